@@ -0,0 +1,216 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"fmt"
+	"image/color"
+	"math/big"
+	"net"
+	"net/netip"
+	"net/url"
+	"reflect"
+	"regexp"
+	"time"
+)
+
+// Any decodes the next value without knowing its kind up front, using the kind on the wire to
+// pick its Go type. Scalars decode as they do with ReadTyped, so for example an Int64 decodes
+// as an int64, while Nil decodes as nil, a Slice as a []any and a Map as a map[any]any.
+func (d *BufferDecoder) Any() (any, error) {
+	return d.decodeAny(0)
+}
+
+func (d *BufferDecoder) decodeAny(depth int) (any, error) {
+	if len(d.b) == 0 {
+		return nil, ErrUnsupportedKind
+	}
+	switch d.b[0] {
+	case SliceRawKind:
+		if depth >= maxSkipDepth {
+			return nil, ErrInvalidSlice
+		}
+		_, size, err := d.SliceHeader()
+		if err != nil {
+			return nil, err
+		}
+		if err = d.checkElements(uint64(size), 1, ErrInvalidSlice); err != nil {
+			return nil, err
+		}
+		value := make([]any, size)
+		for i := range value {
+			if value[i], err = d.decodeAny(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+		return value, nil
+	case MapRawKind:
+		if depth >= maxSkipDepth {
+			return nil, ErrInvalidMap
+		}
+		_, _, size, err := d.MapHeader()
+		if err != nil {
+			return nil, err
+		}
+		if err = d.checkElements(uint64(size), 2, ErrInvalidMap); err != nil {
+			return nil, err
+		}
+		value := make(map[any]any, size)
+		for i := uint32(0); i < size; i++ {
+			k, err := d.decodeAny(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if k != nil && !reflect.TypeOf(k).Comparable() {
+				return nil, ErrInvalidMap
+			}
+			if _, ok := value[k]; ok && d.options.RejectDuplicateKeys {
+				return nil, ErrDuplicateKey
+			}
+			if value[k], err = d.decodeAny(depth + 1); err != nil {
+				return nil, err
+			}
+		}
+		return value, nil
+	}
+	_, value, err := d.ReadTyped()
+	return value, err
+}
+
+// EncodeAny encodes v, which can hold any of the types Decoder.Any decodes into, so that a value
+// decoded with Any can be modified and encoded again. Map and slice elements are encoded as
+// AnyKind, and map[string]any is accepted as well as map[any]any. Kinds that decode as a plain
+// Go type, like StringVar or FloatText, are encoded again as the regular kind for that type.
+// If v or anything it holds can't be encoded, nothing is written and the error is returned.
+func EncodeAny(e *BufferEncoder, v any) error {
+	start := e.offset
+	if err := encodeAny(e, v, 0); err != nil {
+		e.offset = start
+		return err
+	}
+	return nil
+}
+
+func encodeAny(e *BufferEncoder, v any, depth int) error {
+	if depth >= maxSkipDepth {
+		return fmt.Errorf("%w: nested too deeply", ErrUnsupportedType)
+	}
+	switch v := v.(type) {
+	case nil:
+		e.Nil()
+	case bool:
+		e.Bool(v)
+	case uint8:
+		e.Uint8(v)
+	case uint16:
+		e.Uint16(v)
+	case uint32:
+		e.Uint32(v)
+	case uint64:
+		e.Uint64(v)
+	case uint:
+		e.Uint64(uint64(v))
+	case int32:
+		e.Int32(v)
+	case int64:
+		e.Int64(v)
+	case int:
+		e.Int64(int64(v))
+	case float32:
+		e.Float32(v)
+	case float64:
+		e.Float64(v)
+	case string:
+		e.String(v)
+	case []byte:
+		e.Bytes(v)
+	case error:
+		e.Error(v)
+	case netip.Addr:
+		e.NetipAddr(v)
+	case netip.Prefix:
+		e.NetipPrefix(v)
+	case net.HardwareAddr:
+		return e.HardwareAddr(v)
+	case *net.TCPAddr, *net.UDPAddr, *net.UnixAddr:
+		return e.Addr(v.(net.Addr))
+	case time.Time:
+		e.TimeWithZone(v)
+	case TimeRange:
+		e.TimeRange(v.Start, v.End)
+	case Schedule:
+		e.Schedule(v)
+	case LatLng:
+		e.LatLng(v.Lat, v.Lng)
+	case []HistogramBucket:
+		e.Histogram(v)
+	case FlagGroup:
+		e.FlagGroup(v)
+	case *big.Float:
+		e.BigFloat(v)
+	case *big.Rat:
+		e.Rat(v)
+	case *url.URL:
+		e.URL(v)
+	case *regexp.Regexp:
+		e.Regexp(v)
+	case color.RGBA:
+		e.ColorRGBA(v)
+	case color.NRGBA:
+		e.ColorNRGBA(v)
+	case []uint32:
+		EncodeFixedSlice(e, v)
+	case []uint64:
+		EncodeFixedSlice(e, v)
+	case []int32:
+		EncodeFixedSlice(e, v)
+	case []int64:
+		EncodeFixedSlice(e, v)
+	case []float32:
+		EncodeFixedSlice(e, v)
+	case []float64:
+		EncodeFixedSlice(e, v)
+	case []any:
+		e.Slice(uint32(len(v)), AnyKind)
+		for _, elem := range v {
+			if err := encodeAny(e, elem, depth+1); err != nil {
+				return err
+			}
+		}
+	case map[any]any:
+		e.Map(uint32(len(v)), AnyKind, AnyKind)
+		for k, elem := range v {
+			if err := encodeAny(e, k, depth+1); err != nil {
+				return err
+			}
+			if err := encodeAny(e, elem, depth+1); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		e.Map(uint32(len(v)), StringKind, AnyKind)
+		for k, elem := range v {
+			e.String(k)
+			if err := encodeAny(e, elem, depth+1); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("%w: %T", ErrUnsupportedType, v)
+	}
+	return nil
+}
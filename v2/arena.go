@@ -0,0 +1,112 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"unsafe"
+)
+
+const (
+	defaultArenaSize = 4096
+)
+
+// Arena is a bump allocator for the backing storage of decoded bytes and strings.
+//
+// Values allocated from an Arena alias its memory, so callers must not retain them
+// past the next call to Reset. An Arena is not safe for concurrent use.
+type Arena struct {
+	b      []byte
+	offset int
+}
+
+func NewArena() *Arena {
+	return NewArenaSize(defaultArenaSize)
+}
+
+func NewArenaSize(size int) *Arena {
+	return &Arena{
+		b: make([]byte, size),
+	}
+}
+
+// Reset makes the whole Arena available for reuse, invalidating
+// every value previously allocated from it.
+func (a *Arena) Reset() {
+	a.offset = 0
+}
+
+func (a *Arena) Len() int {
+	return a.offset
+}
+
+func (a *Arena) Cap() int {
+	return len(a.b)
+}
+
+func (a *Arena) alloc(n int) []byte {
+	if len(a.b)-a.offset < n {
+		// Values already handed out keep the old block alive, so
+		// it's safe to simply move on to a larger one.
+		size := len(a.b) * 2
+		if size < n {
+			size = n
+		}
+		a.b = make([]byte, size)
+		a.offset = 0
+	}
+	b := a.b[a.offset : a.offset+n : a.offset+n]
+	a.offset += n
+	return b
+}
+
+// decodeBytesAlloc decodes a byte slice into storage obtained from alloc, which
+// must return a slice of length n, such as the alloc method of an Arena. A shorter
+// slice fails with ErrShortAlloc.
+func decodeBytesAlloc(b []byte, alloc func(n int) []byte) ([]byte, []byte, error) {
+	if len(b) > 1 && b[0] == BytesRawKind {
+		remaining, size, err := decodeUint32(b[1:])
+		if err == nil && uint64(len(remaining)) >= uint64(size) {
+			value := alloc(int(size))
+			if len(value) < int(size) {
+				return b, nil, ErrShortAlloc
+			}
+			value = value[:size]
+			copy(value, remaining[:size])
+			return remaining[size:], value, nil
+		}
+	}
+	return b, nil, ErrInvalidBytes
+}
+
+func decodeStringAlloc(b []byte, alloc func(n int) []byte) ([]byte, string, error) {
+	if len(b) > 1 && b[0] == StringRawKind {
+		remaining, size, err := decodeUint32(b[1:])
+		if err == nil && uint64(len(remaining)) >= uint64(size) {
+			if size == 0 {
+				return remaining, emptyString, nil
+			}
+			value := alloc(int(size))
+			if len(value) < int(size) {
+				return b, emptyString, ErrShortAlloc
+			}
+			value = value[:size]
+			copy(value, remaining[:size])
+			return remaining[size:], unsafe.String(unsafe.SliceData(value), size), nil
+		}
+	}
+	return b, emptyString, ErrInvalidString
+}
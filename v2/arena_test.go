@@ -0,0 +1,106 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"testing"
+)
+
+func TestArena(t *testing.T) {
+	t.Parallel()
+
+	a := NewArenaSize(8)
+	b := a.alloc(4)
+	assert.Equal(t, 4, len(b))
+	assert.Equal(t, 4, cap(b))
+	assert.Equal(t, 4, a.Len())
+
+	c := a.alloc(16)
+	assert.Equal(t, 16, len(c))
+	assert.Equal(t, 16, a.Len())
+	assert.Equal(t, 16, a.Cap())
+
+	a.Reset()
+	assert.Equal(t, 0, a.Len())
+	assert.Equal(t, 16, a.Cap())
+}
+
+func TestDecoderWithArena(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).String("Test String").Bytes([]byte("Test Bytes")).String("").Uint32(32)
+
+	a := NewArena()
+	d := DecoderWithArena(p.Bytes(), a)
+
+	s, err := d.String()
+	assert.NoError(t, err)
+	assert.Equal(t, "Test String", s)
+
+	b, err := d.Bytes(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("Test Bytes"), b)
+	assert.Equal(t, len(s)+len(b), a.Len())
+
+	s, err = d.String()
+	assert.NoError(t, err)
+	assert.Equal(t, "", s)
+
+	_, err = d.String()
+	assert.ErrorIs(t, err, ErrInvalidString)
+
+	_, err = d.Bytes(nil)
+	assert.ErrorIs(t, err, ErrInvalidBytes)
+
+	v, err := d.Uint32()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(32), v)
+	assert.Equal(t, 0, d.Remaining())
+}
+
+func TestDecoderWithArenaOptions(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).String("Test String").Bytes([]byte("Test Bytes")).Uint32(32)
+
+	var consumed []int
+	a := NewArena()
+	d := DecoderWithArenaOptions(p.Bytes(), a, DecoderOptions{
+		Progress:     func(n, _ int) { consumed = append(consumed, n) },
+		ErrorContext: 4,
+	})
+
+	s, err := d.String()
+	assert.NoError(t, err)
+	assert.Equal(t, "Test String", s)
+	b, err := d.Bytes(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("Test Bytes"), b)
+	assert.Equal(t, len(s)+len(b), a.Len())
+
+	// The options work from the start of the buffer just as they do without an Arena
+	_, err = d.String()
+	var decodeErr *DecodeError
+	assert.ErrorAs(t, err, &decodeErr)
+	assert.Equal(t, p.Len()-2, decodeErr.Offset())
+	assert.Len(t, consumed, 2)
+	assert.Equal(t, p.Len()-2, consumed[1])
+}
@@ -0,0 +1,57 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"encoding/base64"
+)
+
+// encodeBytesBase64 writes value as a String holding its standard base64 encoding, tagged with
+// Base64RawKind so that it's decoded back to bytes rather than confused with a plain string.
+func encodeBytesBase64(b *Buffer, value []byte) {
+	b.Grow(1)
+	b.b[b.offset] = Base64RawKind
+	b.offset++
+	encodeString(b, base64.StdEncoding.EncodeToString(value))
+}
+
+func decodeBytesBase64(b []byte) ([]byte, []byte, error) {
+	if len(b) > 2 && b[0] == Base64RawKind && b[1] == StringRawKind {
+		remaining, size, err := decodeUint32(b[2:])
+		if err == nil && uint64(len(remaining)) >= uint64(size) {
+			value := make([]byte, base64.StdEncoding.DecodedLen(int(size)))
+			n, err := base64.StdEncoding.Decode(value, remaining[:size])
+			if err == nil {
+				return remaining[size:], value[:n], nil
+			}
+		}
+	}
+	return b, nil, ErrInvalidBytes
+}
+
+// BytesBase64 encodes value as base64 text, for transports that only carry text. It takes a
+// third more space than Bytes, and is decoded with Decoder.BytesBase64 rather than Bytes.
+func (e *BufferEncoder) BytesBase64(value []byte) *BufferEncoder {
+	encodeBytesBase64((*Buffer)(e), value)
+	return e
+}
+
+func (d *BufferDecoder) BytesBase64() (value []byte, err error) {
+	d.b, value, err = decodeBytesBase64(d.b)
+	err = d.step(err)
+	return
+}
@@ -0,0 +1,72 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"testing"
+)
+
+func TestBytesBase64(t *testing.T) {
+	t.Parallel()
+
+	values := [][]byte{[]byte("Test Bytes"), {0x00, 0xFF, 0xFE}, {}}
+
+	p := NewBuffer()
+	e := Encoder(p)
+	for _, v := range values {
+		e.BytesBase64(v)
+	}
+
+	d := Decoder(p.Bytes())
+	for _, v := range values {
+		value, err := d.BytesBase64()
+		assert.NoError(t, err)
+		assert.Equal(t, v, value)
+	}
+	assert.NoError(t, d.Finish())
+
+	// The text is a plain String after the kind.
+	s, err := Decoder(p.Bytes()[1:]).String()
+	assert.NoError(t, err)
+	assert.Equal(t, "VGVzdCBCeXRlcw==", s)
+
+	_, err = Decoder(p.Bytes()).Bytes(nil)
+	assert.ErrorIs(t, err, ErrInvalidBytes)
+
+	kind, value, err := Decoder(p.Bytes()).ReadTyped()
+	assert.NoError(t, err)
+	assert.Equal(t, Base64Kind, kind)
+	assert.Equal(t, values[0], value)
+
+	d = Decoder(p.Bytes())
+	for range values {
+		assert.NoError(t, d.Skip())
+	}
+	assert.NoError(t, d.Finish())
+
+	_, _, err = decodeBytesBase64(p.Bytes()[:5])
+	assert.ErrorIs(t, err, ErrInvalidBytes)
+
+	p.Reset()
+	Encoder(p).String("not base64!")
+	invalid := append([]byte{Base64RawKind}, p.Bytes()...)
+	_, _, err = decodeBytesBase64(invalid)
+	assert.ErrorIs(t, err, ErrInvalidBytes)
+	assert.NoError(t, Decoder(invalid).Skip())
+}
@@ -0,0 +1,76 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package benchmarks
+
+import (
+	"testing"
+
+	"github.com/loopholelabs/polyglot/v2"
+)
+
+const decoderOptionsCount = 64
+
+// BenchmarkDecoderOptions guards the cost of DecoderOptions on the most common reads. A Decoder made
+// with DecoderWithOptions and no options set should decode as fast as one made with Decoder, and
+// only the options that act on every read, like Trace and MaxOps, should slow it down.
+func BenchmarkDecoderOptions(b *testing.B) {
+	polyglotBuf := polyglot.NewBuffer()
+	for i := uint32(0); i < decoderOptionsCount; i++ {
+		polyglot.Encoder(polyglotBuf).Uint32(i * 7919).String("polyglot").Bool(i%2 == 0)
+	}
+	decode := func(d *polyglot.BufferDecoder) {
+		for i := 0; i < decoderOptionsCount; i++ {
+			if _, err := d.Uint32(); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := d.String(); err != nil {
+				b.Fatal(err)
+			}
+			if _, err := d.Bool(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	b.Run("Decoder", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			decode(polyglot.Decoder(polyglotBuf.Bytes()))
+		}
+	})
+
+	b.Run("NoOptions", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			decode(polyglot.DecoderWithOptions(polyglotBuf.Bytes(), polyglot.DecoderOptions{}))
+		}
+	})
+
+	b.Run("Trace", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			decode(polyglot.DecoderWithOptions(polyglotBuf.Bytes(), polyglot.DecoderOptions{Trace: func(polyglot.TraceEvent) {}}))
+		}
+	})
+
+	b.Run("MaxOps", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			decode(polyglot.DecoderWithOptions(polyglotBuf.Bytes(), polyglot.DecoderOptions{MaxOps: 3 * decoderOptionsCount}))
+		}
+	})
+}
@@ -0,0 +1,61 @@
+//go:build !vtproto
+
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package benchmarks
+
+import (
+	"testing"
+
+	"github.com/loopholelabs/polyglot/v2"
+)
+
+const deltaMapCount = 1024
+
+// BenchmarkMapSortedDelta compares a plain Map of Uint64 keys, written in sorted order to make
+// it deterministic, with EncodeMapSortedDelta for keys clustered a few apart, reporting the
+// encoded size of each so the reduction is visible alongside the time taken to encode.
+func BenchmarkMapSortedDelta(b *testing.B) {
+	m := make(map[uint64]uint32, deltaMapCount)
+	keys := make([]uint64, 0, deltaMapCount)
+	for i := uint64(0); i < deltaMapCount; i++ {
+		k := 1700000000000 + i*7
+		m[k] = uint32(i)
+		keys = append(keys, k)
+	}
+	encodeValue := func(e *polyglot.BufferEncoder, v uint32) { e.Uint32(v) }
+
+	b.Run("Map", func(b *testing.B) {
+		polyglotBuf := polyglot.NewBuffer()
+		for i := 0; i < b.N; i++ {
+			polyglotBuf.Reset()
+			polyglot.EncodeOrderedMap(polyglot.Encoder(polyglotBuf), polyglot.Uint64Kind, polyglot.Uint32Kind, keys, func(k uint64) uint32 { return m[k] }, func(e *polyglot.BufferEncoder, k uint64, v uint32) {
+				e.Uint64(k).Uint32(v)
+			})
+		}
+		b.ReportMetric(float64(polyglotBuf.Len()), "bytes")
+	})
+
+	b.Run("MapSortedDelta", func(b *testing.B) {
+		polyglotBuf := polyglot.NewBuffer()
+		for i := 0; i < b.N; i++ {
+			polyglotBuf.Reset()
+			polyglot.EncodeMapSortedDelta(polyglot.Encoder(polyglotBuf), polyglot.Uint32Kind, m, encodeValue)
+		}
+		b.ReportMetric(float64(polyglotBuf.Len()), "bytes")
+	})
+}
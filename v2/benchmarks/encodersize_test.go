@@ -0,0 +1,63 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package benchmarks
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/loopholelabs/polyglot/v2"
+)
+
+// BenchmarkEncoderSize encodes a message of a few kilobytes into a new buffer, comparing one made
+// with NewEncoderSize from the size added up with the Size functions, which allocates once, with
+// the default buffer that grows by doubling as the message is written.
+func BenchmarkEncoderSize(b *testing.B) {
+	values := make([]string, 256)
+	for i := range values {
+		values[i] = "value-" + strconv.Itoa(i)
+	}
+	encode := func(e *polyglot.BufferEncoder) {
+		e.Slice(uint32(len(values)), polyglot.StringKind)
+		for _, v := range values {
+			e.String(v)
+		}
+		e.Uint64(1700000000000)
+	}
+
+	b.Run("Hinted", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			size := polyglot.SizeSlice(uint32(len(values))) + polyglot.SizeUint64(1700000000000)
+			for _, v := range values {
+				size += polyglot.SizeString(v)
+			}
+			e := polyglot.NewEncoderSize(size)
+			encode(e)
+			if e.Buffer().Len() != size {
+				b.Fatalf("encoded %d bytes, expected %d", e.Buffer().Len(), size)
+			}
+		}
+	})
+
+	b.Run("Default", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			encode(polyglot.Encoder(polyglot.NewBuffer()))
+		}
+	})
+}
@@ -0,0 +1,62 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package benchmarks
+
+import (
+	"testing"
+
+	"github.com/loopholelabs/polyglot/v2"
+)
+
+const mapIntoCount = 4096
+
+// BenchmarkMapInto compares DecodeMapInto filling a map made with room for every entry, which it
+// does when given a nil map, with filling a map made without a size that grows as it's filled.
+func BenchmarkMapInto(b *testing.B) {
+	polyglotBuf := polyglot.NewBuffer()
+	polyglot.Encoder(polyglotBuf).Map(mapIntoCount, polyglot.Uint64Kind, polyglot.Uint32Kind)
+	for i := uint64(0); i < mapIntoCount; i++ {
+		polyglot.Encoder(polyglotBuf).Uint64(i * 7919).Uint32(uint32(i))
+	}
+	decode := func(d *polyglot.BufferDecoder) (k uint64, v uint32, err error) {
+		if k, err = d.Uint64(); err != nil {
+			return
+		}
+		v, err = d.Uint32()
+		return
+	}
+
+	b.Run("Hinted", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, err := polyglot.DecodeMapInto(polyglot.Decoder(polyglotBuf.Bytes()), polyglot.Uint64Kind, polyglot.Uint32Kind, nil, decode)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("Unhinted", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_, err := polyglot.DecodeMapInto(polyglot.Decoder(polyglotBuf.Bytes()), polyglot.Uint64Kind, polyglot.Uint32Kind, make(map[uint64]uint32), decode)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
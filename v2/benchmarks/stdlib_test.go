@@ -0,0 +1,249 @@
+//go:build stdlib
+
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package benchmarks
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"testing"
+
+	"github.com/loopholelabs/polyglot/v2"
+)
+
+type stdlibAddress struct {
+	Street string
+	City   string
+	Zip    uint32
+}
+
+type stdlibRecord struct {
+	ID         uint64
+	Name       string
+	Email      string
+	Age        int32
+	Score      float64
+	Active     bool
+	Tags       []string
+	Attributes map[string]string
+	Address    stdlibAddress
+}
+
+var record = stdlibRecord{
+	ID:     1 << 40,
+	Name:   "Ada Lovelace",
+	Email:  "ada@example.com",
+	Age:    36,
+	Score:  99.5,
+	Active: true,
+	Tags:   []string{"math", "engine", "poetry", "notes"},
+	Attributes: map[string]string{
+		"country":  "UK",
+		"language": "en",
+		"role":     "analyst",
+	},
+	Address: stdlibAddress{
+		Street: "12 St James's Square",
+		City:   "London",
+		Zip:    10001,
+	},
+}
+
+// encodeRecord and decodeRecord mirror the code protoc-gen-go-polyglot generates.
+func encodeRecord(b *polyglot.Buffer, r *stdlibRecord) {
+	e := polyglot.Encoder(b).Uint64(r.ID).String(r.Name).String(r.Email).Int32(r.Age).Float64(r.Score).Bool(r.Active)
+	e.Slice(uint32(len(r.Tags)), polyglot.StringKind)
+	for _, tag := range r.Tags {
+		e.String(tag)
+	}
+	e.Map(uint32(len(r.Attributes)), polyglot.StringKind, polyglot.StringKind)
+	for k, v := range r.Attributes {
+		e.String(k).String(v)
+	}
+	e.String(r.Address.Street).String(r.Address.City).Uint32(r.Address.Zip)
+}
+
+func decodeRecord(b []byte, r *stdlibRecord) (err error) {
+	d := polyglot.Decoder(b)
+	if r.ID, err = d.Uint64(); err != nil {
+		return
+	}
+	if r.Name, err = d.String(); err != nil {
+		return
+	}
+	if r.Email, err = d.String(); err != nil {
+		return
+	}
+	if r.Age, err = d.Int32(); err != nil {
+		return
+	}
+	if r.Score, err = d.Float64(); err != nil {
+		return
+	}
+	if r.Active, err = d.Bool(); err != nil {
+		return
+	}
+	size, err := d.Slice(polyglot.StringKind)
+	if err != nil {
+		return
+	}
+	r.Tags = make([]string, size)
+	for i := range r.Tags {
+		if r.Tags[i], err = d.String(); err != nil {
+			return
+		}
+	}
+	if size, err = d.Map(polyglot.StringKind, polyglot.StringKind); err != nil {
+		return
+	}
+	r.Attributes = make(map[string]string, size)
+	for i := uint32(0); i < size; i++ {
+		var k, v string
+		if k, err = d.String(); err != nil {
+			return
+		}
+		if v, err = d.String(); err != nil {
+			return
+		}
+		r.Attributes[k] = v
+	}
+	if r.Address.Street, err = d.String(); err != nil {
+		return
+	}
+	if r.Address.City, err = d.String(); err != nil {
+		return
+	}
+	r.Address.Zip, err = d.Uint32()
+	return
+}
+
+func BenchmarkEncodeStdlib(b *testing.B) {
+	b.Run("Polyglot", func(b *testing.B) {
+		buf := polyglot.NewBuffer()
+		encodeRecord(buf, &record)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			buf.Reset()
+			encodeRecord(buf, &record)
+		}
+		b.ReportMetric(float64(buf.Len()), "bytes/msg")
+	})
+
+	b.Run("PolyglotMarshal", func(b *testing.B) {
+		data, err := polyglot.Marshal(&record)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, _ = polyglot.Marshal(&record)
+		}
+		b.ReportMetric(float64(len(data)), "bytes/msg")
+	})
+
+	b.Run("Gob", func(b *testing.B) {
+		buf := new(bytes.Buffer)
+		if err := gob.NewEncoder(buf).Encode(&record); err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			buf.Reset()
+			_ = gob.NewEncoder(buf).Encode(&record)
+		}
+		b.ReportMetric(float64(buf.Len()), "bytes/msg")
+	})
+
+	b.Run("JSON", func(b *testing.B) {
+		data, err := json.Marshal(&record)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, _ = json.Marshal(&record)
+		}
+		b.ReportMetric(float64(len(data)), "bytes/msg")
+	})
+}
+
+func BenchmarkDecodeStdlib(b *testing.B) {
+	b.Run("Polyglot", func(b *testing.B) {
+		buf := polyglot.NewBuffer()
+		encodeRecord(buf, &record)
+		var r stdlibRecord
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := decodeRecord(buf.Bytes(), &r); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("PolyglotMarshal", func(b *testing.B) {
+		data, err := polyglot.Marshal(&record)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var r stdlibRecord
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err = polyglot.Unmarshal(data, &r); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("Gob", func(b *testing.B) {
+		buf := new(bytes.Buffer)
+		if err := gob.NewEncoder(buf).Encode(&record); err != nil {
+			b.Fatal(err)
+		}
+		data := buf.Bytes()
+		var r stdlibRecord
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&r); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("JSON", func(b *testing.B) {
+		data, err := json.Marshal(&record)
+		if err != nil {
+			b.Fatal(err)
+		}
+		var r stdlibRecord
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err = json.Unmarshal(data, &r); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
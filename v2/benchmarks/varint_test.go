@@ -0,0 +1,84 @@
+//go:build !vtproto
+
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package benchmarks
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/loopholelabs/polyglot/v2"
+)
+
+const varintCount = 1024
+
+// BenchmarkDecodeVarint compares decoding LEB128 varints written by Uint64 with PrefixVarints
+// written by PrefixUint64, for values of the same length, and for values of mixed lengths. The
+// PrefixVarint is only faster for Large values, which it reads with a single load rather than a
+// loop over ten bytes. It's slower for Small values, as Decoder.Uint64 tries LEB128 first, and
+// around the same or slower for Medium and Mixed ones.
+func BenchmarkDecodeVarint(b *testing.B) {
+	random := rand.New(rand.NewSource(0))
+	mixed := make([]uint64, varintCount)
+	for i := range mixed {
+		mixed[i] = random.Uint64() >> random.Intn(64)
+	}
+	values := []struct {
+		name   string
+		values func(int) uint64
+	}{
+		{"Small", func(int) uint64 { return 100 }},
+		{"Medium", func(int) uint64 { return 1 << 30 }},
+		{"Large", func(int) uint64 { return math.MaxUint64 }},
+		{"Mixed", func(i int) uint64 { return mixed[i] }},
+	}
+	schemes := []struct {
+		name   string
+		encode func(*polyglot.BufferEncoder, uint64) *polyglot.BufferEncoder
+	}{
+		{"LEB128", (*polyglot.BufferEncoder).Uint64},
+		{"PrefixVarint", (*polyglot.BufferEncoder).PrefixUint64},
+	}
+	for _, value := range values {
+		for _, scheme := range schemes {
+			b.Run(scheme.name+"/"+value.name, func(b *testing.B) {
+				polyglotBuf := polyglot.NewBuffer()
+				e := polyglot.Encoder(polyglotBuf)
+				for i := 0; i < varintCount; i++ {
+					scheme.encode(e, value.values(i))
+				}
+				polyglotBytes := polyglotBuf.Bytes()
+				b.SetBytes(int64(len(polyglotBytes)))
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					d := polyglot.Decoder(polyglotBytes)
+					for j := 0; j < varintCount; j++ {
+						v, err := d.Uint64()
+						if err != nil {
+							b.Fatal(err)
+						}
+						if v != value.values(j) {
+							b.Fail()
+						}
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,135 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"math/big"
+)
+
+// encodeBigFloat writes the GobEncode form of value, which carries the precision, rounding
+// mode, accuracy, sign, exponent and mantissa, so ±Inf, ±0 and the precision all round-trip.
+// A nil value is written with a zero length.
+func encodeBigFloat(b *Buffer, value *big.Float) {
+	if value == nil {
+		b.Grow(2)
+		b.b[b.offset] = BigFloatRawKind
+		b.b[b.offset+1] = 0
+		b.offset += 2
+		return
+	}
+	data, _ := value.GobEncode()
+	b.Grow(1 + VarIntLen64 + len(data))
+	b.b[b.offset] = BigFloatRawKind
+	b.offset++
+	writeUvarint(b, uint64(len(data)))
+	b.offset += copy(b.b[b.offset:], data)
+}
+
+func decodeBigFloat(b []byte) ([]byte, *big.Float, error) {
+	if len(b) > 1 && b[0] == BigFloatRawKind {
+		remaining, size, ok := readUvarint(b[1:])
+		if ok && size <= uint64(len(remaining)) {
+			if size == 0 {
+				return remaining, nil, nil
+			}
+			value := new(big.Float)
+			if err := value.GobDecode(remaining[:size]); err == nil {
+				return remaining[size:], value, nil
+			}
+		}
+	}
+	return b, nil, ErrInvalidBigFloat
+}
+
+// BigFloat encodes value along with its precision and rounding mode, so the
+// decoded value is identical to value rather than rounded to a float64.
+func (e *BufferEncoder) BigFloat(value *big.Float) *BufferEncoder {
+	encodeBigFloat((*Buffer)(e), value)
+	return e
+}
+
+func (d *BufferDecoder) BigFloat() (value *big.Float, err error) {
+	d.b, value, err = decodeBigFloat(d.b)
+	err = d.step(err)
+	return
+}
+
+const (
+	ratPositive = byte(0)
+	ratNegative = byte(1)
+	ratNil      = byte(2)
+)
+
+// encodeRat writes the sign, then the magnitudes of the numerator and denominator as length
+// prefixed big-endian bytes. A big.Rat is always kept in lowest terms, so the output is canonical.
+func encodeRat(b *Buffer, value *big.Rat) {
+	if value == nil {
+		b.Grow(2)
+		b.b[b.offset] = RatRawKind
+		b.b[b.offset+1] = ratNil
+		b.offset += 2
+		return
+	}
+	num, denom := value.Num().Bytes(), value.Denom().Bytes()
+	b.Grow(2 + 2*VarIntLen64 + len(num) + len(denom))
+	b.b[b.offset] = RatRawKind
+	b.b[b.offset+1] = ratPositive
+	if value.Sign() < 0 {
+		b.b[b.offset+1] = ratNegative
+	}
+	b.offset += 2
+	writeUvarint(b, uint64(len(num)))
+	b.offset += copy(b.b[b.offset:], num)
+	writeUvarint(b, uint64(len(denom)))
+	b.offset += copy(b.b[b.offset:], denom)
+}
+
+func decodeRat(b []byte) ([]byte, *big.Rat, error) {
+	if len(b) > 1 && b[0] == RatRawKind {
+		sign := b[1]
+		if sign == ratNil {
+			return b[2:], nil, nil
+		}
+		remaining, size, ok := readUvarint(b[2:])
+		if sign <= ratNegative && ok && size <= uint64(len(remaining)) {
+			num := new(big.Int).SetBytes(remaining[:size])
+			remaining = remaining[size:]
+			if remaining, size, ok = readUvarint(remaining); ok && size <= uint64(len(remaining)) {
+				denom := new(big.Int).SetBytes(remaining[:size])
+				if denom.Sign() != 0 {
+					if sign == ratNegative {
+						num.Neg(num)
+					}
+					return remaining[size:], new(big.Rat).SetFrac(num, denom), nil
+				}
+			}
+		}
+	}
+	return b, nil, ErrInvalidRat
+}
+
+// Rat encodes value exactly, as its numerator and denominator.
+func (e *BufferEncoder) Rat(value *big.Rat) *BufferEncoder {
+	encodeRat((*Buffer)(e), value)
+	return e
+}
+
+func (d *BufferDecoder) Rat() (value *big.Rat, err error) {
+	d.b, value, err = decodeRat(d.b)
+	err = d.step(err)
+	return
+}
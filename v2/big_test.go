@@ -0,0 +1,135 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestBigFloat(t *testing.T) {
+	t.Parallel()
+
+	pi, _, err := big.ParseFloat("3.14159265358979323846264338327950288419716939937510582097494459", 10, 200, big.ToNearestEven)
+	assert.NoError(t, err)
+
+	values := []*big.Float{
+		pi,
+		new(big.Float).SetPrec(500).Quo(big.NewFloat(1), big.NewFloat(3)),
+		new(big.Float).SetMode(big.ToZero).SetFloat64(-1.5e300),
+		new(big.Float),
+		new(big.Float).Neg(new(big.Float)),
+		new(big.Float).SetInf(false),
+		new(big.Float).SetInf(true),
+		big.NewFloat(math.SmallestNonzeroFloat64),
+	}
+
+	p := NewBuffer()
+	e := Encoder(p)
+	for _, v := range values {
+		e.BigFloat(v)
+	}
+	e.BigFloat(nil)
+
+	d := Decoder(p.Bytes())
+	for _, v := range values {
+		value, err := d.BigFloat()
+		assert.NoError(t, err)
+		assert.Equal(t, v.Prec(), value.Prec())
+		assert.Equal(t, v.Mode(), value.Mode())
+		assert.Equal(t, v.Signbit(), value.Signbit())
+		assert.Equal(t, v.IsInf(), value.IsInf())
+		assert.Zero(t, v.Cmp(value))
+		assert.Equal(t, v.Text('p', 0), value.Text('p', 0))
+	}
+	value, err := d.BigFloat()
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+	assert.NoError(t, d.Finish())
+
+	d = Decoder(p.Bytes())
+	for range values {
+		assert.NoError(t, d.Skip())
+	}
+
+	_, _, err = decodeBigFloat(p.Bytes()[:5])
+	assert.ErrorIs(t, err, ErrInvalidBigFloat)
+
+	_, _, err = decodeBigFloat([]byte{BigFloatRawKind, 2, 0xFF, 0xFF})
+	assert.ErrorIs(t, err, ErrInvalidBigFloat)
+
+	_, _, err = decodeBigFloat([]byte{Uint8RawKind, 0})
+	assert.ErrorIs(t, err, ErrInvalidBigFloat)
+}
+
+func TestRat(t *testing.T) {
+	t.Parallel()
+
+	huge, ok := new(big.Rat).SetString("-123456789012345678901234567890/987654321098765432109876543211")
+	assert.True(t, ok)
+
+	values := []*big.Rat{
+		big.NewRat(1, 3),
+		big.NewRat(-22, 7),
+		big.NewRat(4, 8),
+		new(big.Rat),
+		huge,
+	}
+
+	p := NewBuffer()
+	e := Encoder(p)
+	for _, v := range values {
+		e.Rat(v)
+	}
+	e.Rat(nil)
+
+	d := Decoder(p.Bytes())
+	for _, v := range values {
+		value, err := d.Rat()
+		assert.NoError(t, err)
+		assert.Zero(t, v.Cmp(value))
+	}
+	value, err := d.Rat()
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+	assert.NoError(t, d.Finish())
+
+	d = Decoder(p.Bytes())
+	for range values {
+		assert.NoError(t, d.Skip())
+	}
+	assert.NoError(t, d.Skip())
+	assert.NoError(t, d.Finish())
+
+	p.Reset()
+	Encoder(p).Rat(big.NewRat(2, 4))
+	same := NewBuffer()
+	Encoder(same).Rat(big.NewRat(1, 2))
+	assert.Equal(t, same.Bytes(), p.Bytes())
+
+	_, _, err = decodeRat(p.Bytes()[:4])
+	assert.ErrorIs(t, err, ErrInvalidRat)
+
+	_, _, err = decodeRat([]byte{RatRawKind, 0, 1, 1, 0})
+	assert.ErrorIs(t, err, ErrInvalidRat)
+
+	_, _, err = decodeRat([]byte{RatRawKind, 3, 1, 1, 1, 1})
+	assert.ErrorIs(t, err, ErrInvalidRat)
+}
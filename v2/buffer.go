@@ -54,15 +54,15 @@ func (buf *Buffer) MoveOffset(offset int) {
 	buf.offset += offset
 }
 
+// Grow makes room for at least n more bytes after the offset. A buffer that's too small is
+// replaced with one of double the capacity, or of the capacity needed for the n bytes if that's
+// larger, so that a message of any size is encoded with a number of allocations logarithmic in
+// its size, and a buffer created with a big enough size is never grown at all.
 func (buf *Buffer) Grow(n int) {
 	if cap(buf.b)-buf.offset < n {
-		if cap(buf.b) < n {
-			buf.b = append(buf.b[:buf.offset], make([]byte, n+cap(buf.b)-buf.offset)...)
-		} else {
-			buf.b = append(buf.b[:buf.offset], make([]byte, cap(buf.b)*2-buf.offset)...)
-		}
-		// Grow buffer length to match its new capacity.
-		buf.b = buf.b[:cap(buf.b)]
+		b := make([]byte, max(2*cap(buf.b), buf.offset+n))
+		copy(b, buf.b[:buf.offset])
+		buf.b = b
 	}
 }
 
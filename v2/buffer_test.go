@@ -375,7 +375,7 @@ func TestCompleteChain(t *testing.T) {
 	}
 	assert.Equal(t, test.m, val.m)
 
-	assert.Equal(t, 0, len(*d))
+	assert.Equal(t, 0, d.Remaining())
 
 	p.Reset()
 	n := testing.AllocsPerRun(100, func() {
@@ -435,3 +435,29 @@ func TestLen(t *testing.T) {
 
 	assert.Equal(t, 14, p.Len())
 }
+
+func TestGrow(t *testing.T) {
+	t.Parallel()
+
+	p := NewBufferSize(16)
+	p.Write([]byte("0123456789"))
+
+	// Doubling the capacity is enough for the write
+	p.Grow(20)
+	assert.Equal(t, 32, p.Cap())
+	assert.Equal(t, []byte("0123456789"), p.Bytes())
+
+	// Otherwise the buffer grows to fit it exactly
+	p.Grow(100)
+	assert.Equal(t, 110, p.Cap())
+	assert.Equal(t, []byte("0123456789"), p.Bytes())
+
+	// There's already room
+	p.Grow(100)
+	assert.Equal(t, 110, p.Cap())
+
+	p = NewBufferSize(0)
+	p.Write([]byte("abc"))
+	assert.Equal(t, []byte("abc"), p.Bytes())
+	assert.Equal(t, 3, p.Cap())
+}
@@ -0,0 +1,160 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+)
+
+// crc8Poly is the CRC-8 polynomial x^8 + x^2 + x + 1, which detects every
+// single-bit and double-bit error in the few bytes of a checked varint.
+const crc8Poly = 0x07
+
+func crc8(b []byte) byte {
+	var crc byte
+	for _, c := range b {
+		crc ^= c
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ crc8Poly
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// encodeCheckedUint64 writes value as an untagged varint, followed by
+// the CRC-8 of the kind and the varint.
+func encodeCheckedUint64(b *Buffer, value uint64) {
+	b.Grow(2 + VarIntLen64)
+	start := b.offset
+	b.b[b.offset] = CheckedUint64RawKind
+	b.offset++
+	writeUvarint(b, value)
+	b.b[b.offset] = crc8(b.b[start:b.offset])
+	b.offset++
+}
+
+func decodeCheckedUint64(b []byte) ([]byte, uint64, error) {
+	if len(b) > 2 && b[0] == CheckedUint64RawKind {
+		remaining, value, ok := readUvarint(b[1:])
+		if ok && len(remaining) > 0 {
+			size := len(b) - len(remaining)
+			if crc8(b[:size]) != remaining[0] {
+				return b, 0, ErrChecksumMismatch
+			}
+			return remaining[1:], value, nil
+		}
+	}
+	return b, 0, ErrInvalidUint64
+}
+
+// CheckedUint64 encodes value followed by a one byte checksum, so that a corrupted value is
+// detected when it's decoded with CheckedUint64 rather than silently decoding as another value.
+func (e *BufferEncoder) CheckedUint64(value uint64) *BufferEncoder {
+	encodeCheckedUint64((*Buffer)(e), value)
+	return e
+}
+
+// CheckedUint64 decodes a value encoded with CheckedUint64,
+// returning ErrChecksumMismatch if it has been corrupted.
+func (d *BufferDecoder) CheckedUint64() (value uint64, err error) {
+	d.b, value, err = decodeCheckedUint64(d.b)
+	err = d.step(err)
+	return
+}
+
+func encodeChecksumSliceHeader(b *Buffer, kind Kind, size int) {
+	b.Grow(2 + VarIntLen64)
+	b.b[b.offset] = ChecksumSliceRawKind
+	b.b[b.offset+1] = byte(kind)
+	b.offset += 2
+	writeUvarint(b, uint64(size))
+}
+
+// decodeChecksumSliceHeader returns the elements, their size and their length in bytes, having
+// checked the CRC-32C that follows them so that a corrupted element is reported as ErrChecksumMismatch rather than
+// being decoded, or failing with an error that's harder to tell apart from a bug.
+func decodeChecksumSliceHeader(b []byte, kind Kind) ([]byte, uint64, int, error) {
+	if len(b) > 2 && b[0] == ChecksumSliceRawKind && b[1] == byte(kind) {
+		elements, size, ok := readUvarint(b[2:])
+		if ok && size <= uint64(len(elements)) {
+			remaining := elements
+			var err error
+			for i := uint64(0); i < size && err == nil; i++ {
+				remaining, err = skipValue(remaining, 0)
+			}
+			if err == nil && len(remaining) >= 4 {
+				n := len(elements) - len(remaining)
+				if binary.LittleEndian.Uint32(remaining) != crc32.Checksum(elements[:n], crcTable) {
+					return b, 0, 0, ErrChecksumMismatch
+				}
+				return elements, size, n, nil
+			}
+		}
+	}
+	return b, 0, 0, ErrInvalidChecksumSlice
+}
+
+// EncodeChecksumSlice encodes values like a Slice, each of the given kind and written by encode,
+// followed by the CRC-32C of the encoded elements. This detects corruption within a large slice
+// without checksumming the whole message, at a cost of four bytes.
+func EncodeChecksumSlice[T any](e *BufferEncoder, kind Kind, values []T, encode func(*BufferEncoder, T)) *BufferEncoder {
+	b := (*Buffer)(e)
+	encodeChecksumSliceHeader(b, kind, len(values))
+	start := b.offset
+	for _, v := range values {
+		encode(e, v)
+	}
+	b.Grow(4)
+	binary.LittleEndian.PutUint32(b.b[b.offset:], crc32.Checksum(b.b[start:b.offset], crcTable))
+	b.offset += 4
+	return e
+}
+
+// DecodeChecksumSlice decodes a slice encoded with EncodeChecksumSlice, with decode reading a
+// single element. The checksum is verified before any element is decoded, returning
+// ErrChecksumMismatch if the elements have been corrupted.
+func DecodeChecksumSlice[T any](d *BufferDecoder, kind Kind, decode func(*BufferDecoder) (T, error)) ([]T, error) {
+	var size uint64
+	var n int
+	var err error
+	d.b, size, n, err = decodeChecksumSliceHeader(d.b, kind)
+	err = d.step(err)
+	if err != nil {
+		return nil, err
+	}
+	if err = d.checkElements(size, 1, ErrInvalidChecksumSlice); err != nil {
+		return nil, err
+	}
+	end := len(d.b) - n
+	values := make([]T, size)
+	for i := range values {
+		if values[i], err = decode(d); err != nil {
+			return nil, err
+		}
+	}
+	// The checksum only covers what was skipped, so decode must have read exactly that
+	if len(d.b) != end {
+		return nil, ErrInvalidChecksumSlice
+	}
+	d.b = d.b[4:]
+	return values, nil
+}
@@ -0,0 +1,150 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"math"
+	"testing"
+)
+
+func TestCheckedUint64(t *testing.T) {
+	t.Parallel()
+
+	values := []uint64{0, 1, 127, 128, 1 << 40, math.MaxUint64}
+
+	p := NewBuffer()
+	e := Encoder(p)
+	for _, v := range values {
+		e.CheckedUint64(v)
+	}
+
+	d := Decoder(p.Bytes())
+	for _, v := range values {
+		value, err := d.CheckedUint64()
+		assert.NoError(t, err)
+		assert.Equal(t, v, value)
+	}
+	assert.Equal(t, 0, d.Remaining())
+
+	d = Decoder(p.Bytes())
+	kind, typed, err := d.ReadTyped()
+	assert.NoError(t, err)
+	assert.Equal(t, CheckedUint64Kind, kind)
+	assert.Equal(t, values[0], typed)
+	for range values[1:] {
+		assert.NoError(t, d.Skip())
+	}
+	assert.Equal(t, 0, d.Remaining())
+
+	for _, v := range values {
+		p.Reset()
+		Encoder(p).CheckedUint64(v)
+		for i := 8; i < 8*p.Len(); i++ {
+			b := append([]byte{}, p.Bytes()...)
+			b[i/8] ^= 1 << (i % 8)
+			decoded, err := Decoder(b).CheckedUint64()
+			if err == nil {
+				t.Errorf("bit flip %d of %d went undetected, decoded as %d", i, v, decoded)
+			}
+		}
+	}
+
+	p.Reset()
+	Encoder(p).CheckedUint64(1 << 40)
+	b := p.Bytes()
+	b[len(b)-1]++
+	_, err = Decoder(b).CheckedUint64()
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+
+	_, err = Decoder(b[:len(b)-1]).CheckedUint64()
+	assert.ErrorIs(t, err, ErrInvalidUint64)
+}
+
+func TestChecksumSlice(t *testing.T) {
+	t.Parallel()
+
+	values := []string{"alpha", "beta", "gamma", "delta"}
+	encode := func(e *BufferEncoder, v string) { e.String(v) }
+	decode := func(d *BufferDecoder) (string, error) { return d.String() }
+
+	p := NewBuffer()
+	EncodeChecksumSlice(Encoder(p), StringKind, values, encode).Bool(true)
+
+	d := Decoder(p.Bytes())
+	decoded, err := DecodeChecksumSlice(d, StringKind, decode)
+	assert.NoError(t, err)
+	assert.Equal(t, values, decoded)
+	b, err := d.Bool()
+	assert.NoError(t, err)
+	assert.True(t, b)
+
+	slice := NewBuffer()
+	Encoder(slice).Slice(uint32(len(values)), StringKind)
+	for _, v := range values {
+		Encoder(slice).String(v)
+	}
+	// The size is an untagged varint, a byte shorter than the Uint32 of a Slice, and the checksum takes four
+	assert.Equal(t, slice.Len()+3, p.Len()-2)
+
+	p.Reset()
+	EncodeChecksumSlice(Encoder(p), StringKind, nil, encode)
+	decoded, err = DecodeChecksumSlice(Decoder(p.Bytes()), StringKind, decode)
+	assert.NoError(t, err)
+	assert.Empty(t, decoded)
+
+	p.Reset()
+	EncodeChecksumSlice(Encoder(p), StringKind, values, encode)
+	n, complete, err := MessageComplete(p.Bytes())
+	assert.NoError(t, err)
+	assert.True(t, complete)
+	assert.Equal(t, p.Len(), n)
+	for i := 1; i < p.Len(); i++ {
+		_, complete, err = MessageComplete(p.Bytes()[:i])
+		assert.NoError(t, err)
+		assert.False(t, complete)
+		_, err = DecodeChecksumSlice(Decoder(p.Bytes()[:i]), StringKind, decode)
+		assert.ErrorIs(t, err, ErrInvalidChecksumSlice)
+	}
+
+	// Corrupting a single bit of an element is detected, even though it would still decode
+	corrupted := append([]byte{}, p.Bytes()...)
+	corrupted[len(corrupted)-6] ^= 0x01
+	d = Decoder(corrupted)
+	_, err = DecodeChecksumSlice(d, StringKind, decode)
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	assert.Equal(t, len(corrupted), d.Remaining())
+
+	// As is corrupting the checksum itself
+	corrupted = append([]byte{}, p.Bytes()...)
+	corrupted[len(corrupted)-1] ^= 0x80
+	_, err = DecodeChecksumSlice(Decoder(corrupted), StringKind, decode)
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+
+	_, err = DecodeChecksumSlice(Decoder(p.Bytes()), BytesKind, func(d *BufferDecoder) ([]byte, error) { return d.Bytes(nil) })
+	assert.ErrorIs(t, err, ErrInvalidChecksumSlice)
+
+	_, err = DecodeChecksumSlice(DecoderWithOptions(p.Bytes(), DecoderOptions{MaxElements: len(values) - 1}), StringKind, decode)
+	assert.ErrorIs(t, err, ErrTooManyElements)
+
+	// Decoding anything other than the checksummed elements is an error
+	_, err = DecodeChecksumSlice(Decoder(p.Bytes()), StringKind, func(d *BufferDecoder) (string, error) {
+		return "", nil
+	})
+	assert.ErrorIs(t, err, ErrInvalidChecksumSlice)
+}
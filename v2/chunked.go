@@ -0,0 +1,102 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"io"
+	"math"
+)
+
+// bytesChunkSize is the most BytesChunked reads from its io.Reader at once.
+const bytesChunkSize = 32 * 1024
+
+// checkTotal rejects a total that can't be the length of a Bytes value.
+func checkTotal(total int) error {
+	if total < 0 || uint64(total) > math.MaxUint32 {
+		return ErrInvalidTotal
+	}
+	return nil
+}
+
+func encodeBytesHeader(b *Buffer, size uint32) {
+	b.Grow(bytesSize)
+	b.b[b.offset] = BytesRawKind
+	b.offset++
+	encodeUint32(b, size)
+}
+
+// readChunk reads the next chunk of at most remaining bytes from r directly into b.
+func readChunk(b *Buffer, r io.Reader, remaining int) (int, error) {
+	n := min(remaining, bytesChunkSize)
+	b.Grow(n)
+	if _, err := io.ReadFull(r, b.b[b.offset:b.offset+n]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, err
+	}
+	b.offset += n
+	return n, nil
+}
+
+// BytesChunked encodes exactly total bytes read from r as a Bytes value, which decodes identically
+// to one written by Bytes, without first reading all of r into memory. If r returns an error or
+// ends early, nothing is written and io.ErrUnexpectedEOF or the error is returned. A total that's
+// negative or above math.MaxUint32 fails with ErrInvalidTotal before anything is read or written.
+func (e *BufferEncoder) BytesChunked(r io.Reader, total int) error {
+	if err := checkTotal(total); err != nil {
+		return err
+	}
+	b := (*Buffer)(e)
+	start := b.offset
+	encodeBytesHeader(b, uint32(total))
+	for remaining := total; remaining > 0; {
+		n, err := readChunk(b, r, remaining)
+		if err != nil {
+			b.offset = start
+			return err
+		}
+		remaining -= n
+	}
+	return nil
+}
+
+// BytesChunked flushes anything already encoded and then streams total bytes from r to the Sink
+// as a Bytes value, one chunk at a time, so that at most one chunk is ever held in memory. If r
+// or the Sink fails part way through, the Sink will have received an incomplete value. A total
+// that's negative or above math.MaxUint32 fails with ErrInvalidTotal before anything is flushed.
+func (s *SinkEncoder) BytesChunked(r io.Reader, total int) error {
+	if err := checkTotal(total); err != nil {
+		return err
+	}
+	encodeBytesHeader(s.buf, uint32(total))
+	if err := s.Flush(); err != nil {
+		return err
+	}
+	for remaining := total; remaining > 0; {
+		n, err := readChunk(s.buf, r, remaining)
+		if err != nil {
+			s.buf.Reset()
+			return err
+		}
+		if err = s.Flush(); err != nil {
+			return err
+		}
+		remaining -= n
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"bytes"
+	"crypto/rand"
+	"io"
+	"math"
+	"testing"
+)
+
+// countingSink records the size of every Append.
+type countingSink struct {
+	Buffer
+	appends []int
+}
+
+func (c *countingSink) Append(b []byte) error {
+	c.appends = append(c.appends, len(b))
+	return c.Buffer.Append(b)
+}
+
+func TestBytesChunked(t *testing.T) {
+	t.Parallel()
+
+	data := make([]byte, 3*bytesChunkSize+17)
+	_, err := rand.Read(data)
+	assert.NoError(t, err)
+
+	expected := NewBuffer()
+	Encoder(expected).Bytes(data).Uint8(8)
+
+	p := NewBuffer()
+	assert.NoError(t, Encoder(p).BytesChunked(bytes.NewReader(data), len(data)))
+	Encoder(p).Uint8(8)
+	assert.Equal(t, expected.Bytes(), p.Bytes())
+
+	sink := new(countingSink)
+	s := NewSinkEncoder(sink)
+	assert.NoError(t, s.BytesChunked(bytes.NewReader(data), len(data)))
+	s.Uint8(8)
+	assert.NoError(t, s.Flush())
+	assert.Equal(t, expected.Bytes(), sink.Bytes())
+	for _, n := range sink.appends {
+		assert.LessOrEqual(t, n, bytesChunkSize)
+	}
+
+	p.Reset()
+	assert.NoError(t, Encoder(p).BytesChunked(bytes.NewReader(nil), 0))
+	value, err := Decoder(p.Bytes()).Bytes(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, value)
+
+	p.Reset()
+	Encoder(p).Uint8(8)
+	err = Encoder(p).BytesChunked(bytes.NewReader(data[:bytesChunkSize+1]), len(data))
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Equal(t, 2, p.Len())
+
+	p.Reset()
+	assert.ErrorIs(t, Encoder(p).BytesChunked(bytes.NewReader(data), -1), ErrInvalidTotal)
+	assert.ErrorIs(t, Encoder(p).BytesChunked(bytes.NewReader(data), math.MaxUint32+1), ErrInvalidTotal)
+	assert.Equal(t, 0, p.Len())
+	sink = new(countingSink)
+	s = NewSinkEncoder(sink)
+	s.Uint8(8)
+	assert.ErrorIs(t, s.BytesChunked(bytes.NewReader(data), math.MaxUint32+1), ErrInvalidTotal)
+	assert.Empty(t, sink.Bytes())
+}
@@ -0,0 +1,545 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"container/list"
+	"container/ring"
+	"math"
+	"math/bits"
+	"reflect"
+	"unsafe"
+)
+
+// EncodeOrderedMap encodes a map whose entries are written in the order of keys rather than Go's
+// random map iteration order. The result is an ordinary Map, with get providing the value for each
+// key and encode writing a single key/value pair.
+func EncodeOrderedMap[K any, V any](e *BufferEncoder, keyKind, valueKind Kind, keys []K, get func(K) V, encode func(*BufferEncoder, K, V)) *BufferEncoder {
+	e.Map(uint32(len(keys)), keyKind, valueKind)
+	for _, k := range keys {
+		encode(e, k, get(k))
+	}
+	return e
+}
+
+// checkDuplicateKey returns ErrDuplicateKey if k is already in seen, and otherwise adds it. Keys
+// that can't be compared, such as slices, can't be checked and are ignored.
+func checkDuplicateKey(seen map[any]struct{}, k any) error {
+	if k != nil && !reflect.TypeOf(k).Comparable() {
+		return nil
+	}
+	if _, ok := seen[k]; ok {
+		return ErrDuplicateKey
+	}
+	seen[k] = struct{}{}
+	return nil
+}
+
+// DecodeOrderedMap decodes a Map into parallel slices of keys and values that preserve the order
+// the entries were encoded in, with decode reading a single key/value pair.
+func DecodeOrderedMap[K any, V any](d *BufferDecoder, keyKind, valueKind Kind, decode func(*BufferDecoder) (K, V, error)) ([]K, []V, error) {
+	size, err := d.Map(keyKind, valueKind)
+	if err != nil {
+		return nil, nil, err
+	}
+	return decodeMapEntries(d, size, decode)
+}
+
+// DecodeMapEntries is like DecodeOrderedMap, but accepts a Map of any key and value kinds,
+// reading each key with kf and each value with vf.
+func DecodeMapEntries[K any, V any](d *BufferDecoder, kf func(*BufferDecoder) (K, error), vf func(*BufferDecoder) (V, error)) ([]K, []V, error) {
+	_, _, size, err := d.MapHeader()
+	if err != nil {
+		return nil, nil, err
+	}
+	return decodeMapEntries(d, size, func(d *BufferDecoder) (k K, v V, err error) {
+		if k, err = kf(d); err != nil {
+			return
+		}
+		v, err = vf(d)
+		return
+	})
+}
+
+func decodeMapEntries[K any, V any](d *BufferDecoder, size uint32, decode func(*BufferDecoder) (K, V, error)) ([]K, []V, error) {
+	// Every entry takes at least two bytes, which bounds the
+	// allocation for a corrupt or malicious size.
+	if err := d.checkElements(uint64(size), 2, ErrInvalidMap); err != nil {
+		return nil, nil, err
+	}
+	keys := make([]K, 0, size)
+	values := make([]V, 0, size)
+	var seen map[any]struct{}
+	if d.options.RejectDuplicateKeys {
+		seen = make(map[any]struct{}, size)
+	}
+	for i := uint32(0); i < size; i++ {
+		k, v, err := decode(d)
+		if err != nil {
+			return nil, nil, err
+		}
+		if seen != nil {
+			if err = checkDuplicateKey(seen, k); err != nil {
+				return nil, nil, err
+			}
+		}
+		keys = append(keys, k)
+		values = append(values, v)
+	}
+	return keys, values, nil
+}
+
+// DecodeMapInto decodes a Map into m, with decode reading a single key/value pair, and returns m.
+// If m is nil, a new map is made with room for every entry so that it never grows while it's
+// filled, with the size bounded by MaxElements and by the remaining bytes first. Like append, m
+// is otherwise filled in place, keeping any entries it already holds unless they're replaced.
+func DecodeMapInto[K comparable, V any](d *BufferDecoder, keyKind, valueKind Kind, m map[K]V, decode func(*BufferDecoder) (K, V, error)) (map[K]V, error) {
+	size, err := d.Map(keyKind, valueKind)
+	if err != nil {
+		return m, err
+	}
+	if err = d.checkElements(uint64(size), 2, ErrInvalidMap); err != nil {
+		return m, err
+	}
+	if m == nil {
+		m = make(map[K]V, size)
+	}
+	var seen map[K]struct{}
+	if d.options.RejectDuplicateKeys {
+		seen = make(map[K]struct{}, size)
+	}
+	for i := uint32(0); i < size; i++ {
+		k, v, err := decode(d)
+		if err != nil {
+			return m, err
+		}
+		if seen != nil {
+			if _, ok := seen[k]; ok {
+				return m, ErrDuplicateKey
+			}
+			seen[k] = struct{}{}
+		}
+		m[k] = v
+	}
+	return m, nil
+}
+
+func encodeSetHeader(b *Buffer, size int, kind Kind) {
+	b.Grow(2 + VarIntLen64)
+	b.b[b.offset] = SetRawKind
+	b.b[b.offset+1] = byte(kind)
+	b.offset += 2
+	writeUvarint(b, uint64(size))
+}
+
+func decodeSetHeader(b []byte, kind Kind) ([]byte, uint64, error) {
+	if len(b) > 2 && b[0] == SetRawKind && b[1] == byte(kind) {
+		if remaining, size, ok := readUvarint(b[2:]); ok {
+			return remaining, size, nil
+		}
+	}
+	return b, 0, ErrInvalidSet
+}
+
+// EncodeSet encodes the members of set, each of the given kind, without the empty values a
+// Map would need. Members are written in Go's random map iteration order, with encode writing
+// a single member.
+func EncodeSet[T comparable](e *BufferEncoder, kind Kind, set map[T]struct{}, encode func(*BufferEncoder, T)) *BufferEncoder {
+	encodeSetHeader((*Buffer)(e), len(set), kind)
+	for v := range set {
+		encode(e, v)
+	}
+	return e
+}
+
+// DecodeSet decodes a set encoded with EncodeSet, with decode reading a single member.
+// A set containing the same member twice is rejected with ErrInvalidSet.
+func DecodeSet[T comparable](d *BufferDecoder, kind Kind, decode func(*BufferDecoder) (T, error)) (map[T]struct{}, error) {
+	var size uint64
+	var err error
+	d.b, size, err = decodeSetHeader(d.b, kind)
+	err = d.step(err)
+	if err != nil {
+		return nil, err
+	}
+	if err = d.checkElements(size, 1, ErrInvalidSet); err != nil {
+		return nil, err
+	}
+	set := make(map[T]struct{}, size)
+	for i := uint64(0); i < size; i++ {
+		v, err := decode(d)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := set[v]; ok {
+			return nil, ErrInvalidSet
+		}
+		set[v] = struct{}{}
+	}
+	return set, nil
+}
+
+// DecodeSyncMap decodes the entries of a Map of any key and value kinds with Decoder.Any,
+// passing each to store, which can be the Store method of a sync.Map. Entries stored before
+// an error is returned are left in place.
+func DecodeSyncMap(d *BufferDecoder, store func(k, v any)) error {
+	_, _, size, err := d.MapHeader()
+	if err != nil {
+		return err
+	}
+	if err = d.checkElements(uint64(size), 2, ErrInvalidMap); err != nil {
+		return err
+	}
+	var seen map[any]struct{}
+	if d.options.RejectDuplicateKeys {
+		seen = make(map[any]struct{}, size)
+	}
+	for i := uint32(0); i < size; i++ {
+		k, err := d.Any()
+		if err != nil {
+			return err
+		}
+		if k != nil && !reflect.TypeOf(k).Comparable() {
+			return ErrInvalidMap
+		}
+		if seen != nil {
+			if err = checkDuplicateKey(seen, k); err != nil {
+				return err
+			}
+		}
+		v, err := d.Any()
+		if err != nil {
+			return err
+		}
+		store(k, v)
+	}
+	return nil
+}
+
+func encodeSparseSliceHeader(b *Buffer, kind Kind, present []byte, size int) {
+	b.Grow(2 + VarIntLen64 + len(present))
+	b.b[b.offset] = SparseSliceRawKind
+	b.b[b.offset+1] = byte(kind)
+	b.offset += 2
+	writeUvarint(b, uint64(size))
+	b.offset += copy(b.b[b.offset:], present)
+}
+
+func decodeSparseSliceHeader(b []byte, kind Kind) ([]byte, []byte, uint64, error) {
+	if len(b) > 2 && b[0] == SparseSliceRawKind && b[1] == byte(kind) {
+		// The bitmap takes a bit per element, so size is checked against the bytes left before
+		// it's rounded up to whole bytes, which would wrap for a size near math.MaxUint64
+		remaining, size, ok := readUvarint(b[2:])
+		if ok && size <= uint64(len(remaining))*8 && uint64(len(remaining)) >= (size+7)/8 {
+			return remaining[(size+7)/8:], remaining[:(size+7)/8], size, nil
+		}
+	}
+	return b, nil, 0, ErrInvalidSparseSlice
+}
+
+// sparseSliceHeader reads a SparseSlice header for DecodeSparseSlice and DecodeOptionalSlice,
+// returning its bitmap and the number of elements it has.
+func (d *BufferDecoder) sparseSliceHeader(kind Kind) ([]byte, uint64, error) {
+	var present []byte
+	var size uint64
+	var err error
+	d.b, present, size, err = decodeSparseSliceHeader(d.b, kind)
+	err = d.step(err)
+	if err != nil {
+		return nil, 0, err
+	}
+	if d.options.MaxElements > 0 && size > uint64(d.options.MaxElements) {
+		return nil, 0, ErrTooManyElements
+	}
+	// The bitmap takes a bit per element, which bounds the allocation, and every element it marks
+	// as present takes at least a byte
+	set := 0
+	for _, c := range present {
+		set += bits.OnesCount8(c)
+	}
+	if err = d.checkElements(uint64(set), 1, ErrInvalidSparseSlice); err != nil {
+		return nil, 0, err
+	}
+	return present, size, nil
+}
+
+// EncodeSparseSlice encodes values as a bitmap of which elements aren't the zero value, followed by
+// only those elements, each of the given kind and written by encode. This is far smaller than a
+// Slice when most elements are zero.
+func EncodeSparseSlice[T comparable](e *BufferEncoder, kind Kind, values []T, encode func(*BufferEncoder, T)) *BufferEncoder {
+	var zero T
+	present := make([]byte, (len(values)+7)/8)
+	for i, v := range values {
+		if v != zero {
+			present[i/8] |= 1 << (i % 8)
+		}
+	}
+	encodeSparseSliceHeader((*Buffer)(e), kind, present, len(values))
+	for _, v := range values {
+		if v != zero {
+			encode(e, v)
+		}
+	}
+	return e
+}
+
+// DecodeSparseSlice decodes a slice encoded with EncodeSparseSlice, with decode reading a single
+// element and every element missing from the bitmap left as the zero value.
+func DecodeSparseSlice[T comparable](d *BufferDecoder, kind Kind, decode func(*BufferDecoder) (T, error)) ([]T, error) {
+	present, size, err := d.sparseSliceHeader(kind)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]T, size)
+	for i := range values {
+		if present[i/8]&(1<<(i%8)) == 0 {
+			continue
+		}
+		if values[i], err = decode(d); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// EncodeOptionalSlice encodes values as a SparseSlice whose bitmap marks the non-nil elements,
+// followed by only those elements, each of the given kind and written by encode. This takes a bit
+// for each nil element rather than the byte of a Nil.
+func EncodeOptionalSlice[T any](e *BufferEncoder, kind Kind, values []*T, encode func(*BufferEncoder, T)) *BufferEncoder {
+	present := make([]byte, (len(values)+7)/8)
+	for i, v := range values {
+		if v != nil {
+			present[i/8] |= 1 << (i % 8)
+		}
+	}
+	encodeSparseSliceHeader((*Buffer)(e), kind, present, len(values))
+	for _, v := range values {
+		if v != nil {
+			encode(e, *v)
+		}
+	}
+	return e
+}
+
+// DecodeOptionalSlice decodes a slice encoded with EncodeOptionalSlice, with decode reading a single
+// element and every element missing from the bitmap left as nil.
+func DecodeOptionalSlice[T any](d *BufferDecoder, kind Kind, decode func(*BufferDecoder) (T, error)) ([]*T, error) {
+	present, size, err := d.sparseSliceHeader(kind)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]*T, size)
+	for i := range values {
+		if present[i/8]&(1<<(i%8)) == 0 {
+			continue
+		}
+		v, err := decode(d)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = &v
+	}
+	return values, nil
+}
+
+func encodeRLESliceHeader(b *Buffer, kind Kind, size, runs int) {
+	b.Grow(2 + 2*VarIntLen64)
+	b.b[b.offset] = RLESliceRawKind
+	b.b[b.offset+1] = byte(kind)
+	b.offset += 2
+	writeUvarint(b, uint64(size))
+	writeUvarint(b, uint64(runs))
+}
+
+func decodeRLESliceHeader(b []byte, kind Kind) ([]byte, uint64, uint64, error) {
+	if len(b) > 3 && b[0] == RLESliceRawKind && b[1] == byte(kind) {
+		remaining, size, ok := readUvarint(b[2:])
+		if ok {
+			var runs uint64
+			if remaining, runs, ok = readUvarint(remaining); ok && runs <= size {
+				return remaining, size, runs, nil
+			}
+		}
+	}
+	return b, 0, 0, ErrInvalidRLESlice
+}
+
+// EncodeRLESlice encodes values as runs of identical elements, each made up of an element of the
+// given kind written by encode followed by the length of the run as a Uint32. This is far smaller
+// than a Slice when values has long runs, like quantized readings or palette indices.
+func EncodeRLESlice[T comparable](e *BufferEncoder, kind Kind, values []T, encode func(*BufferEncoder, T)) *BufferEncoder {
+	runs := 0
+	for i := range values {
+		if i == 0 || values[i] != values[i-1] {
+			runs++
+		}
+	}
+	encodeRLESliceHeader((*Buffer)(e), kind, len(values), runs)
+	for i := 0; i < len(values); {
+		j := i + 1
+		for j < len(values) && values[j] == values[i] && uint64(j-i) < math.MaxUint32 {
+			j++
+		}
+		encode(e, values[i])
+		e.Uint32(uint32(j - i))
+		i = j
+	}
+	return e
+}
+
+// MaxRLESliceSize is the largest slice, in bytes of its elements, that DecodeRLESlice expands
+// to when MaxElements isn't set, guarding against a few bytes of runs that expand to a huge slice.
+const MaxRLESliceSize = 64 << 20
+
+// maxRLESliceElements returns the number of elements of T that fit in MaxRLESliceSize bytes.
+func maxRLESliceElements[T any]() uint64 {
+	var zero T
+	return MaxRLESliceSize / uint64(max(unsafe.Sizeof(zero), 1))
+}
+
+// DecodeRLESlice decodes a slice encoded with EncodeRLESlice, with decode reading a single element.
+//
+// Unlike other collections, the decoded length isn't bounded by the size of the buffer, so it's
+// limited to MaxElements if it's set, and otherwise to as many elements of T as fit in
+// MaxRLESliceSize bytes.
+func DecodeRLESlice[T comparable](d *BufferDecoder, kind Kind, decode func(*BufferDecoder) (T, error)) ([]T, error) {
+	var size, runs uint64
+	var err error
+	d.b, size, runs, err = decodeRLESliceHeader(d.b, kind)
+	err = d.step(err)
+	if err != nil {
+		return nil, err
+	}
+	if d.options.MaxElements > 0 && size > uint64(d.options.MaxElements) ||
+		d.options.MaxElements <= 0 && size > maxRLESliceElements[T]() {
+		return nil, ErrTooManyElements
+	}
+	// Every run takes at least a byte for its element and two for its length
+	if err = d.checkElements(runs, 3, ErrInvalidRLESlice); err != nil {
+		return nil, err
+	}
+	// The runs must add up to size, but it's only trusted for
+	// the allocation once it has been bounded by MaxElements.
+	var values []T
+	if d.options.MaxElements > 0 {
+		values = make([]T, 0, size)
+	}
+	for i := uint64(0); i < runs; i++ {
+		v, err := decode(d)
+		if err != nil {
+			return nil, err
+		}
+		n, err := d.Uint32()
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 || uint64(n) > size-uint64(len(values)) {
+			return nil, ErrInvalidRLESlice
+		}
+		for j := uint32(0); j < n; j++ {
+			values = append(values, v)
+		}
+	}
+	if uint64(len(values)) != size {
+		return nil, ErrInvalidRLESlice
+	}
+	return values, nil
+}
+
+// EncodeList encodes the elements of l from front to back as a Slice of the given kind, with
+// encode writing each element, so it's decoded just like a Slice encoded from a Go slice.
+func EncodeList(e *BufferEncoder, kind Kind, l *list.List, encode func(*BufferEncoder, any)) *BufferEncoder {
+	e.Slice(uint32(l.Len()), kind)
+	for elem := l.Front(); elem != nil; elem = elem.Next() {
+		encode(e, elem.Value)
+	}
+	return e
+}
+
+// DecodeList decodes a Slice of the given kind into a new list, with decode reading each element.
+func DecodeList(d *BufferDecoder, kind Kind, decode func(*BufferDecoder) (any, error)) (*list.List, error) {
+	size, err := d.Slice(kind)
+	if err != nil {
+		return nil, err
+	}
+	if err = d.checkElements(uint64(size), 1, ErrInvalidSlice); err != nil {
+		return nil, err
+	}
+	l := list.New()
+	for i := uint32(0); i < size; i++ {
+		v, err := decode(d)
+		if err != nil {
+			return nil, err
+		}
+		l.PushBack(v)
+	}
+	return l, nil
+}
+
+// EncodeRing encodes the elements of r, starting at r and moving forwards, as a Slice of the given
+// kind, with encode writing each element. A nil r is encoded as an empty Slice.
+func EncodeRing(e *BufferEncoder, kind Kind, r *ring.Ring, encode func(*BufferEncoder, any)) *BufferEncoder {
+	e.Slice(uint32(r.Len()), kind)
+	r.Do(func(v any) {
+		encode(e, v)
+	})
+	return e
+}
+
+// DecodeRing decodes a Slice of the given kind into a new ring positioned at the first element,
+// with decode reading each element. An empty Slice decodes as a nil ring.
+func DecodeRing(d *BufferDecoder, kind Kind, decode func(*BufferDecoder) (any, error)) (*ring.Ring, error) {
+	size, err := d.Slice(kind)
+	if err != nil {
+		return nil, err
+	}
+	if err = d.checkElements(uint64(size), 1, ErrInvalidSlice); err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		return nil, nil
+	}
+	r := ring.New(int(size))
+	for i := uint32(0); i < size; i++ {
+		if r.Value, err = decode(d); err != nil {
+			return nil, err
+		}
+		r = r.Next()
+	}
+	return r, nil
+}
+
+// DecodeSliceToChan decodes a Slice of the given kind, sending each element on ch as soon as
+// decode has read it so that the whole slice is never held in memory. It stops at the first
+// error, and never closes ch since the caller owns it.
+func DecodeSliceToChan[T any](d *BufferDecoder, kind Kind, ch chan<- T, decode func(*BufferDecoder) (T, error)) error {
+	size, err := d.Slice(kind)
+	if err != nil {
+		return err
+	}
+	if err = d.checkElements(uint64(size), 1, ErrInvalidSlice); err != nil {
+		return err
+	}
+	for i := uint32(0); i < size; i++ {
+		v, err := decode(d)
+		if err != nil {
+			return err
+		}
+		ch <- v
+	}
+	return nil
+}
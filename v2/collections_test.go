@@ -0,0 +1,584 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"container/list"
+	"container/ring"
+	"io"
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestOrderedMap(t *testing.T) {
+	t.Parallel()
+
+	m := map[string]uint32{"c": 3, "a": 1, "b": 2, "d": 4}
+	keys := []string{"d", "a", "c", "b"}
+
+	p := NewBuffer()
+	EncodeOrderedMap(Encoder(p), StringKind, Uint32Kind, keys, func(k string) uint32 {
+		return m[k]
+	}, func(e *BufferEncoder, k string, v uint32) {
+		e.String(k).Uint32(v)
+	})
+
+	decode := func(d *BufferDecoder) (k string, v uint32, err error) {
+		if k, err = d.String(); err != nil {
+			return
+		}
+		v, err = d.Uint32()
+		return
+	}
+
+	d := Decoder(p.Bytes())
+	dk, dv, err := DecodeOrderedMap(d, StringKind, Uint32Kind, decode)
+	assert.NoError(t, err)
+	assert.Equal(t, keys, dk)
+	assert.Equal(t, []uint32{4, 1, 3, 2}, dv)
+	assert.Equal(t, 0, d.Remaining())
+
+	d = Decoder(p.Bytes())
+	_, _, err = DecodeOrderedMap(d, StringKind, Uint64Kind, decode)
+	assert.ErrorIs(t, err, ErrInvalidMap)
+
+	d = Decoder((p.Bytes())[:len(p.Bytes())-1])
+	_, _, err = DecodeOrderedMap(d, StringKind, Uint32Kind, decode)
+	assert.ErrorIs(t, err, ErrInvalidUint32)
+
+	p.Reset()
+	Encoder(p).Map(1<<20, StringKind, Uint32Kind)
+	_, _, err = DecodeOrderedMap(Decoder(p.Bytes()), StringKind, Uint32Kind, decode)
+	assert.ErrorIs(t, err, ErrInvalidMap)
+
+	_, _, err = DecodeOrderedMap(DecoderWithOptions(p.Bytes(), DecoderOptions{MaxElements: 1 << 10}), StringKind, Uint32Kind, decode)
+	assert.ErrorIs(t, err, ErrTooManyElements)
+}
+
+func TestSet(t *testing.T) {
+	t.Parallel()
+
+	set := map[string]struct{}{"a": {}, "b": {}, "c": {}}
+
+	p := NewBuffer()
+	EncodeSet(Encoder(p), StringKind, set, func(e *BufferEncoder, v string) {
+		e.String(v)
+	})
+	Encoder(p).Uint8(8)
+
+	decode := func(d *BufferDecoder) (string, error) {
+		return d.String()
+	}
+
+	d := Decoder(p.Bytes())
+	value, err := DecodeSet(d, StringKind, decode)
+	assert.NoError(t, err)
+	assert.Equal(t, set, value)
+
+	u, err := d.Uint8()
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(8), u)
+
+	d = Decoder(p.Bytes())
+	assert.NoError(t, d.Skip())
+	_, err = d.Uint8()
+	assert.NoError(t, err)
+
+	_, err = DecodeSet(Decoder(p.Bytes()), StringVarKind, func(d *BufferDecoder) (string, error) {
+		return d.StringVar()
+	})
+	assert.ErrorIs(t, err, ErrInvalidSet)
+
+	_, err = DecodeSet(DecoderWithOptions(p.Bytes(), DecoderOptions{MaxElements: 2}), StringKind, decode)
+	assert.ErrorIs(t, err, ErrTooManyElements)
+
+	p.Reset()
+	EncodeSet(Encoder(p), Uint32Kind, map[uint32]struct{}{1: {}, 2: {}}, func(e *BufferEncoder, v uint32) {
+		e.Uint32(1)
+	})
+	_, err = DecodeSet(Decoder(p.Bytes()), Uint32Kind, func(d *BufferDecoder) (uint32, error) {
+		return d.Uint32()
+	})
+	assert.ErrorIs(t, err, ErrInvalidSet)
+
+	p.Reset()
+	encodeSetHeader(p, 1<<20, Uint32Kind)
+	_, err = DecodeSet(Decoder(p.Bytes()), Uint32Kind, func(d *BufferDecoder) (uint32, error) {
+		return d.Uint32()
+	})
+	assert.ErrorIs(t, err, ErrInvalidSet)
+}
+
+func TestSyncMap(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).Map(3, StringKind, AnyKind).String("a").Uint32(1).String("b").Slice(1, BoolKind).Bool(true).String("c").Nil()
+
+	var m sync.Map
+	assert.NoError(t, DecodeSyncMap(Decoder(p.Bytes()), m.Store))
+
+	expected := map[any]any{"a": uint32(1), "b": []any{true}, "c": nil}
+	count := 0
+	m.Range(func(k, v any) bool {
+		assert.Equal(t, expected[k], v)
+		count++
+		return true
+	})
+	assert.Equal(t, len(expected), count)
+
+	err := DecodeSyncMap(DecoderWithOptions(p.Bytes(), DecoderOptions{MaxElements: 2}), m.Store)
+	assert.ErrorIs(t, err, ErrTooManyElements)
+
+	err = DecodeSyncMap(Decoder(p.Bytes()[:len(p.Bytes())-4]), m.Store)
+	assert.Error(t, err)
+
+	p.Reset()
+	Encoder(p).Map(1, BytesKind, BoolKind).Slice(0, BoolKind).Bool(true)
+	assert.ErrorIs(t, DecodeSyncMap(Decoder(p.Bytes()), m.Store), ErrInvalidMap)
+}
+
+func TestSparseSlice(t *testing.T) {
+	t.Parallel()
+
+	values := make([]uint32, 100)
+	values[3] = 3
+	values[64] = 64
+	values[99] = 99
+
+	p := NewBuffer()
+	EncodeSparseSlice(Encoder(p), Uint32Kind, values, func(e *BufferEncoder, v uint32) {
+		e.Uint32(v)
+	})
+	Encoder(p).Bool(true)
+	assert.Less(t, p.Len(), 30)
+
+	decode := func(d *BufferDecoder) (uint32, error) {
+		return d.Uint32()
+	}
+
+	d := Decoder(p.Bytes())
+	value, err := DecodeSparseSlice(d, Uint32Kind, decode)
+	assert.NoError(t, err)
+	assert.Equal(t, values, value)
+	b, err := d.Bool()
+	assert.NoError(t, err)
+	assert.True(t, b)
+
+	d = Decoder(p.Bytes())
+	assert.NoError(t, d.Skip())
+	assert.Equal(t, 2, d.Remaining())
+
+	_, err = DecodeSparseSlice(Decoder(p.Bytes()), Uint64Kind, func(d *BufferDecoder) (uint64, error) {
+		return d.Uint64()
+	})
+	assert.ErrorIs(t, err, ErrInvalidSparseSlice)
+
+	_, err = DecodeSparseSlice(DecoderWithOptions(p.Bytes(), DecoderOptions{MaxElements: 99}), Uint32Kind, decode)
+	assert.ErrorIs(t, err, ErrTooManyElements)
+
+	_, err = DecodeSparseSlice(Decoder(p.Bytes()[:len(p.Bytes())-4]), Uint32Kind, decode)
+	assert.ErrorIs(t, err, ErrInvalidUint32)
+
+	p.Reset()
+	EncodeSparseSlice(Encoder(p), StringKind, []string{}, func(e *BufferEncoder, v string) {
+		e.String(v)
+	})
+	strings, err := DecodeSparseSlice(Decoder(p.Bytes()), StringKind, func(d *BufferDecoder) (string, error) {
+		return d.String()
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, strings)
+
+	p.Reset()
+	encodeSparseSliceHeader(p, Uint32Kind, nil, 1<<40)
+	_, err = DecodeSparseSlice(Decoder(p.Bytes()), Uint32Kind, decode)
+	assert.ErrorIs(t, err, ErrInvalidSparseSlice)
+
+	// A size whose bitmap length would wrap around to zero bytes
+	wrap := []byte{SparseSliceRawKind, Uint32RawKind, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}
+	_, err = DecodeSparseSlice(Decoder(wrap), Uint32Kind, decode)
+	assert.ErrorIs(t, err, ErrInvalidSparseSlice)
+	assert.Error(t, Decoder(wrap).Skip())
+}
+
+func TestOptionalSlice(t *testing.T) {
+	t.Parallel()
+
+	ptr := func(v uint32) *uint32 { return &v }
+	values := []*uint32{nil, ptr(0), ptr(7), nil, nil, nil, nil, nil, nil, ptr(1 << 20), nil}
+	encode := func(e *BufferEncoder, v uint32) { e.Uint32(v) }
+	decode := func(d *BufferDecoder) (uint32, error) { return d.Uint32() }
+
+	p := NewBuffer()
+	EncodeOptionalSlice(Encoder(p), Uint32Kind, values, encode)
+	Encoder(p).Bool(true)
+
+	// A bit per element instead of a Nil for each of the eight nil elements
+	withNil := NewBuffer()
+	Encoder(withNil).Slice(uint32(len(values)), Uint32Kind)
+	for _, v := range values {
+		if v == nil {
+			Encoder(withNil).Nil()
+		} else {
+			Encoder(withNil).Uint32(*v)
+		}
+	}
+	Encoder(withNil).Bool(true)
+	assert.Less(t, p.Len(), withNil.Len())
+
+	d := Decoder(p.Bytes())
+	decoded, err := DecodeOptionalSlice(d, Uint32Kind, decode)
+	assert.NoError(t, err)
+	assert.Equal(t, values, decoded)
+	b, err := d.Bool()
+	assert.NoError(t, err)
+	assert.True(t, b)
+
+	// The wire form is a SparseSlice, so it can be skipped or read back with zero values for nil
+	d = Decoder(p.Bytes())
+	assert.NoError(t, d.Skip())
+	assert.Equal(t, 2, d.Remaining())
+	sparse, err := DecodeSparseSlice(Decoder(p.Bytes()), Uint32Kind, decode)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{0, 0, 7, 0, 0, 0, 0, 0, 0, 1 << 20, 0}, sparse)
+
+	_, err = DecodeOptionalSlice(Decoder(p.Bytes()), StringKind, func(d *BufferDecoder) (string, error) {
+		return d.String()
+	})
+	assert.ErrorIs(t, err, ErrInvalidSparseSlice)
+
+	_, err = DecodeOptionalSlice(DecoderWithOptions(p.Bytes(), DecoderOptions{MaxElements: len(values) - 1}), Uint32Kind, decode)
+	assert.ErrorIs(t, err, ErrTooManyElements)
+
+	_, err = DecodeOptionalSlice(Decoder(p.Bytes()[:p.Len()-6]), Uint32Kind, decode)
+	assert.ErrorIs(t, err, ErrInvalidUint32)
+
+	p.Reset()
+	EncodeOptionalSlice[uint32](Encoder(p), Uint32Kind, nil, encode)
+	decoded, err = DecodeOptionalSlice(Decoder(p.Bytes()), Uint32Kind, decode)
+	assert.NoError(t, err)
+	assert.Empty(t, decoded)
+
+	p.Reset()
+	encodeSparseSliceHeader(p, Uint32Kind, nil, 1<<40)
+	_, err = DecodeOptionalSlice(Decoder(p.Bytes()), Uint32Kind, decode)
+	assert.ErrorIs(t, err, ErrInvalidSparseSlice)
+
+	// A size whose bitmap length would wrap around to zero bytes
+	wrap := []byte{SparseSliceRawKind, Uint32RawKind, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}
+	_, err = DecodeOptionalSlice(Decoder(wrap), Uint32Kind, decode)
+	assert.ErrorIs(t, err, ErrInvalidSparseSlice)
+}
+
+func TestDuplicateKeys(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).Map(2, StringKind, Uint32Kind).String("a").Uint32(1).String("a").Uint32(2)
+	reject := DecoderOptions{RejectDuplicateKeys: true}
+
+	decode := func(d *BufferDecoder) (k string, v uint32, err error) {
+		if k, err = d.String(); err != nil {
+			return
+		}
+		v, err = d.Uint32()
+		return
+	}
+
+	keys, values, err := DecodeOrderedMap(Decoder(p.Bytes()), StringKind, Uint32Kind, decode)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "a"}, keys)
+	assert.Equal(t, []uint32{1, 2}, values)
+	_, _, err = DecodeOrderedMap(DecoderWithOptions(p.Bytes(), reject), StringKind, Uint32Kind, decode)
+	assert.ErrorIs(t, err, ErrDuplicateKey)
+
+	v, err := Decoder(p.Bytes()).Any()
+	assert.NoError(t, err)
+	assert.Equal(t, map[any]any{"a": uint32(2)}, v)
+	_, err = DecoderWithOptions(p.Bytes(), reject).Any()
+	assert.ErrorIs(t, err, ErrDuplicateKey)
+
+	var m map[string]uint32
+	assert.NoError(t, Unmarshal(p.Bytes(), &m))
+	assert.Equal(t, map[string]uint32{"a": 2}, m)
+	m = nil
+	assert.ErrorIs(t, MarshalOptions{}.Decode(DecoderWithOptions(p.Bytes(), reject), &m), ErrDuplicateKey)
+
+	var s sync.Map
+	assert.NoError(t, DecodeSyncMap(Decoder(p.Bytes()), s.Store))
+	assert.ErrorIs(t, DecodeSyncMap(DecoderWithOptions(p.Bytes(), reject), s.Store), ErrDuplicateKey)
+}
+
+func TestMapEntries(t *testing.T) {
+	t.Parallel()
+
+	keys := []string{"zebra", "apple", "mango", "kiwi"}
+	p := NewBuffer()
+	e := Encoder(p).Map(uint32(len(keys)), StringKind, Uint32Kind)
+	for i, k := range keys {
+		e.String(k).Uint32(uint32(i))
+	}
+
+	d := Decoder(p.Bytes())
+	dk, dv, err := DecodeMapEntries(d, (*BufferDecoder).String, (*BufferDecoder).Uint32)
+	assert.NoError(t, err)
+	assert.Equal(t, keys, dk)
+	assert.Equal(t, []uint32{0, 1, 2, 3}, dv)
+	assert.Equal(t, 0, d.Remaining())
+
+	_, _, err = DecodeMapEntries(Decoder(p.Bytes()), (*BufferDecoder).String, (*BufferDecoder).Uint64)
+	assert.ErrorIs(t, err, ErrInvalidUint64)
+
+	_, _, err = DecodeMapEntries(Decoder(p.Bytes()[:len(p.Bytes())-1]), (*BufferDecoder).String, (*BufferDecoder).Uint32)
+	assert.ErrorIs(t, err, ErrInvalidUint32)
+
+	p.Reset()
+	Encoder(p).String("not a map")
+	_, _, err = DecodeMapEntries(Decoder(p.Bytes()), (*BufferDecoder).String, (*BufferDecoder).Uint32)
+	assert.ErrorIs(t, err, ErrInvalidMap)
+}
+
+func TestMapInto(t *testing.T) {
+	t.Parallel()
+
+	keys := []string{"zebra", "apple", "mango", "kiwi"}
+	p := NewBuffer()
+	e := Encoder(p).Map(uint32(len(keys)), StringKind, Uint32Kind)
+	for i, k := range keys {
+		e.String(k).Uint32(uint32(i))
+	}
+	decode := func(d *BufferDecoder) (k string, v uint32, err error) {
+		if k, err = d.String(); err != nil {
+			return
+		}
+		v, err = d.Uint32()
+		return
+	}
+
+	d := Decoder(p.Bytes())
+	m, err := DecodeMapInto(d, StringKind, Uint32Kind, nil, decode)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]uint32{"zebra": 0, "apple": 1, "mango": 2, "kiwi": 3}, m)
+	assert.Equal(t, 0, d.Remaining())
+
+	// An existing map is filled in place
+	existing := map[string]uint32{"apple": 10, "pear": 11}
+	m, err = DecodeMapInto(Decoder(p.Bytes()), StringKind, Uint32Kind, existing, decode)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]uint32{"zebra": 0, "apple": 1, "mango": 2, "kiwi": 3, "pear": 11}, existing)
+	m["plum"] = 12
+	assert.Contains(t, existing, "plum")
+
+	_, err = DecodeMapInto(DecoderWithOptions(p.Bytes(), DecoderOptions{MaxElements: len(keys) - 1}), StringKind, Uint32Kind, nil, decode)
+	assert.ErrorIs(t, err, ErrTooManyElements)
+
+	// A size larger than the remaining bytes could hold isn't allocated
+	p.Reset()
+	Encoder(p).Map(math.MaxUint32, StringKind, Uint32Kind)
+	_, err = DecodeMapInto(Decoder(p.Bytes()), StringKind, Uint32Kind, nil, decode)
+	assert.ErrorIs(t, err, ErrInvalidMap)
+
+	p.Reset()
+	Encoder(p).Map(2, StringKind, Uint32Kind).String("apple").Uint32(1).String("apple").Uint32(2)
+	m, err = DecodeMapInto(Decoder(p.Bytes()), StringKind, Uint32Kind, nil, decode)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]uint32{"apple": 2}, m)
+	_, err = DecodeMapInto(DecoderWithOptions(p.Bytes(), DecoderOptions{RejectDuplicateKeys: true}), StringKind, Uint32Kind, nil, decode)
+	assert.ErrorIs(t, err, ErrDuplicateKey)
+
+	// Keys already in the map aren't duplicates
+	p.Reset()
+	Encoder(p).Map(1, StringKind, Uint32Kind).String("apple").Uint32(1)
+	m, err = DecodeMapInto(DecoderWithOptions(p.Bytes(), DecoderOptions{RejectDuplicateKeys: true}), StringKind, Uint32Kind, map[string]uint32{"apple": 0}, decode)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]uint32{"apple": 1}, m)
+
+	_, err = DecodeMapInto(Decoder(p.Bytes()), StringKind, Uint64Kind, nil, func(d *BufferDecoder) (string, uint64, error) { return "", 0, nil })
+	assert.ErrorIs(t, err, ErrInvalidMap)
+}
+
+func TestRLESlice(t *testing.T) {
+	t.Parallel()
+
+	encodeUint8 := func(e *BufferEncoder, v uint8) { e.Uint8(v) }
+	encodeString := func(e *BufferEncoder, v string) { e.String(v) }
+
+	values := make([]uint8, 10000)
+	for i := range values {
+		values[i] = 7
+	}
+
+	p := NewBuffer()
+	EncodeRLESlice(Encoder(p), Uint8Kind, values, encodeUint8)
+	assert.Less(t, p.Len(), 16)
+
+	d := Decoder(p.Bytes())
+	decoded, err := DecodeRLESlice(d, Uint8Kind, (*BufferDecoder).Uint8)
+	assert.NoError(t, err)
+	assert.Equal(t, values, decoded)
+	assert.Equal(t, 0, d.Remaining())
+
+	mixed := []string{"a", "a", "b", "a", "c", "c", "c"}
+	for _, v := range [][]string{mixed, {"only"}, {}} {
+		p.Reset()
+		EncodeRLESlice(Encoder(p), StringKind, v, encodeString)
+		Encoder(p).Bool(true)
+
+		d = Decoder(p.Bytes())
+		decodedStrings, err := DecodeRLESlice(d, StringKind, (*BufferDecoder).String)
+		assert.NoError(t, err)
+		assert.Equal(t, len(v), len(decodedStrings))
+		if len(v) > 0 {
+			assert.Equal(t, v, decodedStrings)
+		}
+
+		d = Decoder(p.Bytes())
+		assert.NoError(t, d.Skip())
+		assert.Equal(t, 2, d.Remaining())
+	}
+
+	p.Reset()
+	EncodeRLESlice(Encoder(p), Uint8Kind, values, encodeUint8)
+	_, err = DecodeRLESlice(DecoderWithOptions(p.Bytes(), DecoderOptions{MaxElements: 1000}), Uint8Kind, (*BufferDecoder).Uint8)
+	assert.ErrorIs(t, err, ErrTooManyElements)
+
+	_, err = DecodeRLESlice(Decoder(p.Bytes()), Uint16Kind, (*BufferDecoder).Uint16)
+	assert.ErrorIs(t, err, ErrInvalidRLESlice)
+
+	// Runs that don't add up to the declared length
+	p.Reset()
+	encodeRLESliceHeader(p, Uint8Kind, 3, 1)
+	Encoder(p).Uint8(1).Uint32(2)
+	_, err = DecodeRLESlice(Decoder(p.Bytes()), Uint8Kind, (*BufferDecoder).Uint8)
+	assert.ErrorIs(t, err, ErrInvalidRLESlice)
+
+	p.Reset()
+	encodeRLESliceHeader(p, Uint8Kind, 3, 1)
+	Encoder(p).Uint8(1).Uint32(4)
+	_, err = DecodeRLESlice(Decoder(p.Bytes()), Uint8Kind, (*BufferDecoder).Uint8)
+	assert.ErrorIs(t, err, ErrInvalidRLESlice)
+
+	// A few bytes that would expand to a huge slice are limited even without MaxElements
+	p.Reset()
+	encodeRLESliceHeader(p, Uint8Kind, MaxRLESliceSize+1, 1)
+	Encoder(p).Uint8(1).Uint32(MaxRLESliceSize + 1)
+	_, err = DecodeRLESlice(Decoder(p.Bytes()), Uint8Kind, (*BufferDecoder).Uint8)
+	assert.ErrorIs(t, err, ErrTooManyElements)
+
+	// The limit is in bytes, so it allows fewer elements that are larger
+	p.Reset()
+	encodeRLESliceHeader(p, StringKind, MaxRLESliceSize/16+1, 1)
+	Encoder(p).String("a").Uint32(MaxRLESliceSize/16 + 1)
+	_, err = DecodeRLESlice(Decoder(p.Bytes()), StringKind, (*BufferDecoder).String)
+	assert.ErrorIs(t, err, ErrTooManyElements)
+
+	// A number of runs that wraps around to zero when doubled can't be skipped
+	half := []byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01}
+	wrap := append(append([]byte{RLESliceRawKind, Uint8RawKind}, half...), half...)
+	assert.ErrorIs(t, Decoder(wrap).Skip(), io.ErrUnexpectedEOF)
+}
+
+func TestListRing(t *testing.T) {
+	t.Parallel()
+
+	encode := func(e *BufferEncoder, v any) { e.String(v.(string)) }
+	decode := func(d *BufferDecoder) (any, error) { return d.String() }
+	values := []string{"a", "b", "c"}
+
+	l := list.New()
+	for _, v := range values {
+		l.PushBack(v)
+	}
+	p := NewBuffer()
+	EncodeList(Encoder(p), StringKind, l, encode)
+
+	// The wire form is the same as a plain slice
+	expected := NewBuffer()
+	Encoder(expected).Slice(3, StringKind).String("a").String("b").String("c")
+	assert.Equal(t, expected.Bytes(), p.Bytes())
+
+	decoded, err := DecodeList(Decoder(p.Bytes()), StringKind, decode)
+	assert.NoError(t, err)
+	var got []string
+	for elem := decoded.Front(); elem != nil; elem = elem.Next() {
+		got = append(got, elem.Value.(string))
+	}
+	assert.Equal(t, values, got)
+
+	r := ring.New(3)
+	for _, v := range values {
+		r.Value = v
+		r = r.Next()
+	}
+	p.Reset()
+	EncodeRing(Encoder(p), StringKind, r.Next(), encode)
+	decodedRing, err := DecodeRing(Decoder(p.Bytes()), StringKind, decode)
+	assert.NoError(t, err)
+	got = got[:0]
+	decodedRing.Do(func(v any) { got = append(got, v.(string)) })
+	assert.Equal(t, []string{"b", "c", "a"}, got)
+
+	p.Reset()
+	EncodeRing(Encoder(p), StringKind, nil, encode)
+	decodedRing, err = DecodeRing(Decoder(p.Bytes()), StringKind, decode)
+	assert.NoError(t, err)
+	assert.Nil(t, decodedRing)
+
+	_, err = DecodeList(Decoder(expected.Bytes()[:expected.Len()-1]), StringKind, decode)
+	assert.ErrorIs(t, err, ErrInvalidString)
+	_, err = DecodeRing(Decoder(expected.Bytes()), BoolKind, decode)
+	assert.ErrorIs(t, err, ErrInvalidSlice)
+}
+
+func TestSliceToChan(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).Slice(4, Uint32Kind).Uint32(1).Uint32(2).Uint32(3).Uint32(4)
+	decode := func(d *BufferDecoder) (uint32, error) { return d.Uint32() }
+
+	ch := make(chan uint32, 1)
+	var got []uint32
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for v := range ch {
+			got = append(got, v)
+		}
+	}()
+	err := DecodeSliceToChan(Decoder(p.Bytes()), Uint32Kind, ch, decode)
+	assert.NoError(t, err)
+	close(ch)
+	wg.Wait()
+	assert.Equal(t, []uint32{1, 2, 3, 4}, got)
+
+	// Elements decoded before an error are still sent, and the channel is left open
+	ch = make(chan uint32, 4)
+	err = DecodeSliceToChan(Decoder(p.Bytes()[:p.Len()-1]), Uint32Kind, ch, decode)
+	assert.ErrorIs(t, err, ErrInvalidUint32)
+	assert.Equal(t, 3, len(ch))
+	ch <- 5
+	assert.Equal(t, 4, len(ch))
+
+	err = DecodeSliceToChan(Decoder(p.Bytes()), BoolKind, ch, decode)
+	assert.ErrorIs(t, err, ErrInvalidSlice)
+}
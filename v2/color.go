@@ -0,0 +1,92 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"image/color"
+)
+
+const (
+	// colorSize is the kind, the model and the four channels.
+	colorSize = 6
+
+	colorModelRGBA  = byte(0)
+	colorModelNRGBA = byte(1)
+)
+
+func encodeColor(b *Buffer, model, r, g, bl, a byte) {
+	b.Grow(colorSize)
+	b.b[b.offset] = ColorRawKind
+	b.b[b.offset+1] = model
+	b.b[b.offset+2] = r
+	b.b[b.offset+3] = g
+	b.b[b.offset+4] = bl
+	b.b[b.offset+5] = a
+	b.offset += colorSize
+}
+
+// decodeColor returns a color.RGBA or a color.NRGBA depending on which was encoded.
+func decodeColor(b []byte) ([]byte, color.Color, error) {
+	if len(b) >= colorSize && b[0] == ColorRawKind {
+		switch b[1] {
+		case colorModelRGBA:
+			// Premultiplied channels can't exceed alpha
+			if b[2] <= b[5] && b[3] <= b[5] && b[4] <= b[5] {
+				return b[colorSize:], color.RGBA{R: b[2], G: b[3], B: b[4], A: b[5]}, nil
+			}
+		case colorModelNRGBA:
+			return b[colorSize:], color.NRGBA{R: b[2], G: b[3], B: b[4], A: b[5]}, nil
+		}
+	}
+	return b, nil, ErrInvalidColor
+}
+
+// ColorRGBA encodes an alpha-premultiplied color.
+func (e *BufferEncoder) ColorRGBA(value color.RGBA) *BufferEncoder {
+	encodeColor((*Buffer)(e), colorModelRGBA, value.R, value.G, value.B, value.A)
+	return e
+}
+
+// ColorNRGBA encodes a non-alpha-premultiplied color.
+func (e *BufferEncoder) ColorNRGBA(value color.NRGBA) *BufferEncoder {
+	encodeColor((*Buffer)(e), colorModelNRGBA, value.R, value.G, value.B, value.A)
+	return e
+}
+
+// ColorRGBA decodes a color encoded with either ColorRGBA or ColorNRGBA,
+// premultiplying the channels if it was encoded with ColorNRGBA.
+func (d *BufferDecoder) ColorRGBA() (value color.RGBA, err error) {
+	var c color.Color
+	d.b, c, err = decodeColor(d.b)
+	if err == nil {
+		value = color.RGBAModel.Convert(c).(color.RGBA)
+	}
+	err = d.step(err)
+	return
+}
+
+// ColorNRGBA decodes a color encoded with either ColorRGBA or ColorNRGBA,
+// un-premultiplying the channels if it was encoded with ColorRGBA.
+func (d *BufferDecoder) ColorNRGBA() (value color.NRGBA, err error) {
+	var c color.Color
+	d.b, c, err = decodeColor(d.b)
+	if err == nil {
+		value = color.NRGBAModel.Convert(c).(color.NRGBA)
+	}
+	err = d.step(err)
+	return
+}
@@ -0,0 +1,70 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"image/color"
+	"testing"
+)
+
+func TestColor(t *testing.T) {
+	t.Parallel()
+
+	rgba := color.RGBA{R: 0x10, G: 0x20, B: 0x30, A: 0x80}
+	nrgba := color.NRGBA{R: 0xFF, G: 0x80, B: 0x00, A: 0x80}
+
+	p := NewBuffer()
+	Encoder(p).ColorRGBA(rgba).ColorNRGBA(nrgba).ColorNRGBA(nrgba)
+	assert.Equal(t, 3*colorSize, p.Len())
+
+	d := Decoder(p.Bytes())
+	r, err := d.ColorRGBA()
+	assert.NoError(t, err)
+	assert.Equal(t, rgba, r)
+
+	n, err := d.ColorNRGBA()
+	assert.NoError(t, err)
+	assert.Equal(t, nrgba, n)
+
+	r, err = d.ColorRGBA()
+	assert.NoError(t, err)
+	assert.Equal(t, color.RGBAModel.Convert(nrgba), r)
+	assert.Equal(t, 0, d.Remaining())
+
+	d = Decoder(p.Bytes())
+	kind, value, err := d.ReadTyped()
+	assert.NoError(t, err)
+	assert.Equal(t, ColorKind, kind)
+	assert.Equal(t, rgba, value)
+	kind, value, err = d.ReadTyped()
+	assert.NoError(t, err)
+	assert.Equal(t, ColorKind, kind)
+	assert.Equal(t, nrgba, value)
+	assert.NoError(t, d.Skip())
+	assert.Equal(t, 0, d.Remaining())
+
+	_, err = Decoder(p.Bytes()[:colorSize-1]).ColorRGBA()
+	assert.ErrorIs(t, err, ErrInvalidColor)
+
+	_, err = Decoder([]byte{ColorRawKind, 2, 0, 0, 0, 0}).ColorNRGBA()
+	assert.ErrorIs(t, err, ErrInvalidColor)
+
+	_, err = Decoder([]byte{ColorRawKind, colorModelRGBA, 0xFF, 0, 0, 0x80}).ColorRGBA()
+	assert.ErrorIs(t, err, ErrInvalidColor)
+}
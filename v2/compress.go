@@ -0,0 +1,137 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sync"
+)
+
+// CompressionAlgo is the one-byte tag CompressEncode writes before the compressed bytes.
+type CompressionAlgo byte
+
+const (
+	CompressionNone CompressionAlgo = iota
+	CompressionGzip
+	// CompressionZstd is zstd, which is registered when building with the zstd tag, and requires
+	// the module using it to require github.com/klauspost/compress. It's behind a tag so that the
+	// package has no dependency on a zstd implementation otherwise, and without it CompressEncode
+	// and Decompress fail with ErrUnknownCompression unless one is provided with RegisterCompression.
+	CompressionZstd
+)
+
+// MaxDecompressedSize is the largest payload Decompress inflates gzip or zstd data to, guarding
+// against small inputs that expand to huge outputs.
+const MaxDecompressedSize = 64 << 20
+
+// Compressor compresses and decompresses payloads for a CompressionAlgo.
+type Compressor interface {
+	Compress(b []byte) ([]byte, error)
+	Decompress(b []byte) ([]byte, error)
+}
+
+var (
+	compressorsMu sync.RWMutex
+	compressors   = map[CompressionAlgo]Compressor{
+		CompressionGzip: gzipCompressor{},
+	}
+)
+
+// RegisterCompression makes c available to CompressEncode and Decompress for algo, replacing
+// any compressor already registered for it. It's typically called from an init function, for
+// example to provide CompressionZstd.
+func RegisterCompression(algo CompressionAlgo, c Compressor) {
+	compressorsMu.Lock()
+	compressors[algo] = c
+	compressorsMu.Unlock()
+}
+
+func compressor(algo CompressionAlgo) (Compressor, error) {
+	compressorsMu.RLock()
+	c, ok := compressors[algo]
+	compressorsMu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownCompression
+	}
+	return c, nil
+}
+
+// CompressEncode returns the bytes encoded by e compressed with algo, prefixed with the
+// algorithm tag so Decompress can reverse it.
+func CompressEncode(e *BufferEncoder, algo CompressionAlgo) ([]byte, error) {
+	encoded := (*Buffer)(e).Bytes()
+	if algo == CompressionNone {
+		return append([]byte{byte(CompressionNone)}, encoded...), nil
+	}
+	c, err := compressor(algo)
+	if err != nil {
+		return nil, err
+	}
+	compressed, err := c.Compress(encoded)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{byte(algo)}, compressed...), nil
+}
+
+// Decompress reads the algorithm tag written by CompressEncode and returns the decompressed
+// bytes, ready to be passed to Decoder.
+func Decompress(b []byte) ([]byte, error) {
+	if len(b) == 0 {
+		return nil, ErrUnknownCompression
+	}
+	algo := CompressionAlgo(b[0])
+	if algo == CompressionNone {
+		return b[1:], nil
+	}
+	c, err := compressor(algo)
+	if err != nil {
+		return nil, err
+	}
+	return c.Decompress(b[1:])
+}
+
+type gzipCompressor struct{}
+
+func (gzipCompressor) Compress(b []byte) ([]byte, error) {
+	var out bytes.Buffer
+	w := gzip.NewWriter(&out)
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func (gzipCompressor) Decompress(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	out, err := io.ReadAll(io.LimitReader(r, MaxDecompressedSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(out) > MaxDecompressedSize {
+		return nil, ErrDecompressedTooLarge
+	}
+	return out, r.Close()
+}
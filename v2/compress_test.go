@@ -0,0 +1,84 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"bytes"
+	"testing"
+)
+
+type reverseCompressor struct{}
+
+func (reverseCompressor) Compress(b []byte) ([]byte, error) {
+	out := make([]byte, len(b))
+	for i := range b {
+		out[len(b)-1-i] = b[i]
+	}
+	return out, nil
+}
+
+func (c reverseCompressor) Decompress(b []byte) ([]byte, error) {
+	return c.Compress(b)
+}
+
+func TestCompress(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	e := Encoder(p)
+	for i := 0; i < 100; i++ {
+		e.String("repeated").Uint32(uint32(i))
+	}
+
+	for _, algo := range []CompressionAlgo{CompressionNone, CompressionGzip} {
+		c, err := CompressEncode(e, algo)
+		assert.NoError(t, err)
+		assert.Equal(t, byte(algo), c[0])
+
+		b, err := Decompress(c)
+		assert.NoError(t, err)
+		assert.Equal(t, p.Bytes(), b)
+	}
+
+	c, err := CompressEncode(e, CompressionGzip)
+	assert.NoError(t, err)
+	assert.Less(t, len(c), p.Len())
+
+	_, err = Decompress(c[:len(c)/2])
+	assert.Error(t, err)
+
+	_, err = CompressEncode(e, CompressionAlgo(200))
+	assert.ErrorIs(t, err, ErrUnknownCompression)
+	_, err = Decompress([]byte{200, 1, 2})
+	assert.ErrorIs(t, err, ErrUnknownCompression)
+	_, err = Decompress(nil)
+	assert.ErrorIs(t, err, ErrUnknownCompression)
+
+	RegisterCompression(CompressionAlgo(201), reverseCompressor{})
+	c, err = CompressEncode(e, CompressionAlgo(201))
+	assert.NoError(t, err)
+	b, err := Decompress(c)
+	assert.NoError(t, err)
+	assert.Equal(t, p.Bytes(), b)
+
+	bomb, err := gzipCompressor{}.Compress(bytes.Repeat([]byte{0}, MaxDecompressedSize+1))
+	assert.NoError(t, err)
+	_, err = Decompress(append([]byte{byte(CompressionGzip)}, bomb...))
+	assert.ErrorIs(t, err, ErrDecompressedTooLarge)
+}
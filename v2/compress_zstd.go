@@ -0,0 +1,59 @@
+//go:build zstd
+
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"errors"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Building with the zstd tag registers a zstd Compressor for CompressionZstd. It's behind a tag
+// so that the package has no dependency on a zstd implementation otherwise, and the module using
+// it must require github.com/klauspost/compress itself.
+func init() {
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		panic(err)
+	}
+	decoder, err := zstd.NewReader(nil, zstd.WithDecoderMaxMemory(MaxDecompressedSize), zstd.WithDecoderConcurrency(0))
+	if err != nil {
+		panic(err)
+	}
+	RegisterCompression(CompressionZstd, zstdCompressor{encoder: encoder, decoder: decoder})
+}
+
+// zstdCompressor compresses whole payloads, which EncodeAll and DecodeAll allow
+// from any number of goroutines at once.
+type zstdCompressor struct {
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+func (c zstdCompressor) Compress(b []byte) ([]byte, error) {
+	return c.encoder.EncodeAll(b, nil), nil
+}
+
+func (c zstdCompressor) Decompress(b []byte) ([]byte, error) {
+	out, err := c.decoder.DecodeAll(b, nil)
+	if errors.Is(err, zstd.ErrDecoderSizeExceeded) {
+		return nil, ErrDecompressedTooLarge
+	}
+	return out, err
+}
@@ -0,0 +1,55 @@
+//go:build zstd
+
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"bytes"
+	"testing"
+)
+
+func TestCompressZstd(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	e := Encoder(p)
+	for i := 0; i < 100; i++ {
+		e.String("repeated").Uint32(uint32(i))
+	}
+
+	c, err := CompressEncode(e, CompressionZstd)
+	assert.NoError(t, err)
+	assert.Equal(t, byte(CompressionZstd), c[0])
+	assert.Less(t, len(c), p.Len())
+
+	b, err := Decompress(c)
+	assert.NoError(t, err)
+	assert.Equal(t, p.Bytes(), b)
+
+	_, err = Decompress(c[:len(c)/2])
+	assert.Error(t, err)
+
+	zstd, err := compressor(CompressionZstd)
+	assert.NoError(t, err)
+	bomb, err := zstd.Compress(bytes.Repeat([]byte{0}, MaxDecompressedSize+1))
+	assert.NoError(t, err)
+	_, err = Decompress(append([]byte{byte(CompressionZstd)}, bomb...))
+	assert.ErrorIs(t, err, ErrDecompressedTooLarge)
+}
@@ -17,7 +17,9 @@
 package polyglot
 
 import (
+	"encoding/binary"
 	"errors"
+	"io"
 	"math"
 )
 
@@ -27,23 +29,84 @@ const (
 	VarIntLen32  = 5
 	VarIntLen64  = 10
 	continuation = 0x80
+
+	// The largest final bytes of maximum length varints whose values still fit in 16, 32 and
+	// 64 bits. Anything larger has bits set beyond the width of the kind, so it's overlong.
+	lastVarIntByte16 = 0x03
+	lastVarIntByte32 = 0x0F
+	lastVarIntByte64 = 0x01
 )
 
 var (
-	ErrInvalidSlice   = errors.New("invalid slice encoding")
-	ErrInvalidMap     = errors.New("invalid map encoding")
-	ErrInvalidBytes   = errors.New("invalid bytes encoding")
-	ErrInvalidString  = errors.New("invalid string encoding")
-	ErrInvalidError   = errors.New("invalid error encoding")
-	ErrInvalidBool    = errors.New("invalid bool encoding")
-	ErrInvalidUint8   = errors.New("invalid uint8 encoding")
-	ErrInvalidUint16  = errors.New("invalid uint16 encoding")
-	ErrInvalidUint32  = errors.New("invalid uint32 encoding")
-	ErrInvalidUint64  = errors.New("invalid uint64 encoding")
-	ErrInvalidInt32   = errors.New("invalid int32 encoding")
-	ErrInvalidInt64   = errors.New("invalid int64 encoding")
-	ErrInvalidFloat32 = errors.New("invalid float32 encoding")
-	ErrInvalidFloat64 = errors.New("invalid float64 encoding")
+	ErrInvalidSlice         = errors.New("invalid slice encoding")
+	ErrInvalidMap           = errors.New("invalid map encoding")
+	ErrInvalidBytes         = errors.New("invalid bytes encoding")
+	ErrInvalidString        = errors.New("invalid string encoding")
+	ErrInvalidError         = errors.New("invalid error encoding")
+	ErrInvalidBool          = errors.New("invalid bool encoding")
+	ErrInvalidUint8         = errors.New("invalid uint8 encoding")
+	ErrInvalidUint16        = errors.New("invalid uint16 encoding")
+	ErrInvalidUint32        = errors.New("invalid uint32 encoding")
+	ErrInvalidUint64        = errors.New("invalid uint64 encoding")
+	ErrInvalidInt32         = errors.New("invalid int32 encoding")
+	ErrInvalidInt64         = errors.New("invalid int64 encoding")
+	ErrInvalidFloat32       = errors.New("invalid float32 encoding")
+	ErrInvalidFloat64       = errors.New("invalid float64 encoding")
+	ErrInvalidNetipAddr     = errors.New("invalid netip addr encoding")
+	ErrInvalidPrefix        = errors.New("invalid prefix encoding")
+	ErrContainerKind        = errors.New("container kinds can't be decoded as a single value")
+	ErrUnsupportedKind      = errors.New("unsupported kind")
+	ErrUnsupportedType      = errors.New("unsupported type")
+	ErrInvalidTarget        = errors.New("target must be a non-nil pointer")
+	ErrDuplicateField       = errors.New("duplicate field")
+	ErrNeedMoreData         = errors.New("need more data")
+	ErrInvalidHardwareAddr  = errors.New("invalid hardware addr encoding")
+	ErrInvalidTime          = errors.New("invalid time encoding")
+	ErrTrailingData         = errors.New("trailing data after message")
+	ErrInvalidBigFloat      = errors.New("invalid big float encoding")
+	ErrInvalidFixedSlice    = errors.New("invalid fixed slice encoding")
+	ErrInvalidURL           = errors.New("invalid url encoding")
+	ErrInvalidColor         = errors.New("invalid color encoding")
+	ErrDecodePanic          = errors.New("panic during decode")
+	ErrInvalidSet           = errors.New("invalid set encoding")
+	ErrTooManyElements      = errors.New("too many elements")
+	ErrInvalidFloatText     = errors.New("invalid float text encoding")
+	ErrInvalidFrame         = errors.New("invalid frame")
+	ErrChecksumMismatch     = errors.New("checksum mismatch")
+	ErrVarintOverflow       = errors.New("varint overflows 64 bits")
+	ErrInvalidRegexp        = errors.New("invalid regexp encoding")
+	ErrUnknownField         = errors.New("unknown field")
+	ErrFieldOutOfRange      = errors.New("field index out of range")
+	ErrInvalidSparseSlice   = errors.New("invalid sparse slice encoding")
+	ErrDuplicateKey         = errors.New("duplicate map key")
+	ErrUnknownCompression   = errors.New("unknown compression algorithm")
+	ErrDecompressedTooLarge = errors.New("decompressed payload too large")
+	ErrInvalidTimeRange     = errors.New("invalid time range encoding")
+	ErrInvalidMonth         = errors.New("invalid month encoding")
+	ErrInvalidWeekday       = errors.New("invalid weekday encoding")
+	ErrBudgetExceeded       = errors.New("decode budget exceeded")
+	ErrInvalidLatLng        = errors.New("invalid lat/lng encoding")
+	ErrDisallowedKind       = errors.New("kind not allowed")
+	ErrInvalidFlags         = errors.New("invalid flags encoding")
+	ErrUnknownTypeID        = errors.New("unknown type id")
+	ErrKindMismatch         = errors.New("unexpected kind")
+	ErrInvalidRLESlice      = errors.New("invalid run-length encoded slice encoding")
+	ErrMessageTooLarge      = errors.New("message too large")
+	ErrInvalidResult        = errors.New("invalid result encoding")
+	ErrWrongBytesLength     = errors.New("wrong bytes length")
+	ErrInvalidRat           = errors.New("invalid rat encoding")
+	ErrInvalidPackedSlice   = errors.New("invalid packed slice encoding")
+	ErrSchemaMismatch       = errors.New("schema fingerprint mismatch")
+	ErrInvalidDeltaMap      = errors.New("invalid delta map encoding")
+	ErrInvalidHistogram     = errors.New("invalid histogram encoding")
+	ErrFieldCountMismatch   = errors.New("unexpected number of fields")
+	ErrInvalidChecksumSlice = errors.New("invalid checksum slice encoding")
+	ErrInvalidAddr          = errors.New("invalid addr encoding")
+	ErrUnsupportedAddr      = errors.New("unsupported addr type")
+	ErrInvalidSchedule      = errors.New("invalid schedule encoding")
+	ErrTooDeep              = errors.New("value nested too deeply")
+	ErrInvalidTotal         = errors.New("total out of range")
+	ErrShortAlloc           = errors.New("allocated storage too short")
 )
 
 func decodeNil(b []byte) ([]byte, bool) {
@@ -79,6 +142,28 @@ func decodeSlice(b []byte, kind Kind) ([]byte, uint32, error) {
 	return b, 0, ErrInvalidSlice
 }
 
+func decodeMapHeader(b []byte) ([]byte, Kind, Kind, uint32, error) {
+	if len(b) > 3 && b[0] == MapRawKind {
+		remaining, size, err := decodeUint32(b[3:])
+		if err != nil {
+			return b, 0, 0, 0, ErrInvalidMap
+		}
+		return remaining, Kind(b[1]), Kind(b[2]), size, nil
+	}
+	return b, 0, 0, 0, ErrInvalidMap
+}
+
+func decodeSliceHeader(b []byte) ([]byte, Kind, uint32, error) {
+	if len(b) > 2 && b[0] == SliceRawKind {
+		remaining, size, err := decodeUint32(b[2:])
+		if err != nil {
+			return b, 0, 0, ErrInvalidSlice
+		}
+		return remaining, Kind(b[1]), size, nil
+	}
+	return b, 0, 0, ErrInvalidSlice
+}
+
 func decodeBytes(b []byte, ret []byte) ([]byte, []byte, error) {
 	if len(b) > 2 && b[0] == BytesRawKind && b[1] == Uint32RawKind {
 		var size int
@@ -108,7 +193,7 @@ func decodeBytes(b []byte, ret []byte) ([]byte, []byte, error) {
 					} else {
 						x |= (cb & (continuation - 1)) << 21
 						cb = uint32(b[6])
-						if cb < continuation {
+						if cb <= lastVarIntByte32 {
 							size = int(x | (cb << 28))
 							offset = 7
 						}
@@ -116,13 +201,39 @@ func decodeBytes(b []byte, ret []byte) ([]byte, []byte, error) {
 				}
 			}
 		}
-		if len(b)-offset > size-1 {
+		if offset > 0 && len(b)-offset > size-1 {
 			return b[size+offset:], append(ret[:0], b[offset:size+offset]...), nil
 		}
 	}
 	return b, nil, ErrInvalidBytes
 }
 
+func decodeBytesExact(b []byte) ([]byte, []byte, error) {
+	if len(b) > 1 && b[0] == BytesRawKind {
+		remaining, size, err := decodeUint32(b[1:])
+		if err == nil && uint64(len(remaining)) >= uint64(size) {
+			value := make([]byte, size)
+			copy(value, remaining)
+			return remaining[size:], value, nil
+		}
+	}
+	return b, nil, ErrInvalidBytes
+}
+
+func decodeBytesArray(b []byte, dst []byte) ([]byte, error) {
+	if len(b) > 1 && b[0] == BytesRawKind {
+		remaining, size, err := decodeUint32(b[1:])
+		if err == nil && uint64(len(remaining)) >= uint64(size) {
+			if int(size) != len(dst) {
+				return b, ErrWrongBytesLength
+			}
+			copy(dst, remaining)
+			return remaining[size:], nil
+		}
+	}
+	return b, ErrInvalidBytes
+}
+
 func decodeString(b []byte) ([]byte, string, error) {
 	if len(b) > 1 && b[0] == StringRawKind {
 		var size uint32
@@ -185,7 +296,7 @@ func decodeUint16(b []byte) ([]byte, uint16, error) {
 
 		x |= (cb & (continuation - 1)) << 7
 		cb = uint16(b[3])
-		if cb < continuation {
+		if cb <= lastVarIntByte16 {
 			return b[4:], x | (cb << 14), nil
 		}
 	}
@@ -219,7 +330,7 @@ func decodeUint32(b []byte) ([]byte, uint32, error) {
 
 		x |= (cb & (continuation - 1)) << 21
 		cb = uint32(b[5])
-		if cb < continuation {
+		if cb <= lastVarIntByte32 {
 			return b[6:], x | (cb << 28), nil
 		}
 	}
@@ -283,10 +394,22 @@ func decodeUint64(b []byte) ([]byte, uint64, error) {
 
 		x |= (cb & (continuation - 1)) << 56
 		cb = uint64(b[10])
-		if cb < continuation {
+		if cb <= lastVarIntByte64 {
 			return b[11:], x | (cb << 63), nil
 		}
 	}
+	if len(b) > 9 && b[0] == PrefixUint64RawKind {
+		// readPrefixUvarint, for when there are enough bytes to load eight at once
+		if b[1] == 0 {
+			return b[10:], binary.LittleEndian.Uint64(b[2:]), nil
+		}
+		n := prefixUvarintLen(b[1])
+		shift := uint(64 - 8*n)
+		return b[1+n:], binary.LittleEndian.Uint64(b[1:]) << shift >> (shift + uint(n)), nil
+	}
+	if len(b) > 1 && b[0] == PrefixUint64RawKind {
+		return decodePrefixUint64(b)
+	}
 	return b, 0, ErrInvalidUint64
 }
 
@@ -333,7 +456,7 @@ func decodeInt32(b []byte) ([]byte, int32, error) {
 
 		x |= (cb & (continuation - 1)) << 21
 		cb = uint32(b[5])
-		if cb < continuation {
+		if cb <= lastVarIntByte32 {
 			x |= cb << 28
 			if x&1 != 0 {
 				return b[6:], -(int32(x>>1) + 1), nil
@@ -437,7 +560,7 @@ func decodeInt64(b []byte) ([]byte, int64, error) {
 
 		x |= (cb & (continuation - 1)) << 56
 		cb = uint64(b[10])
-		if cb < continuation {
+		if cb <= lastVarIntByte64 {
 			x |= cb << 63
 			if x&1 != 0 {
 				return b[11:], -(int64(x>>1) + 1), nil
@@ -445,6 +568,9 @@ func decodeInt64(b []byte) ([]byte, int64, error) {
 			return b[11:], int64(x >> 1), nil
 		}
 	}
+	if len(b) > 1 && b[0] == PrefixInt64RawKind {
+		return decodePrefixInt64(b)
+	}
 	return b, 0, ErrInvalidInt64
 }
 
@@ -462,3 +588,133 @@ func decodeFloat64(b []byte) ([]byte, float64, error) {
 	}
 	return b, 0, ErrInvalidFloat64
 }
+
+func decodeEmpty(b []byte) ([]byte, bool) {
+	if len(b) > 0 && b[0] == EmptyRawKind {
+		return b[1:], true
+	}
+	return b, false
+}
+
+func decodeTyped(b []byte) ([]byte, Kind, any, error) {
+	if len(b) == 0 {
+		return b, NilKind, nil, io.ErrUnexpectedEOF
+	}
+	kind := Kind(b[0])
+	var value any
+	var err error
+	switch b[0] {
+	case NilRawKind:
+		return b[1:], kind, nil, nil
+	case EmptyRawKind:
+		return b[1:], kind, nil, nil
+	case SliceRawKind, MapRawKind, SetRawKind, SparseSliceRawKind, RLESliceRawKind, PackedSliceRawKind, DeltaMapRawKind, ChecksumSliceRawKind, ResultRawKind, AnyRawKind:
+		return b, kind, nil, ErrContainerKind
+	case BytesRawKind:
+		b, value, err = decodeBytes(b, nil)
+	case StringRawKind:
+		b, value, err = decodeString(b)
+	case ErrorRawKind:
+		b, value, err = decodeError(b)
+	case BoolRawKind:
+		b, value, err = decodeBool(b)
+	case Uint8RawKind:
+		b, value, err = decodeUint8(b)
+	case Uint16RawKind:
+		b, value, err = decodeUint16(b)
+	case Uint32RawKind:
+		b, value, err = decodeUint32(b)
+	case Uint64RawKind:
+		b, value, err = decodeUint64(b)
+	case StaticUint32RawKind:
+		b, value, err = decodeStaticUint32(b)
+	case Int32RawKind:
+		b, value, err = decodeInt32(b)
+	case Int64RawKind:
+		b, value, err = decodeInt64(b)
+	case Float32RawKind:
+		b, value, err = decodeFloat32(b)
+	case Float64RawKind:
+		b, value, err = decodeFloat64(b)
+	case NetipAddrRawKind:
+		b, value, err = decodeNetipAddr(b)
+	case NetipPrefixRawKind:
+		b, value, err = decodeNetipPrefix(b)
+	case BytesVarRawKind:
+		b, value, err = decodeBytesVar(b, nil)
+	case StringVarRawKind:
+		b, value, err = decodeStringVar(b)
+	case HardwareAddrRawKind:
+		b, value, err = decodeHardwareAddr(b)
+	case AddrRawKind:
+		b, value, err = decodeAddr(b)
+	case TimeZoneRawKind:
+		b, value, err = decodeTimeWithZone(b)
+	case UnixMillisRawKind:
+		b, value, err = decodeUnixMillis(b)
+	case TimeRangeRawKind:
+		var r TimeRange
+		b, r.Start, r.End, err = decodeTimeRange(b)
+		value = r
+	case ScheduleRawKind:
+		b, value, err = decodeSchedule(b)
+	case LatLngRawKind:
+		b, value, err = decodeLatLng(b)
+	case HistogramRawKind:
+		b, value, err = decodeHistogram(b, 0)
+	case FlagsRawKind:
+		b, value, err = decodeFlags(b)
+	case FlagByteRawKind:
+		b, value, err = decodeFlagByte(b)
+	case BigFloatRawKind:
+		b, value, err = decodeBigFloat(b)
+	case RatRawKind:
+		b, value, err = decodeRat(b)
+	case Base64RawKind:
+		b, value, err = decodeBytesBase64(b)
+	case PrefixUint64RawKind:
+		b, value, err = decodePrefixUint64(b)
+	case PrefixInt64RawKind:
+		b, value, err = decodePrefixInt64(b)
+	case FixedSliceRawKind:
+		b, value, err = decodeFixedSliceTyped(b)
+	case URLRawKind:
+		b, value, err = decodeURL(b)
+	case ColorRawKind:
+		b, value, err = decodeColor(b)
+	case FloatTextRawKind:
+		b, value, err = decodeFloatText(b)
+	case CheckedUint64RawKind:
+		b, value, err = decodeCheckedUint64(b)
+	case StringUTF16RawKind:
+		b, value, err = decodeStringUTF16(b)
+	case RegexpRawKind:
+		b, value, err = decodeRegexp(b)
+	default:
+		return b, kind, nil, ErrUnsupportedKind
+	}
+	if err != nil {
+		return b, kind, nil, err
+	}
+	return b, kind, value, nil
+}
+
+func decodeBytesVar(b []byte, ret []byte) ([]byte, []byte, error) {
+	if len(b) > 1 && b[0] == BytesVarRawKind {
+		remaining, size, ok := readUvarint(b[1:])
+		if ok && size <= uint64(len(remaining)) {
+			return remaining[size:], append(ret[:0], remaining[:size]...), nil
+		}
+	}
+	return b, nil, ErrInvalidBytes
+}
+
+func decodeStringVar(b []byte) ([]byte, string, error) {
+	if len(b) > 1 && b[0] == StringVarRawKind {
+		remaining, size, ok := readUvarint(b[1:])
+		if ok && size <= uint64(len(remaining)) {
+			return remaining[size:], string(remaining[:size]), nil
+		}
+	}
+	return b, emptyString, ErrInvalidString
+}
@@ -20,6 +20,8 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"errors"
+	"math"
+	"strings"
 	"testing"
 )
 
@@ -85,6 +87,26 @@ func TestDecodeMap(t *testing.T) {
 	assert.Zero(t, n)
 }
 
+func TestDecodeSliceHeader(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	encodeSlice(p, 32, StringKind)
+
+	remaining, kind, size, err := decodeSliceHeader(p.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, StringKind, kind)
+	assert.Equal(t, uint32(32), size)
+	assert.Equal(t, 0, len(remaining))
+
+	_, _, _, err = decodeSliceHeader((p.Bytes())[1:])
+	assert.ErrorIs(t, err, ErrInvalidSlice)
+
+	remaining, _, _, err = decodeSliceHeader((p.Bytes())[:2])
+	assert.ErrorIs(t, err, ErrInvalidSlice)
+	assert.Equal(t, 2, len(remaining))
+}
+
 func TestDecodeBytes(t *testing.T) {
 	t.Parallel()
 
@@ -606,3 +628,127 @@ func TestDecodeFloat64(t *testing.T) {
 	})
 	assert.Zero(t, n)
 }
+
+func TestDecodeEmpty(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	encodeEmpty(p)
+
+	remaining, value := decodeEmpty(p.Bytes())
+	assert.True(t, value)
+	assert.Equal(t, 0, len(remaining))
+
+	_, value = decodeEmpty((p.Bytes())[1:])
+	assert.False(t, value)
+
+	p.Reset()
+	encodeNil(p)
+	remaining, value = decodeEmpty(p.Bytes())
+	assert.False(t, value)
+	assert.Equal(t, 1, len(remaining))
+}
+
+func TestDecodeBytesVar(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	v := []byte("Test Bytes")
+	encodeBytesVar(p, v)
+
+	remaining, value, err := decodeBytesVar(p.Bytes(), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, v, value)
+	assert.Equal(t, 0, len(remaining))
+
+	_, _, err = decodeBytesVar((p.Bytes())[1:], nil)
+	assert.ErrorIs(t, err, ErrInvalidBytes)
+
+	_, _, err = decodeBytesVar((p.Bytes())[:len(p.Bytes())-1], nil)
+	assert.ErrorIs(t, err, ErrInvalidBytes)
+
+	p.Reset()
+	encodeBytes(p, v)
+	_, _, err = decodeBytesVar(p.Bytes(), nil)
+	assert.ErrorIs(t, err, ErrInvalidBytes)
+}
+
+func TestDecodeStringVar(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	v := strings.Repeat("Test String", 100)
+	encodeStringVar(p, v)
+
+	remaining, value, err := decodeStringVar(p.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, v, value)
+	assert.Equal(t, 0, len(remaining))
+
+	_, _, err = decodeStringVar((p.Bytes())[1:])
+	assert.ErrorIs(t, err, ErrInvalidString)
+
+	_, _, err = decodeStringVar((p.Bytes())[:len(p.Bytes())-1])
+	assert.ErrorIs(t, err, ErrInvalidString)
+
+	_, _, err = decodeStringVar((p.Bytes())[:2])
+	assert.ErrorIs(t, err, ErrInvalidString)
+}
+
+func TestDecodeOverlongVarint(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	encodeUint16(p, math.MaxUint16)
+	encodeUint32(p, math.MaxUint32)
+	encodeInt32(p, math.MinInt32)
+	encodeUint64(p, math.MaxUint64)
+	encodeInt64(p, math.MinInt64)
+	b := p.Bytes()
+
+	remaining, u16, err := decodeUint16(b)
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(math.MaxUint16), u16)
+	remaining, u32, err := decodeUint32(remaining)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(math.MaxUint32), u32)
+	remaining, i32, err := decodeInt32(remaining)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(math.MinInt32), i32)
+	remaining, u64, err := decodeUint64(remaining)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(math.MaxUint64), u64)
+	remaining, i64, err := decodeInt64(remaining)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(math.MinInt64), i64)
+	assert.Empty(t, remaining)
+
+	// The final byte of each varint has bits set beyond the width of its kind.
+	_, _, err = decodeUint16([]byte{Uint16RawKind, 0xFF, 0xFF, 0x04})
+	assert.ErrorIs(t, err, ErrInvalidUint16)
+	_, _, err = decodeUint32([]byte{Uint32RawKind, 0xFF, 0xFF, 0xFF, 0xFF, 0x10})
+	assert.ErrorIs(t, err, ErrInvalidUint32)
+	_, _, err = decodeInt32([]byte{Int32RawKind, 0xFF, 0xFF, 0xFF, 0xFF, 0x1F})
+	assert.ErrorIs(t, err, ErrInvalidInt32)
+	_, _, err = decodeUint64([]byte{Uint64RawKind, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x02})
+	assert.ErrorIs(t, err, ErrInvalidUint64)
+	_, _, err = decodeInt64([]byte{Int64RawKind, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x7F})
+	assert.ErrorIs(t, err, ErrInvalidInt64)
+
+	// Varints that are still continued after the maximum length.
+	overlong := []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x01}
+	_, _, err = decodeUint16(append([]byte{Uint16RawKind}, overlong...))
+	assert.ErrorIs(t, err, ErrInvalidUint16)
+	_, _, err = decodeUint32(append([]byte{Uint32RawKind}, overlong...))
+	assert.ErrorIs(t, err, ErrInvalidUint32)
+	_, _, err = decodeInt32(append([]byte{Int32RawKind}, overlong...))
+	assert.ErrorIs(t, err, ErrInvalidInt32)
+	_, _, err = decodeUint64(append([]byte{Uint64RawKind}, overlong...))
+	assert.ErrorIs(t, err, ErrInvalidUint64)
+
+	// Lengths of bytes are bounded the same way.
+	_, _, err = decodeBytes([]byte{BytesRawKind, Uint32RawKind, 0xFF, 0xFF, 0xFF, 0xFF, 0x10}, nil)
+	assert.ErrorIs(t, err, ErrInvalidBytes)
+	_, _, err = decodeBytes(append([]byte{BytesRawKind, Uint32RawKind}, overlong...), nil)
+	assert.ErrorIs(t, err, ErrInvalidBytes)
+}
@@ -16,84 +16,759 @@
 
 package polyglot
 
-type BufferDecoder []byte
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DecoderOptions configures the behaviour of a Decoder created with DecoderWithOptions.
+type DecoderOptions struct {
+	// AllowTrailing makes Finish ignore any bytes left over after the message,
+	// such as padding added by the transport.
+	AllowTrailing bool
+
+	// Progress, if set, is called with the number of bytes consumed so far and
+	// the length of the buffer the Decoder was created with, once at least
+	// ProgressInterval bytes have been consumed since the previous call.
+	Progress         func(consumed, total int)
+	ProgressInterval int
+
+	// MaxElements, if set, limits the number of elements accepted by Any and by
+	// collection helpers like DecodeSet, failing with ErrTooManyElements beyond it.
+	MaxElements int
+
+	// RejectDuplicateKeys makes Any, Unmarshal and the map helpers like DecodeOrderedMap
+	// fail with ErrDuplicateKey if a map contains the same key more than once, rather
+	// than keeping the last value.
+	RejectDuplicateKeys bool
+
+	// RequireOrderedTimeRanges makes TimeRange fail with ErrInvalidTimeRange
+	// if the decoded end is before the start.
+	RequireOrderedTimeRanges bool
+
+	// MaxOps, if set, limits the number of values and headers that can be read, failing with
+	// ErrBudgetExceeded beyond it. Nested containers decoded with Any or Unmarshal count every
+	// element, so this bounds the total work done on untrusted input regardless of its shape.
+	// Nil and IsEmpty count towards the budget too, and as they have no error to return, they
+	// return false once it's exceeded, leaving the Decoder where it was.
+	MaxOps int
+
+	// AllowedKinds, if set, makes any read of a value or header whose kind isn't in the
+	// list fail with ErrDisallowedKind, leaving the Decoder positioned at that value.
+	// Nil and IsEmpty return false for a disallowed Nil or Empty, as they have no error to
+	// return. Values skipped with Skip are only checked at the top level.
+	AllowedKinds []Kind
+
+	// Trace, if set, is called after every value or header is read
+	// with a TraceEvent describing the bytes that were consumed.
+	Trace func(TraceEvent)
+
+	// InternString, if set, is called with every string decoded by String, StringVar,
+	// StringUTF16, ReadTyped, Any and Unmarshal, and its result is returned in place of
+	// the decoded string. It's typically used to share one copy of strings that repeat
+	// across many messages, like map keys or column names.
+	InternString func(string) string
+
+	// AllocBytes, if set, is called by Bytes with a nil slice and by String to obtain the
+	// backing storage for each decoded value, and must return a slice of length n, or the read
+	// fails with ErrShortAlloc. It lets the storage come from a caller managed pool, and is
+	// ignored by a Decoder with an Arena. Strings decoded into the storage share its memory, so
+	// like the bytes they must not be used once it's returned to the pool and reused. Strings
+	// aren't decoded into it when InternString is set, as interned strings are usually kept.
+	AllocBytes func(n int) []byte
+
+	// ErrorContext, if set, makes every read that fails return a *DecodeError holding
+	// the offset of the failing value and up to ErrorContext bytes either side of it.
+	ErrorContext int
+
+	// ReadOnly makes Bytes, BytesVar and DecodeFixedSlice ignore the slice passed to them for
+	// reuse and always allocate, so that decoding never writes to memory the caller didn't
+	// explicitly hand over, like BytesArray's dst. No Decoder ever writes to its own buffer,
+	// but a slice passed for reuse may alias it, for instance when it came from RawMessage or
+	// UnsafeDecodeFixedSlice. Together these make it safe to decode straight from read-only
+	// memory, like a file mapped with mmap, where any write would fault.
+	ReadOnly bool
+
+	// Schema, if set, lists the kind of every top-level value in the message, and makes a read
+	// that starts at a value fail with an *UnexpectedKindError if the value isn't of that kind,
+	// leaving the Decoder positioned at it. A Nil is accepted in place of any kind, as it's how an
+	// optional field is left out. Reads inside a value, like the elements of a Slice, aren't
+	// checked, and values beyond the end of the Schema fail with ErrFieldOutOfRange.
+	Schema []Kind
+}
+
+type BufferDecoder struct {
+	b []byte
+	// hooks is set when any of the options that step acts on is, so that a read
+	// with none of them only pays for checking it.
+	hooks    bool
+	arena    *Arena
+	options  DecoderOptions
+	origin   []byte
+	total    int
+	reported int
+	traced   int
+	ops      int
+	allowed  *[256]bool
+	last     []byte
+
+	expectFields bool
+	fields       int
+	fieldsFrom   []byte
+
+	schemaField int
+	schemaNext  []byte
+}
 
 func Decoder(b []byte) *BufferDecoder {
-	c := (BufferDecoder)(b)
+	return &BufferDecoder{
+		b: b,
+	}
+}
+
+// DecoderReadOnly returns a Decoder with the ReadOnly option set, for decoding from b when it's
+// read-only memory, like a memory-mapped file. Values returned by RawMessage, UnsafeDecodeFixedSlice
+// and LazyMessage.Raw alias b, and must be treated as read-only too.
+func DecoderReadOnly(b []byte) *BufferDecoder {
+	return DecoderWithOptions(b, DecoderOptions{ReadOnly: true})
+}
+
+// DecoderSchema returns a Decoder with the Schema option set to kinds, which checks that the
+// top-level values of the message are of those kinds, in that order, as they're decoded.
+func DecoderSchema(b []byte, kinds []Kind) *BufferDecoder {
+	return DecoderWithOptions(b, DecoderOptions{Schema: kinds})
+}
+
+// DecoderWithArena returns a Decoder that allocates decoded bytes and strings
+// from the given Arena instead of the heap. Decoded values must not be retained
+// after the Arena is reset.
+func DecoderWithArena(b []byte, arena *Arena) *BufferDecoder {
+	return &BufferDecoder{
+		b:     b,
+		arena: arena,
+	}
+}
+
+// DecoderWithArenaOptions returns a Decoder configured with the given options that allocates
+// decoded bytes and strings from the given Arena, as DecoderWithArena does. Strings aren't
+// allocated from the Arena if options.InternString is set, as interned strings are usually kept.
+func DecoderWithArenaOptions(b []byte, arena *Arena, options DecoderOptions) *BufferDecoder {
+	d := DecoderWithOptions(b, options)
+	d.arena = arena
+	return d
+}
+
+// DecoderWithOptions returns a Decoder configured with the given options.
+func DecoderWithOptions(b []byte, options DecoderOptions) *BufferDecoder {
+	d := &BufferDecoder{
+		b:       b,
+		options: options,
+		origin:  b,
+		total:   len(b),
+		last:    b,
+		hooks: options.Progress != nil || options.Trace != nil || options.MaxOps > 0 ||
+			len(options.AllowedKinds) > 0 || options.Schema != nil || options.ErrorContext > 0,
+	}
+	if options.Schema != nil {
+		d.schemaNext = b
+	}
+	if len(options.AllowedKinds) > 0 {
+		d.allowed = new([256]bool)
+		for _, kind := range options.AllowedKinds {
+			d.allowed[kind] = true
+		}
+	}
+	return d
+}
+
+// Clone returns an independent Decoder positioned at the same offset as d. Decoding from the
+// clone doesn't advance d, so a speculative decode can be committed with *d = *clone.
+func (d *BufferDecoder) Clone() *BufferDecoder {
+	c := *d
 	return &c
 }
 
+// step is called after every read with its error, if any, to report progress and trace events.
+// It returns err, ErrDisallowedKind if the value read isn't one of the AllowedKinds, or
+// ErrBudgetExceeded once more than MaxOps reads have been made. Only the hooks flag is checked
+// inline, so a Decoder without any of those options pays next to nothing for them.
+func (d *BufferDecoder) step(err error) error {
+	if d.hooks {
+		return d.stepHooks(err)
+	}
+	return err
+}
+
+// stepTo moves d to b, the bytes left after a read, and steps it with the read's error,
+// returning where d was left, which is back at the value if it was rejected.
+func (d *BufferDecoder) stepTo(b []byte, err error) ([]byte, error) {
+	d.b = b
+	err = d.stepHooks(err)
+	return d.b, err
+}
+
+// readError and the other readX methods do the work of the most common reads. Each decodes the
+// next value and steps d over it only if an option needs to act on it, and returns the bytes left
+// rather than assigning them. The read methods are then a single call to one of them and an
+// assignment, which keeps them cheap enough to be inlined, so a Decoder that doesn't escape is
+// updated in place. The readX methods themselves aren't inlined, since the decode and step calls
+// together are over the inliner's budget, so each read makes one call more than it did before
+// options existed.
+func (d *BufferDecoder) readError() ([]byte, error, error) {
+	b, value, err := decodeError(d.b)
+	if d.hooks {
+		b, err = d.stepTo(b, err)
+	}
+	return b, value, err
+}
+
+func (d *BufferDecoder) readBool() ([]byte, bool, error) {
+	b, value, err := decodeBool(d.b)
+	if d.hooks {
+		b, err = d.stepTo(b, err)
+	}
+	return b, value, err
+}
+
+func (d *BufferDecoder) readUint8() ([]byte, uint8, error) {
+	b, value, err := decodeUint8(d.b)
+	if d.hooks {
+		b, err = d.stepTo(b, err)
+	}
+	return b, value, err
+}
+
+func (d *BufferDecoder) readUint16() ([]byte, uint16, error) {
+	b, value, err := decodeUint16(d.b)
+	if d.hooks {
+		b, err = d.stepTo(b, err)
+	}
+	return b, value, err
+}
+
+func (d *BufferDecoder) readUint32() ([]byte, uint32, error) {
+	b, value, err := decodeUint32(d.b)
+	if d.hooks {
+		b, err = d.stepTo(b, err)
+	}
+	return b, value, err
+}
+
+func (d *BufferDecoder) readUint64() ([]byte, uint64, error) {
+	b, value, err := decodeUint64(d.b)
+	if d.hooks {
+		b, err = d.stepTo(b, err)
+	}
+	return b, value, err
+}
+
+func (d *BufferDecoder) readInt32() ([]byte, int32, error) {
+	b, value, err := decodeInt32(d.b)
+	if d.hooks {
+		b, err = d.stepTo(b, err)
+	}
+	return b, value, err
+}
+
+func (d *BufferDecoder) readInt64() ([]byte, int64, error) {
+	b, value, err := decodeInt64(d.b)
+	if d.hooks {
+		b, err = d.stepTo(b, err)
+	}
+	return b, value, err
+}
+
+func (d *BufferDecoder) readFloat32() ([]byte, float32, error) {
+	b, value, err := decodeFloat32(d.b)
+	if d.hooks {
+		b, err = d.stepTo(b, err)
+	}
+	return b, value, err
+}
+
+func (d *BufferDecoder) readFloat64() ([]byte, float64, error) {
+	b, value, err := decodeFloat64(d.b)
+	if d.hooks {
+		b, err = d.stepTo(b, err)
+	}
+	return b, value, err
+}
+
+// readNil is like the other readX methods, but as Nil has no error to return, it reports false
+// instead if an option rejects the read, and leaves d where it was.
+func (d *BufferDecoder) readNil() ([]byte, bool) {
+	b, value := decodeNil(d.b)
+	if d.hooks {
+		start := d.b
+		if _, err := d.stepTo(b, nil); err != nil {
+			return start, false
+		}
+	}
+	return b, value
+}
+
+func (d *BufferDecoder) readMap(keyKind, valueKind Kind) ([]byte, uint32, error) {
+	b, size, err := decodeMap(d.b, keyKind, valueKind)
+	if d.hooks {
+		b, err = d.stepTo(b, err)
+	}
+	return b, size, err
+}
+
+func (d *BufferDecoder) readSlice(kind Kind) ([]byte, uint32, error) {
+	b, size, err := decodeSlice(d.b, kind)
+	if d.hooks {
+		b, err = d.stepTo(b, err)
+	}
+	return b, size, err
+}
+
+func (d *BufferDecoder) readBytes(dst []byte) (b []byte, value []byte, err error) {
+	if d.options.ReadOnly {
+		dst = nil
+	}
+	if alloc := d.alloc(); alloc != nil && dst == nil {
+		b, value, err = decodeBytesAlloc(d.b, alloc)
+	} else {
+		b, value, err = decodeBytes(d.b, dst)
+	}
+	if d.hooks {
+		b, err = d.stepTo(b, err)
+	}
+	return
+}
+
+func (d *BufferDecoder) readString() (b []byte, value string, err error) {
+	// An interned string would outlive storage that's reused, so it's always allocated
+	if alloc := d.alloc(); alloc != nil && d.options.InternString == nil {
+		b, value, err = decodeStringAlloc(d.b, alloc)
+	} else {
+		b, value, err = decodeString(d.b)
+	}
+	if d.hooks {
+		b, err = d.stepTo(b, err)
+	}
+	if err == nil {
+		value = d.intern(value)
+	}
+	return
+}
+
+// stepHooks does the work of step for the options that are set. It's kept out of line so that
+// step stays cheap enough to inline.
+//
+//go:noinline
+func (d *BufferDecoder) stepHooks(err error) error {
+	if d.allowed != nil {
+		if len(d.last) > len(d.b) && !d.allowed[d.last[0]] {
+			d.b, err = d.last, ErrDisallowedKind
+		}
+		d.last = d.b
+	}
+	if d.options.Schema != nil {
+		err = d.checkSchema(err)
+	}
+	if d.options.Progress != nil {
+		if consumed := d.total - len(d.b); consumed-d.reported >= d.options.ProgressInterval && consumed > d.reported {
+			d.reported = consumed
+			d.options.Progress(consumed, d.total)
+		}
+	}
+	if d.options.Trace != nil {
+		d.trace(err)
+	}
+	if d.options.MaxOps > 0 {
+		if d.ops++; d.ops > d.options.MaxOps && err == nil {
+			err = ErrBudgetExceeded
+		}
+	}
+	if err != nil && d.options.ErrorContext > 0 {
+		if _, ok := err.(*DecodeError); !ok {
+			err = d.errorContext(err)
+		}
+	}
+	return err
+}
+
+// checkSchema checks the kind of a value read from where the next top-level value starts against
+// the Schema, given the error of the read. A read that starts before the next value is inside the
+// current one, and isn't checked.
+func (d *BufferDecoder) checkSchema(err error) error {
+	start := d.schemaNext
+	if len(start) == 0 || len(d.b) > len(start) || (len(d.b) == len(start) && err == nil) {
+		return err
+	}
+	kind := Kind(start[0])
+	if d.schemaField >= len(d.options.Schema) {
+		d.b, d.last = start, start
+		return fmt.Errorf("%w: value %d of a schema of %d", ErrFieldOutOfRange, d.schemaField, len(d.options.Schema))
+	}
+	if want := d.options.Schema[d.schemaField]; kind != want && kind != NilKind {
+		d.b, d.last = start, start
+		return &UnexpectedKindError{Field: d.schemaField, Got: kind, Want: want}
+	}
+	if err != nil {
+		return err
+	}
+	// A value that can't be skipped fails to decode further on, so there's no next value to check
+	next, skipErr := skipValue(start, 0)
+	if skipErr != nil {
+		next = nil
+	}
+	d.schemaField, d.schemaNext = d.schemaField+1, next
+	return nil
+}
+
+// checkElements bounds the size of a collection by MaxElements and by the remaining bytes,
+// given that every element takes at least min bytes, before anything is allocated for it.
+func (d *BufferDecoder) checkElements(size uint64, min int, invalid error) error {
+	if d.options.MaxElements > 0 && size > uint64(d.options.MaxElements) {
+		return ErrTooManyElements
+	}
+	if size > uint64(len(d.b)/min) {
+		return invalid
+	}
+	return nil
+}
+
+// Remaining returns the number of bytes left to decode, which was len(*d) when BufferDecoder
+// was a byte slice.
+func (d *BufferDecoder) Remaining() int {
+	return len(d.b)
+}
+
+// PeekKind returns the kind of the next value without consuming it,
+// or io.ErrUnexpectedEOF if there are no values left.
+func (d *BufferDecoder) PeekKind() (Kind, error) {
+	if len(d.b) == 0 {
+		return NilKind, io.ErrUnexpectedEOF
+	}
+	return Kind(d.b[0]), nil
+}
+
+// KindMismatchError is returned by Expect when the next value isn't of the expected
+// kind, and matches ErrKindMismatch with errors.Is.
+type KindMismatchError struct {
+	Got  Kind
+	Want Kind
+}
+
+func (e *KindMismatchError) Error() string {
+	return fmt.Sprintf("%s: got %s, want %s", ErrKindMismatch, e.Got, e.Want)
+}
+
+func (e *KindMismatchError) Unwrap() error {
+	return ErrKindMismatch
+}
+
+// UnexpectedKindError is returned by reads from a Decoder with a Schema when a top-level value
+// isn't of the kind the Schema expects, and matches ErrKindMismatch with errors.Is.
+type UnexpectedKindError struct {
+	// Field is the index of the value in the message and in the Schema.
+	Field int
+	Got   Kind
+	Want  Kind
+}
+
+func (e *UnexpectedKindError) Error() string {
+	return fmt.Sprintf("%s: field %d is %s, want %s", ErrKindMismatch, e.Field, e.Got, e.Want)
+}
+
+func (e *UnexpectedKindError) Unwrap() error {
+	return ErrKindMismatch
+}
+
+// DecodeError is returned by reads from a Decoder created with the ErrorContext option,
+// and matches the error it wraps with errors.Is.
+type DecodeError struct {
+	err     error
+	offset  int
+	snippet string
+}
+
+func (d *BufferDecoder) errorContext(err error) *DecodeError {
+	offset := d.total - len(d.b)
+	start, end := max(offset-d.options.ErrorContext, 0), min(offset+d.options.ErrorContext+1, d.total)
+	var snippet strings.Builder
+	for i := start; i < end; i++ {
+		if i > start {
+			snippet.WriteByte(' ')
+		}
+		if i == offset {
+			fmt.Fprintf(&snippet, "[%02x]", d.origin[i])
+		} else {
+			fmt.Fprintf(&snippet, "%02x", d.origin[i])
+		}
+	}
+	if offset == d.total {
+		if snippet.Len() > 0 {
+			snippet.WriteByte(' ')
+		}
+		snippet.WriteString("[]")
+	}
+	return &DecodeError{err: err, offset: offset, snippet: snippet.String()}
+}
+
+// Offset returns the position of the failing value in the buffer the Decoder was created with.
+func (e *DecodeError) Offset() int {
+	return e.offset
+}
+
+// Snippet returns the bytes around Offset in hex, with the byte at Offset in brackets,
+// or empty brackets if the buffer ended at Offset.
+func (e *DecodeError) Snippet() string {
+	return e.snippet
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("%s at offset %d: %s", e.err, e.offset, e.snippet)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.err
+}
+
+// Expect returns a *KindMismatchError naming both kinds if the next value isn't of kind k,
+// which is more helpful while developing than the error of the method that fails to decode it.
+// Nothing is consumed either way.
+func (d *BufferDecoder) Expect(k Kind) error {
+	got, err := d.PeekKind()
+	if err != nil {
+		return err
+	}
+	if got != k {
+		return &KindMismatchError{Got: got, Want: k}
+	}
+	return nil
+}
+
+// FieldCountMismatchError is returned by Finish when the message didn't hold the number of
+// top-level values given to ExpectFields, and matches ErrFieldCountMismatch with errors.Is.
+type FieldCountMismatchError struct {
+	Got  int
+	Want int
+}
+
+func (e *FieldCountMismatchError) Error() string {
+	return fmt.Sprintf("%s: got %d, want %d", ErrFieldCountMismatch, e.Got, e.Want)
+}
+
+func (e *FieldCountMismatchError) Unwrap() error {
+	return ErrFieldCountMismatch
+}
+
+// ExpectFields makes Finish check that exactly n top-level values were decoded from this point
+// on, which guards a fixed-schema message against truncation or extra fields. A value counts
+// once however it was read, so a slice counts as one field along with all of its elements.
+// Unless AllowTrailing is set, values left over after the message count too, so that Finish
+// reports extra fields as a *FieldCountMismatchError rather than ErrTrailingData.
+func (d *BufferDecoder) ExpectFields(n int) {
+	d.expectFields, d.fields, d.fieldsFrom = true, n, d.b
+}
+
+// countFields counts the values from where ExpectFields was called up to the current position,
+// or up to the end of the buffer unless AllowTrailing is set.
+func (d *BufferDecoder) countFields() (int, error) {
+	end := len(d.b)
+	if !d.options.AllowTrailing {
+		end = 0
+	}
+	b, count := d.fieldsFrom, 0
+	for len(b) > end {
+		var err error
+		if b, err = skipValue(b, 0); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Finish should be called once the whole message has been decoded, and returns ErrTrailingData
+// if any bytes remain unless the Decoder was created with AllowTrailing set. Any progress not
+// yet reported because of the ProgressInterval is reported by Finish, and the number of fields
+// is checked if ExpectFields was called.
+func (d *BufferDecoder) Finish() error {
+	if d.options.Progress != nil && d.total-len(d.b) > d.reported {
+		d.reported = d.total - len(d.b)
+		d.options.Progress(d.reported, d.total)
+	}
+	if d.expectFields {
+		if count, err := d.countFields(); err == nil && count != d.fields {
+			return &FieldCountMismatchError{Got: count, Want: d.fields}
+		}
+	}
+	if len(d.b) > 0 && !d.options.AllowTrailing {
+		return ErrTrailingData
+	}
+	return nil
+}
+
 func (d *BufferDecoder) Nil() (value bool) {
-	*d, value = decodeNil(*d)
+	d.b, value = d.readNil()
 	return
 }
 
 func (d *BufferDecoder) Map(keyKind, valueKind Kind) (size uint32, err error) {
-	*d, size, err = decodeMap(*d, keyKind, valueKind)
+	d.b, size, err = d.readMap(keyKind, valueKind)
 	return
 }
 
 func (d *BufferDecoder) Slice(kind Kind) (size uint32, err error) {
-	*d, size, err = decodeSlice(*d, kind)
+	d.b, size, err = d.readSlice(kind)
+	return
+}
+
+// MapHeader reads a map header without requiring the key and value kinds up front,
+// returning them along with the number of entries that follow.
+func (d *BufferDecoder) MapHeader() (keyKind, valueKind Kind, size uint32, err error) {
+	d.b, keyKind, valueKind, size, err = decodeMapHeader(d.b)
+	err = d.step(err)
 	return
 }
 
+// SliceHeader reads a slice header without requiring the element kind up front,
+// returning the element kind and the number of elements that follow.
+func (d *BufferDecoder) SliceHeader() (kind Kind, size uint32, err error) {
+	d.b, kind, size, err = decodeSliceHeader(d.b)
+	err = d.step(err)
+	return
+}
+
+// Bytes decodes a byte slice by appending it to b[:0], so the result shares b's backing array
+// when b has enough capacity and is otherwise grown by append, which may over-allocate. A nil b
+// on a Decoder with an Arena or AllocBytes allocates the result from them instead. Use BytesExact instead when
+// the result should always be a new slice of exactly the decoded length.
 func (d *BufferDecoder) Bytes(b []byte) (value []byte, err error) {
-	*d, value, err = decodeBytes(*d, b)
+	d.b, value, err = d.readBytes(b)
+	return
+}
+
+// BytesExact decodes a byte slice into a newly allocated slice of exactly the decoded length,
+// which never aliases the Decoder's buffer, a caller provided slice or an Arena.
+func (d *BufferDecoder) BytesExact() (value []byte, err error) {
+	d.b, value, err = decodeBytesExact(d.b)
+	err = d.step(err)
+	return
+}
+
+// BytesArray decodes a byte slice into dst, which is typically a slice of a fixed size array
+// like a [32]byte key, failing with ErrWrongBytesLength unless the lengths match exactly.
+func (d *BufferDecoder) BytesArray(dst []byte) (err error) {
+	d.b, err = decodeBytesArray(d.b, dst)
+	err = d.step(err)
 	return
 }
 
 func (d *BufferDecoder) String() (value string, err error) {
-	*d, value, err = decodeString(*d)
+	d.b, value, err = d.readString()
+	return
+}
+
+// alloc returns the function used to allocate decoded bytes and strings,
+// or nil if they should be allocated by the decode functions themselves.
+func (d *BufferDecoder) alloc() func(n int) []byte {
+	if d.arena != nil {
+		return d.arena.alloc
+	}
+	return d.options.AllocBytes
+}
+
+// intern passes value through the InternString option, if it's set.
+func (d *BufferDecoder) intern(value string) string {
+	if d.options.InternString != nil {
+		return d.options.InternString(value)
+	}
+	return value
+}
+
+func (d *BufferDecoder) BytesVar(b []byte) (value []byte, err error) {
+	if d.options.ReadOnly {
+		b = nil
+	}
+	d.b, value, err = decodeBytesVar(d.b, b)
+	err = d.step(err)
+	return
+}
+
+func (d *BufferDecoder) StringVar() (value string, err error) {
+	d.b, value, err = decodeStringVar(d.b)
+	err = d.step(err)
+	if err == nil {
+		value = d.intern(value)
+	}
 	return
 }
 
 func (d *BufferDecoder) Error() (value, err error) {
-	*d, value, err = decodeError(*d)
+	d.b, value, err = d.readError()
 	return
 }
 
 func (d *BufferDecoder) Bool() (value bool, err error) {
-	*d, value, err = decodeBool(*d)
+	d.b, value, err = d.readBool()
 	return
 }
 
 func (d *BufferDecoder) Uint8() (value uint8, err error) {
-	*d, value, err = decodeUint8(*d)
+	d.b, value, err = d.readUint8()
 	return
 }
 
 func (d *BufferDecoder) Uint16() (value uint16, err error) {
-	*d, value, err = decodeUint16(*d)
+	d.b, value, err = d.readUint16()
 	return
 }
 
 func (d *BufferDecoder) Uint32() (value uint32, err error) {
-	*d, value, err = decodeUint32(*d)
+	d.b, value, err = d.readUint32()
 	return
 }
 
 func (d *BufferDecoder) Uint64() (value uint64, err error) {
-	*d, value, err = decodeUint64(*d)
+	d.b, value, err = d.readUint64()
 	return
 }
 
 func (d *BufferDecoder) Int32() (value int32, err error) {
-	*d, value, err = decodeInt32(*d)
+	d.b, value, err = d.readInt32()
 	return
 }
 
 func (d *BufferDecoder) Int64() (value int64, err error) {
-	*d, value, err = decodeInt64(*d)
+	d.b, value, err = d.readInt64()
 	return
 }
 
 func (d *BufferDecoder) Float32() (value float32, err error) {
-	*d, value, err = decodeFloat32(*d)
+	d.b, value, err = d.readFloat32()
 	return
 }
 
 func (d *BufferDecoder) Float64() (value float64, err error) {
-	*d, value, err = decodeFloat64(*d)
+	d.b, value, err = d.readFloat64()
+	return
+}
+
+func (d *BufferDecoder) IsEmpty() (value bool) {
+	start := d.b
+	d.b, value = decodeEmpty(d.b)
+	if d.step(nil) != nil {
+		d.b = start
+		return false
+	}
+	return
+}
+
+// ReadTyped decodes the next scalar value, whatever its kind, and returns the kind
+// alongside it, or io.ErrUnexpectedEOF if there is no value left. Slices and maps must be
+// decoded with Slice and Map instead.
+func (d *BufferDecoder) ReadTyped() (kind Kind, value any, err error) {
+	d.b, kind, value, err = decodeTyped(d.b)
+	err = d.step(err)
+	if s, ok := value.(string); ok && err == nil {
+		value = d.intern(s)
+	}
 	return
 }
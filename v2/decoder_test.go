@@ -20,7 +20,12 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"errors"
+	"fmt"
+	"io"
+	"net/netip"
 	"testing"
+	"time"
+	"unsafe"
 )
 
 func TestDecoderNil(t *testing.T) {
@@ -142,6 +147,36 @@ func TestDecoderSlice(t *testing.T) {
 	assert.Equal(t, float64(1), n)
 }
 
+func TestDecoderSliceHeader(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	m := []uint32{1, 2, 3}
+
+	e := Encoder(p).SliceHeader(Uint32Kind, uint32(len(m)))
+	for _, v := range m {
+		e.Uint32(v)
+	}
+
+	d := Decoder(p.Bytes())
+	kind, size, err := d.SliceHeader()
+	assert.NoError(t, err)
+	assert.Equal(t, Uint32Kind, kind)
+	assert.Equal(t, uint32(len(m)), size)
+
+	mv := make([]uint32, size)
+	for i := range mv {
+		mv[i], err = d.Uint32()
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, m, mv)
+
+	kind, size, err = d.SliceHeader()
+	assert.ErrorIs(t, err, ErrInvalidSlice)
+	assert.Equal(t, Kind(0), kind)
+	assert.Equal(t, uint32(0), size)
+}
+
 func TestDecoderBytes(t *testing.T) {
 	t.Parallel()
 
@@ -169,6 +204,66 @@ func TestDecoderBytes(t *testing.T) {
 	assert.Equal(t, float64(1), n)
 }
 
+func TestDecoderBytesExact(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	v := []byte("Test String")
+
+	Encoder(p).Bytes(v).Bytes(nil)
+
+	d := Decoder(p.Bytes())
+	value, err := d.BytesExact()
+	assert.NoError(t, err)
+	assert.Equal(t, v, value)
+	assert.Equal(t, len(v), cap(value))
+
+	p.Bytes()[3] = 'X'
+	assert.Equal(t, v, value)
+
+	value, err = d.BytesExact()
+	assert.NoError(t, err)
+	assert.NotNil(t, value)
+	assert.Empty(t, value)
+
+	_, err = d.BytesExact()
+	assert.ErrorIs(t, err, ErrInvalidBytes)
+
+	_, err = Decoder(p.Bytes()[:5]).BytesExact()
+	assert.ErrorIs(t, err, ErrInvalidBytes)
+}
+
+func TestDecoderBytesArray(t *testing.T) {
+	t.Parallel()
+
+	var key [32]byte
+	for i := range key {
+		key[i] = byte(i)
+	}
+
+	p := NewBuffer()
+	Encoder(p).Bytes(key[:]).Bytes(key[:31]).Bytes(append(key[:], 32))
+
+	var dst [32]byte
+	d := Decoder(p.Bytes())
+	assert.NoError(t, d.BytesArray(dst[:]))
+	assert.Equal(t, key, dst)
+
+	dst = [32]byte{}
+	remaining := d.Remaining()
+	assert.ErrorIs(t, d.BytesArray(dst[:]), ErrWrongBytesLength)
+	assert.Equal(t, remaining, d.Remaining())
+	assert.Equal(t, [32]byte{}, dst)
+	assert.NoError(t, d.Skip())
+
+	assert.ErrorIs(t, d.BytesArray(dst[:]), ErrWrongBytesLength)
+	assert.Equal(t, [32]byte{}, dst)
+	assert.NoError(t, d.Skip())
+
+	assert.ErrorIs(t, d.BytesArray(dst[:]), ErrInvalidBytes)
+	assert.ErrorIs(t, Decoder(p.Bytes()[:20]).BytesArray(dst[:]), ErrInvalidBytes)
+}
+
 func TestDecoderString(t *testing.T) {
 	t.Parallel()
 
@@ -462,3 +557,687 @@ func TestDecoderFloat64(t *testing.T) {
 	})
 	assert.Equal(t, float64(1), n)
 }
+
+func TestDecoderEmpty(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).Empty().String("").Nil()
+
+	d := Decoder(p.Bytes())
+	assert.False(t, d.Nil())
+	assert.True(t, d.IsEmpty())
+	assert.False(t, d.IsEmpty())
+
+	value, err := d.String()
+	assert.NoError(t, err)
+	assert.Equal(t, "", value)
+
+	assert.False(t, d.IsEmpty())
+	assert.True(t, d.Nil())
+	assert.Equal(t, 0, d.Remaining())
+}
+
+func TestDecoderReadTyped(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).Nil().String("Test String").Uint32(32).Int64(-64).Bool(true).Float64(1.5).Bytes([]byte("Test Bytes")).Slice(0, StringKind)
+
+	expected := []struct {
+		kind  Kind
+		value any
+	}{
+		{NilKind, nil},
+		{StringKind, "Test String"},
+		{Uint32Kind, uint32(32)},
+		{Int64Kind, int64(-64)},
+		{BoolKind, true},
+		{Float64Kind, 1.5},
+		{BytesKind, []byte("Test Bytes")},
+	}
+
+	d := Decoder(p.Bytes())
+	for _, e := range expected {
+		kind, value, err := d.ReadTyped()
+		assert.NoError(t, err)
+		assert.Equal(t, e.kind, kind)
+		assert.Equal(t, e.value, value)
+	}
+
+	kind, value, err := d.ReadTyped()
+	assert.ErrorIs(t, err, ErrContainerKind)
+	assert.Equal(t, SliceKind, kind)
+	assert.Nil(t, value)
+
+	size, err := d.Slice(StringKind)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(0), size)
+
+	_, _, err = d.ReadTyped()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	d = Decoder([]byte{Uint32RawKind})
+	kind, _, err = d.ReadTyped()
+	assert.ErrorIs(t, err, ErrInvalidUint32)
+	assert.Equal(t, Uint32Kind, kind)
+	assert.Equal(t, 1, d.Remaining())
+
+	d = Decoder([]byte{0xFF})
+	_, _, err = d.ReadTyped()
+	assert.ErrorIs(t, err, ErrUnsupportedKind)
+}
+
+func TestDecoderAny(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).Map(2, StringKind, AnyKind).String("a").Slice(2, Uint8Kind).Uint8(1).Uint8(2).String("b").Nil().Int64(-64)
+
+	d := Decoder(p.Bytes())
+	value, err := d.Any()
+	assert.NoError(t, err)
+	assert.Equal(t, map[any]any{"a": []any{uint8(1), uint8(2)}, "b": nil}, value)
+
+	value, err = d.Any()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-64), value)
+
+	_, err = d.Any()
+	assert.ErrorIs(t, err, ErrUnsupportedKind)
+
+	p.Reset()
+	Encoder(p).Map(1, SliceKind, BoolKind).Slice(0, BoolKind).Bool(true)
+	_, err = Decoder(p.Bytes()).Any()
+	assert.ErrorIs(t, err, ErrInvalidMap)
+
+	p.Reset()
+	Encoder(p).Slice(1<<20, AnyKind)
+	_, err = Decoder(p.Bytes()).Any()
+	assert.ErrorIs(t, err, ErrInvalidSlice)
+}
+
+func TestEncodeAny(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	p := NewBuffer()
+	Encoder(p).Slice(10, AnyKind).
+		Map(1, StringKind, AnyKind).String("a").Slice(2, Uint8Kind).Uint8(1).Uint8(2).
+		Int64(-64).Uint16(16).Float32(3.5).Bool(true).Bytes([]byte("raw")).
+		StringVar("var").TimeRange(start, start.Add(time.Hour)).NetipAddr(netip.MustParseAddr("10.0.0.1"))
+	EncodeFixedSlice(Encoder(p), []float64{1, 2, 3})
+
+	value, err := Decoder(p.Bytes()).Any()
+	assert.NoError(t, err)
+
+	reencoded := NewBuffer()
+	assert.NoError(t, EncodeAny(Encoder(reencoded), value))
+	again, err := Decoder(reencoded.Bytes()).Any()
+	assert.NoError(t, err)
+	assert.Equal(t, value, again)
+
+	// Encoding again gives identical bytes, as the only map has a single entry and so a fixed order.
+	stable := NewBuffer()
+	assert.NoError(t, EncodeAny(Encoder(stable), again))
+	assert.Equal(t, reencoded.Bytes(), stable.Bytes())
+
+	p.Reset()
+	assert.NoError(t, EncodeAny(Encoder(p), map[string]any{"n": 1, "s": []any{"x", nil}}))
+	value, err = Decoder(p.Bytes()).Any()
+	assert.NoError(t, err)
+	assert.Equal(t, map[any]any{"n": int64(1), "s": []any{"x", nil}}, value)
+
+	// A failure part way through a value leaves nothing of it behind
+	n := p.Len()
+	assert.ErrorIs(t, EncodeAny(Encoder(p), struct{}{}), ErrUnsupportedType)
+	assert.ErrorIs(t, EncodeAny(Encoder(p), []any{"x", map[any]any{"k": int8(1)}}), ErrUnsupportedType)
+	assert.Equal(t, n, p.Len())
+
+	cyclic := []any{nil}
+	cyclic[0] = cyclic
+	assert.ErrorIs(t, EncodeAny(Encoder(p), cyclic), ErrUnsupportedType)
+	assert.Equal(t, n, p.Len())
+}
+
+func TestDecoderClone(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).String("Test String").Uint32(32)
+
+	d := Decoder(p.Bytes())
+	c := d.Clone()
+
+	_, err := c.Uint32()
+	assert.ErrorIs(t, err, ErrInvalidUint32)
+
+	s, err := c.String()
+	assert.NoError(t, err)
+	assert.Equal(t, "Test String", s)
+	assert.Equal(t, len(p.Bytes()), d.Remaining())
+
+	*d = *c
+	v, err := d.Uint32()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(32), v)
+	assert.Equal(t, 0, d.Remaining())
+	assert.Equal(t, 2, c.Remaining())
+}
+
+func TestDecoderFinish(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).Uint32(32)
+	p.Write([]byte{0, 0, 0})
+
+	d := Decoder(p.Bytes())
+	v, err := d.Uint32()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(32), v)
+	assert.ErrorIs(t, d.Finish(), ErrTrailingData)
+
+	d = DecoderWithOptions(p.Bytes(), DecoderOptions{AllowTrailing: true})
+	v, err = d.Uint32()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(32), v)
+	assert.NoError(t, d.Finish())
+
+	d = Decoder(p.Bytes()[:len(p.Bytes())-3])
+	_, err = d.Uint32()
+	assert.NoError(t, err)
+	assert.NoError(t, d.Finish())
+}
+
+func TestDecoderExpectFields(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).String("name").Slice(3, Uint32Kind).Uint32(1).Uint32(2).Uint32(3).Bool(true)
+
+	decode := func(d *BufferDecoder, fields int) {
+		_, err := d.String()
+		assert.NoError(t, err)
+		if fields > 1 {
+			size, err := d.Slice(Uint32Kind)
+			assert.NoError(t, err)
+			for i := uint32(0); i < size; i++ {
+				_, err = d.Uint32()
+				assert.NoError(t, err)
+			}
+		}
+		if fields > 2 {
+			_, err = d.Bool()
+			assert.NoError(t, err)
+		}
+	}
+
+	d := Decoder(p.Bytes())
+	d.ExpectFields(3)
+	decode(d, 3)
+	assert.NoError(t, d.Finish())
+
+	// Too many fields in the message are reported instead of ErrTrailingData
+	d = Decoder(p.Bytes())
+	d.ExpectFields(2)
+	decode(d, 2)
+	err := d.Finish()
+	assert.ErrorIs(t, err, ErrFieldCountMismatch)
+	var mismatch *FieldCountMismatchError
+	assert.True(t, errors.As(err, &mismatch))
+	assert.Equal(t, &FieldCountMismatchError{Got: 3, Want: 2}, mismatch)
+	assert.EqualError(t, err, "unexpected number of fields: got 3, want 2")
+
+	// Too few fields in the message
+	d = Decoder(p.Bytes())
+	d.ExpectFields(4)
+	decode(d, 3)
+	assert.Equal(t, &FieldCountMismatchError{Got: 3, Want: 4}, d.Finish())
+
+	// With AllowTrailing only the fields that were decoded count
+	d = DecoderWithOptions(p.Bytes(), DecoderOptions{AllowTrailing: true})
+	d.ExpectFields(2)
+	decode(d, 2)
+	assert.NoError(t, d.Finish())
+	d = DecoderWithOptions(p.Bytes(), DecoderOptions{AllowTrailing: true})
+	d.ExpectFields(3)
+	decode(d, 1)
+	assert.Equal(t, &FieldCountMismatchError{Got: 1, Want: 3}, d.Finish())
+
+	// Fields are counted from where ExpectFields is called
+	d = Decoder(p.Bytes())
+	_, err = d.String()
+	assert.NoError(t, err)
+	d.ExpectFields(2)
+	assert.NoError(t, d.Skip())
+	_, err = d.Bool()
+	assert.NoError(t, err)
+	assert.NoError(t, d.Finish())
+
+	// Trailing bytes that aren't values are still ErrTrailingData
+	q := NewBuffer()
+	q.Write(p.Bytes())
+	q.Write([]byte{0xFF})
+	d = Decoder(q.Bytes())
+	d.ExpectFields(3)
+	decode(d, 3)
+	assert.ErrorIs(t, d.Finish(), ErrTrailingData)
+}
+
+func TestDecoderProgress(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	e := Encoder(p).Slice(64, BytesKind)
+	for i := 0; i < 64; i++ {
+		e.Bytes(make([]byte, 30))
+	}
+	e.Uint8(1)
+
+	var calls [][2]int
+	d := DecoderWithOptions(p.Bytes(), DecoderOptions{
+		Progress: func(consumed, total int) {
+			calls = append(calls, [2]int{consumed, total})
+		},
+		ProgressInterval: 512,
+	})
+	size, err := d.Slice(BytesKind)
+	assert.NoError(t, err)
+	for i := uint32(0); i < size; i++ {
+		_, err = d.Bytes(nil)
+		assert.NoError(t, err)
+	}
+	_, err = d.Uint8()
+	assert.NoError(t, err)
+	assert.NoError(t, d.Finish())
+
+	assert.Greater(t, len(calls), 2)
+	assert.Less(t, len(calls), 10)
+	for i, call := range calls {
+		assert.Equal(t, len(p.Bytes()), call[1])
+		if i > 0 {
+			assert.Greater(t, call[0], calls[i-1][0])
+		}
+	}
+	assert.Equal(t, len(p.Bytes()), calls[len(calls)-1][0])
+
+	calls = nil
+	d = DecoderWithOptions(p.Bytes(), DecoderOptions{
+		Progress: func(consumed, total int) {
+			calls = append(calls, [2]int{consumed, total})
+		},
+	})
+	assert.NoError(t, d.Skip())
+	assert.NoError(t, d.Skip())
+	assert.NoError(t, d.Finish())
+	assert.Equal(t, [][2]int{{len(p.Bytes()) - 2, len(p.Bytes())}, {len(p.Bytes()), len(p.Bytes())}}, calls)
+}
+
+func TestDecoderMaxOps(t *testing.T) {
+	t.Parallel()
+
+	// No single collection is large, so MaxElements doesn't help,
+	// but together they make the decoder do over 10,000 reads.
+	p := NewBuffer()
+	e := Encoder(p).Slice(100, SliceKind)
+	for i := 0; i < 100; i++ {
+		e.Slice(100, BoolKind)
+		for j := 0; j < 100; j++ {
+			e.Bool(true)
+		}
+	}
+
+	options := DecoderOptions{MaxElements: 100, MaxOps: 100 + 100*100 + 1}
+	value, err := DecoderWithOptions(p.Bytes(), options).Any()
+	assert.NoError(t, err)
+	assert.Len(t, value, 100)
+
+	options.MaxOps = 5000
+	_, err = DecoderWithOptions(p.Bytes(), options).Any()
+	assert.ErrorIs(t, err, ErrBudgetExceeded)
+
+	var v [][]bool
+	assert.ErrorIs(t, MarshalOptions{}.Decode(DecoderWithOptions(p.Bytes(), options), &v), ErrBudgetExceeded)
+
+	// Once exceeded, the budget stays exceeded, even after reads that can't report it.
+	d := DecoderWithOptions(p.Bytes(), DecoderOptions{MaxOps: 1})
+	_, _, err = d.SliceHeader()
+	assert.NoError(t, err)
+	assert.False(t, d.Nil())
+	_, _, err = d.SliceHeader()
+	assert.ErrorIs(t, err, ErrBudgetExceeded)
+	_, err = d.Bool()
+	assert.ErrorIs(t, err, ErrBudgetExceeded)
+
+	// Nil and IsEmpty can't return the error, so they report that there's no Nil or Empty
+	p.Reset()
+	Encoder(p).Nil().Empty().Nil()
+	d = DecoderWithOptions(p.Bytes(), DecoderOptions{MaxOps: 1})
+	assert.True(t, d.Nil())
+	assert.False(t, d.IsEmpty())
+	assert.Equal(t, p.Len()-1, d.Remaining())
+	d = DecoderWithOptions(p.Bytes()[1:], DecoderOptions{MaxOps: 1})
+	assert.True(t, d.IsEmpty())
+	assert.False(t, d.Nil())
+	assert.Equal(t, p.Len()-2, d.Remaining())
+}
+
+func TestDecoderAllowedKinds(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).Slice(2, AnyKind).String("ok").Uint32(32).Float64(6.4)
+	options := DecoderOptions{AllowedKinds: []Kind{SliceKind, StringKind, Uint32Kind}}
+
+	d := DecoderWithOptions(p.Bytes(), options)
+	value, err := d.Any()
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"ok", uint32(32)}, value)
+
+	remaining := d.Remaining()
+	_, err = d.Float64()
+	assert.ErrorIs(t, err, ErrDisallowedKind)
+	assert.Equal(t, remaining, d.Remaining())
+	assert.ErrorIs(t, d.Skip(), ErrDisallowedKind)
+	_, err = d.Any()
+	assert.ErrorIs(t, err, ErrDisallowedKind)
+
+	// An injected value is rejected as soon as it's reached, even inside a container.
+	p.Reset()
+	Encoder(p).Slice(2, AnyKind).String("ok").Map(0, StringKind, StringKind)
+	_, err = DecoderWithOptions(p.Bytes(), options).Any()
+	assert.ErrorIs(t, err, ErrDisallowedKind)
+
+	var v []any
+	p.Reset()
+	Encoder(p).Slice(2, AnyKind).String("ok").Float64(6.4)
+	assert.ErrorIs(t, MarshalOptions{}.Decode(DecoderWithOptions(p.Bytes(), options), &v), ErrDisallowedKind)
+
+	// Nil and IsEmpty can't return the error, so they report that there's no Nil or Empty
+	p.Reset()
+	Encoder(p).Nil().Empty().Uint32(32)
+	d = DecoderWithOptions(p.Bytes(), DecoderOptions{AllowedKinds: []Kind{Uint32Kind}})
+	assert.False(t, d.Nil())
+	assert.False(t, d.Nil())
+	assert.Equal(t, p.Len(), d.Remaining())
+	d = DecoderWithOptions(p.Bytes()[1:], DecoderOptions{AllowedKinds: []Kind{Uint32Kind}})
+	assert.False(t, d.IsEmpty())
+	assert.Equal(t, p.Len()-1, d.Remaining())
+}
+
+func TestDecoderInternString(t *testing.T) {
+	t.Parallel()
+
+	interned := make(map[string]string)
+	calls := 0
+	options := DecoderOptions{InternString: func(s string) string {
+		calls++
+		if v, ok := interned[s]; ok {
+			return v
+		}
+		interned[s] = "interned:" + s
+		return interned[s]
+	}}
+
+	p := NewBuffer()
+	Encoder(p).String("name").String("name").StringVar("id").Map(1, StringKind, StringKind).String("key").String("value")
+
+	d := DecoderWithOptions(p.Bytes(), options)
+	value, err := d.String()
+	assert.NoError(t, err)
+	assert.Equal(t, "interned:name", value)
+	value, err = d.String()
+	assert.NoError(t, err)
+	assert.Equal(t, "interned:name", value)
+	value, err = d.StringVar()
+	assert.NoError(t, err)
+	assert.Equal(t, "interned:id", value)
+
+	var m map[string]string
+	assert.NoError(t, MarshalOptions{}.Decode(d, &m))
+	assert.Equal(t, map[string]string{"interned:key": "interned:value"}, m)
+	assert.NoError(t, d.Finish())
+	assert.Equal(t, 5, calls)
+
+	p.Reset()
+	Encoder(p).Slice(2, StringKind).String("name").String("other")
+	a, err := DecoderWithOptions(p.Bytes(), options).Any()
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"interned:name", "interned:other"}, a)
+	assert.Equal(t, 7, calls)
+
+	_, err = DecoderWithOptions([]byte{StringRawKind}, options).String()
+	assert.Error(t, err)
+	assert.Equal(t, 7, calls)
+}
+
+func TestDecoderAllocBytes(t *testing.T) {
+	t.Parallel()
+
+	var sizes []int
+	pool := make([]byte, 0, 64)
+	options := DecoderOptions{AllocBytes: func(n int) []byte {
+		sizes = append(sizes, n)
+		pool = pool[:len(pool)+n]
+		return pool[len(pool)-n:]
+	}}
+
+	p := NewBuffer()
+	Encoder(p).Bytes([]byte("bytes")).String("string").String("").Bytes([]byte("caller"))
+
+	d := DecoderWithOptions(p.Bytes(), options)
+	b, err := d.Bytes(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("bytes"), b)
+	s, err := d.String()
+	assert.NoError(t, err)
+	assert.Equal(t, "string", s)
+	s, err = d.String()
+	assert.NoError(t, err)
+	assert.Equal(t, "", s)
+	b, err = d.Bytes(make([]byte, 0, 8))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("caller"), b)
+	assert.NoError(t, d.Finish())
+
+	assert.Equal(t, []int{5, 6}, sizes)
+	assert.Equal(t, "bytesstring", string(pool))
+
+	// Interned strings aren't decoded into storage that the pool will reuse
+	sizes = nil
+	options.InternString = func(s string) string { return s }
+	d = DecoderWithOptions(p.Bytes(), options)
+	_, err = d.Bytes(nil)
+	assert.NoError(t, err)
+	s, err = d.String()
+	assert.NoError(t, err)
+	assert.Equal(t, "string", s)
+	assert.Equal(t, []int{5}, sizes)
+
+	// Storage shorter than asked for fails rather than panicking
+	short := DecoderOptions{AllocBytes: func(n int) []byte { return make([]byte, n-1) }}
+	d = DecoderWithOptions(p.Bytes(), short)
+	_, err = d.Bytes(nil)
+	assert.ErrorIs(t, err, ErrShortAlloc)
+	assert.Equal(t, p.Len(), d.Remaining())
+	d = DecoderWithOptions(p.Bytes()[8:], short)
+	_, err = d.String()
+	assert.ErrorIs(t, err, ErrShortAlloc)
+}
+
+func TestDecoderExpect(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).Uint32(32)
+
+	d := Decoder(p.Bytes())
+	kind, err := d.PeekKind()
+	assert.NoError(t, err)
+	assert.Equal(t, Uint32Kind, kind)
+
+	err = d.Expect(StringKind)
+	assert.ErrorIs(t, err, ErrKindMismatch)
+	assert.EqualError(t, err, "unexpected kind: got Uint32, want String")
+	var mismatch *KindMismatchError
+	assert.ErrorAs(t, err, &mismatch)
+	assert.Equal(t, Uint32Kind, mismatch.Got)
+	assert.Equal(t, StringKind, mismatch.Want)
+	assert.Equal(t, p.Len(), d.Remaining())
+
+	assert.NoError(t, d.Expect(Uint32Kind))
+	_, err = d.Uint32()
+	assert.NoError(t, err)
+
+	_, err = d.PeekKind()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.ErrorIs(t, d.Expect(Uint32Kind), io.ErrUnexpectedEOF)
+
+	assert.Equal(t, "Kind(200)", Kind(200).String())
+	assert.Equal(t, "Flags", FlagsKind.String())
+}
+
+func TestDecoderErrorContext(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).Uint32(5).Uint32(7)
+	b := p.Bytes()
+	assert.Equal(t, 4, len(b))
+
+	d := DecoderWithOptions(b, DecoderOptions{ErrorContext: 2})
+	v, err := d.Uint32()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(5), v)
+
+	_, err = d.String()
+	assert.ErrorIs(t, err, ErrInvalidString)
+	var decodeErr *DecodeError
+	assert.True(t, errors.As(err, &decodeErr))
+	assert.Equal(t, 2, decodeErr.Offset())
+	assert.Equal(t, fmt.Sprintf("%02x %02x [%02x] %02x", b[0], b[1], b[2], b[3]), decodeErr.Snippet())
+	assert.Equal(t, fmt.Sprintf("%s at offset 2: %s", ErrInvalidString, decodeErr.Snippet()), err.Error())
+
+	v, err = d.Uint32()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(7), v)
+
+	_, err = d.Uint32()
+	assert.ErrorIs(t, err, ErrInvalidUint32)
+	assert.True(t, errors.As(err, &decodeErr))
+	assert.Equal(t, 4, decodeErr.Offset())
+	assert.Equal(t, fmt.Sprintf("%02x %02x []", b[2], b[3]), decodeErr.Snippet())
+
+	// Errors from nested reads are only wrapped once
+	d = DecoderWithOptions(b[:1], DecoderOptions{ErrorContext: 2})
+	_, err = d.Any()
+	assert.True(t, errors.As(err, &decodeErr))
+	assert.Equal(t, 0, decodeErr.Offset())
+	_, nested := errors.Unwrap(err).(*DecodeError)
+	assert.False(t, nested)
+
+	_, err = Decoder(b[:1]).Uint32()
+	assert.Equal(t, ErrInvalidUint32, err)
+}
+
+func TestDecoderReadOnly(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).Bytes([]byte("first value")).Bytes([]byte("second")).BytesVar([]byte("third"))
+	EncodeFixedSlice(Encoder(p), []uint32{1, 2, 3})
+
+	// A slice that aliases the buffer, like one returned by RawMessage, passed for reuse
+	// makes a normal Decoder overwrite the buffer with the value it decodes.
+	mapped := append([]byte(nil), p.Bytes()...)
+	d := Decoder(mapped)
+	raw, err := d.RawMessage()
+	assert.NoError(t, err)
+	_, err = d.Bytes(raw)
+	assert.NoError(t, err)
+	assert.NotEqual(t, p.Bytes(), mapped)
+
+	// The region stands in for a read-only mapping, so it must be left exactly as it was
+	mapped = append([]byte(nil), p.Bytes()...)
+	d = DecoderReadOnly(mapped)
+	raw, err = d.RawMessage()
+	assert.NoError(t, err)
+	value, err := d.Bytes(raw)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("second"), value)
+	value[0] = 'S'
+
+	value, err = d.BytesVar(mapped[1:4])
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("third"), value)
+	value[0] = 'T'
+
+	ret := unsafe.Slice((*uint32)(unsafe.Pointer(&mapped[0])), len(mapped)/4)[:0]
+	values, err := DecodeFixedSlice(d, ret)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{1, 2, 3}, values)
+	values[0] = 0xFFFFFFFF
+
+	assert.NoError(t, d.Finish())
+	assert.Equal(t, p.Bytes(), mapped)
+}
+
+func TestDecoderSchema(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).String("name").Uint32(7).Slice(2, Int64Kind).Int64(-1).Int64(1).Nil()
+	schema := []Kind{StringKind, Uint32Kind, SliceKind, BoolKind}
+
+	d := DecoderSchema(p.Bytes(), schema)
+	name, err := d.String()
+	assert.NoError(t, err)
+	assert.Equal(t, "name", name)
+	count, err := d.Uint32()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(7), count)
+	size, err := d.Slice(Int64Kind)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2), size)
+	// The elements are inside the Slice, so aren't checked against the next field's kind
+	for _, want := range []int64{-1, 1} {
+		value, err := d.Int64()
+		assert.NoError(t, err)
+		assert.Equal(t, want, value)
+	}
+	// An optional field left out is accepted whatever kind the schema expects
+	assert.True(t, d.Nil())
+	assert.NoError(t, d.Finish())
+
+	// Swapping two fields is caught at the first of them, whether or not the read itself fails
+	swapped := NewBuffer()
+	Encoder(swapped).Uint32(7).String("name")
+	d = DecoderSchema(swapped.Bytes(), schema)
+	_, err = d.String()
+	assert.ErrorIs(t, err, ErrKindMismatch)
+	var unexpected *UnexpectedKindError
+	assert.ErrorAs(t, err, &unexpected)
+	assert.Equal(t, &UnexpectedKindError{Field: 0, Got: Uint32Kind, Want: StringKind}, unexpected)
+	assert.Equal(t, swapped.Bytes(), d.b)
+
+	d = DecoderSchema(swapped.Bytes(), schema)
+	_, err = d.Any()
+	assert.ErrorAs(t, err, &unexpected)
+	assert.Equal(t, &UnexpectedKindError{Field: 0, Got: Uint32Kind, Want: StringKind}, unexpected)
+	assert.Equal(t, swapped.Bytes(), d.b)
+
+	d = DecoderSchema(p.Bytes(), []Kind{StringKind, Int64Kind})
+	_, err = d.String()
+	assert.NoError(t, err)
+	_, err = d.Uint32()
+	assert.ErrorAs(t, err, &unexpected)
+	assert.Equal(t, &UnexpectedKindError{Field: 1, Got: Uint32Kind, Want: Int64Kind}, unexpected)
+
+	// Values past the end of the schema aren't expected at all
+	d = DecoderSchema(p.Bytes(), []Kind{StringKind})
+	_, err = d.String()
+	assert.NoError(t, err)
+	_, err = d.Uint32()
+	assert.ErrorIs(t, err, ErrFieldOutOfRange)
+}
@@ -0,0 +1,149 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"slices"
+)
+
+// DeltaEncoder encodes a sequence of uint64s as the difference from the previous value in the
+// sequence, starting from zero. Each difference is encoded as an Int64, whose zig-zag varint
+// takes a byte or two for the small steps of timestamps or sequence numbers, whichever way
+// they move, rather than the full width of the values themselves.
+type DeltaEncoder struct {
+	e    *BufferEncoder
+	prev uint64
+}
+
+func NewDeltaEncoder(e *BufferEncoder) *DeltaEncoder {
+	return &DeltaEncoder{e: e}
+}
+
+func (d *DeltaEncoder) Uint64(value uint64) *DeltaEncoder {
+	// Wrapping subtraction gives the right difference for any pair of values.
+	d.e.Int64(int64(value - d.prev))
+	d.prev = value
+	return d
+}
+
+// Reset starts a new sequence, so the next value is encoded as its difference from zero.
+func (d *DeltaEncoder) Reset() {
+	d.prev = 0
+}
+
+// DeltaDecoder decodes a sequence of uint64s encoded with a DeltaEncoder.
+type DeltaDecoder struct {
+	d    *BufferDecoder
+	prev uint64
+}
+
+func NewDeltaDecoder(d *BufferDecoder) *DeltaDecoder {
+	return &DeltaDecoder{d: d}
+}
+
+func (d *DeltaDecoder) Uint64() (uint64, error) {
+	delta, err := d.d.Int64()
+	if err != nil {
+		return 0, err
+	}
+	d.prev += uint64(delta)
+	return d.prev, nil
+}
+
+// Reset starts a new sequence, matching a Reset of the DeltaEncoder.
+func (d *DeltaDecoder) Reset() {
+	d.prev = 0
+}
+
+// EncodeMapSortedDelta encodes m with its keys in ascending order, each written as an untagged
+// varint of its difference from the previous key, followed by the values of the given kind in
+// the same order, with encode writing each value. Keys that are clustered together take a byte
+// or two each instead of the full width of a Uint64, and the output is deterministic.
+func EncodeMapSortedDelta[V any](e *BufferEncoder, valueKind Kind, m map[uint64]V, encode func(*BufferEncoder, V)) *BufferEncoder {
+	keys := make([]uint64, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+
+	b := (*Buffer)(e)
+	b.Grow(2 + VarIntLen64)
+	b.b[b.offset] = DeltaMapRawKind
+	b.b[b.offset+1] = byte(valueKind)
+	b.offset += 2
+	writeUvarint(b, uint64(len(keys)))
+	var prev uint64
+	for _, k := range keys {
+		writeUvarint(b, k-prev)
+		prev = k
+	}
+	for _, k := range keys {
+		encode(e, m[k])
+	}
+	return e
+}
+
+func decodeDeltaMapKeys(b []byte, valueKind Kind, maxElements int) ([]byte, []uint64, error) {
+	if len(b) < 3 || b[0] != DeltaMapRawKind || b[1] != byte(valueKind) {
+		return b, nil, ErrInvalidDeltaMap
+	}
+	remaining, size, ok := readUvarint(b[2:])
+	if !ok {
+		return b, nil, ErrInvalidDeltaMap
+	}
+	if maxElements > 0 && size > uint64(maxElements) {
+		return b, nil, ErrTooManyElements
+	}
+	// Every key takes at least a byte, and so does its value
+	if size > uint64(len(remaining)/2) {
+		return b, nil, ErrInvalidDeltaMap
+	}
+	keys := make([]uint64, size)
+	var prev uint64
+	for i := range keys {
+		var delta uint64
+		if remaining, delta, ok = readUvarint(remaining); !ok {
+			return b, nil, ErrInvalidDeltaMap
+		}
+		// Keys are strictly increasing, so only the first delta can be zero
+		if (i > 0 && delta == 0) || prev+delta < prev {
+			return b, nil, ErrInvalidDeltaMap
+		}
+		prev += delta
+		keys[i] = prev
+	}
+	return remaining, keys, nil
+}
+
+// DecodeMapSortedDelta decodes a map encoded with EncodeMapSortedDelta, with decode reading each value.
+func DecodeMapSortedDelta[V any](d *BufferDecoder, valueKind Kind, decode func(*BufferDecoder) (V, error)) (map[uint64]V, error) {
+	var keys []uint64
+	var err error
+	d.b, keys, err = decodeDeltaMapKeys(d.b, valueKind, d.options.MaxElements)
+	if err = d.step(err); err != nil {
+		return nil, err
+	}
+	m := make(map[uint64]V, len(keys))
+	for _, k := range keys {
+		v, err := decode(d)
+		if err != nil {
+			return nil, err
+		}
+		m[k] = v
+	}
+	return m, nil
+}
@@ -0,0 +1,133 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"math"
+	"testing"
+)
+
+func TestDelta(t *testing.T) {
+	t.Parallel()
+
+	values := []uint64{1700000000000, 1700000000005, 1700000000010, 1700000000009, 0, math.MaxUint64, 1}
+
+	p := NewBuffer()
+	e := NewDeltaEncoder(Encoder(p))
+	for _, v := range values {
+		e.Uint64(v)
+	}
+
+	d := NewDeltaDecoder(Decoder(p.Bytes()))
+	for _, v := range values {
+		value, err := d.Uint64()
+		assert.NoError(t, err)
+		assert.Equal(t, v, value)
+	}
+	_, err := d.Uint64()
+	assert.ErrorIs(t, err, ErrInvalidInt64)
+
+	// After the first, increasing timestamps take two bytes each rather than the seven of a Uint64.
+	p.Reset()
+	e.Reset()
+	start := NewBuffer()
+	Encoder(start).Uint64(values[0])
+	for i := 0; i < 100; i++ {
+		e.Uint64(values[0] + uint64(i)*5)
+	}
+	assert.Equal(t, start.Len()+99*2, p.Len())
+
+	d = NewDeltaDecoder(Decoder(p.Bytes()))
+	for i := 0; i < 100; i++ {
+		value, err := d.Uint64()
+		assert.NoError(t, err)
+		assert.Equal(t, values[0]+uint64(i)*5, value)
+	}
+}
+
+func TestMapSortedDelta(t *testing.T) {
+	t.Parallel()
+
+	m := map[uint64]string{1700000000010: "c", 1700000000000: "a", 1700000000005: "b", 0: "zero", math.MaxUint64: "max"}
+	encode := func(e *BufferEncoder, v string) { e.String(v) }
+	decode := func(d *BufferDecoder) (string, error) { return d.String() }
+
+	p := NewBuffer()
+	EncodeMapSortedDelta(Encoder(p), StringKind, m, encode)
+
+	// The output doesn't depend on map iteration order
+	for i := 0; i < 10; i++ {
+		q := NewBuffer()
+		EncodeMapSortedDelta(Encoder(q), StringKind, m, encode)
+		assert.Equal(t, p.Bytes(), q.Bytes())
+	}
+
+	decoded, err := DecodeMapSortedDelta(Decoder(p.Bytes()), StringKind, decode)
+	assert.NoError(t, err)
+	assert.Equal(t, m, decoded)
+
+	n, complete, err := MessageComplete(p.Bytes())
+	assert.NoError(t, err)
+	assert.True(t, complete)
+	assert.Equal(t, p.Len(), n)
+	_, _, err = Decoder(p.Bytes()).ReadTyped()
+	assert.ErrorIs(t, err, ErrContainerKind)
+
+	for i := 1; i < p.Len(); i++ {
+		_, complete, err = MessageComplete(p.Bytes()[:i])
+		assert.NoError(t, err)
+		assert.False(t, complete)
+		_, err = DecodeMapSortedDelta(Decoder(p.Bytes()[:i]), StringKind, decode)
+		assert.Error(t, err)
+	}
+
+	// Rather than seven bytes for each tagged Uint64 key, the first key takes six
+	// and the rest a byte each, and the header is two bytes shorter
+	clustered := make(map[uint64]bool)
+	for i := uint64(0); i < 100; i++ {
+		clustered[1700000000000+i*3] = true
+	}
+	p.Reset()
+	EncodeMapSortedDelta(Encoder(p), BoolKind, clustered, func(e *BufferEncoder, v bool) { e.Bool(v) })
+	plain := NewBuffer()
+	Encoder(plain).Map(100, Uint64Kind, BoolKind)
+	for k, v := range clustered {
+		Encoder(plain).Uint64(k).Bool(v)
+	}
+	assert.Equal(t, plain.Len()-100*7+6+99-2, p.Len())
+
+	p.Reset()
+	EncodeMapSortedDelta(Encoder(p), StringKind, map[uint64]string{}, encode)
+	decoded, err = DecodeMapSortedDelta(Decoder(p.Bytes()), StringKind, decode)
+	assert.NoError(t, err)
+	assert.Empty(t, decoded)
+
+	_, err = DecodeMapSortedDelta(Decoder(p.Bytes()), BoolKind, decode)
+	assert.ErrorIs(t, err, ErrInvalidDeltaMap)
+
+	// A repeated key is a zero delta after the first
+	p.Reset()
+	p.Write([]byte{DeltaMapRawKind, StringRawKind, 2, 5, 0})
+	Encoder(p).String("a").String("b")
+	_, err = DecodeMapSortedDelta(Decoder(p.Bytes()), StringKind, decode)
+	assert.ErrorIs(t, err, ErrInvalidDeltaMap)
+
+	_, err = DecodeMapSortedDelta(DecoderWithOptions(p.Bytes(), DecoderOptions{MaxElements: 1}), StringKind, decode)
+	assert.ErrorIs(t, err, ErrTooManyElements)
+}
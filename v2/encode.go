@@ -17,50 +17,182 @@
 package polyglot
 
 import (
+	"fmt"
 	"math"
 	"unsafe"
 )
 
 var (
-	NilRawKind     = byte(0)
-	SliceRawKind   = byte(1)
-	MapRawKind     = byte(2)
-	AnyRawKind     = byte(3)
-	BytesRawKind   = byte(4)
-	StringRawKind  = byte(5)
-	ErrorRawKind   = byte(6)
-	BoolRawKind    = byte(7)
-	Uint8RawKind   = byte(8)
-	Uint16RawKind  = byte(9)
-	Uint32RawKind  = byte(10)
-	Uint64RawKind  = byte(11)
-	Int32RawKind   = byte(12)
-	Int64RawKind   = byte(13)
-	Float32RawKind = byte(14)
-	Float64RawKind = byte(15)
+	NilRawKind           = byte(0)
+	SliceRawKind         = byte(1)
+	MapRawKind           = byte(2)
+	AnyRawKind           = byte(3)
+	BytesRawKind         = byte(4)
+	StringRawKind        = byte(5)
+	ErrorRawKind         = byte(6)
+	BoolRawKind          = byte(7)
+	Uint8RawKind         = byte(8)
+	Uint16RawKind        = byte(9)
+	Uint32RawKind        = byte(10)
+	Uint64RawKind        = byte(11)
+	Int32RawKind         = byte(12)
+	Int64RawKind         = byte(13)
+	Float32RawKind       = byte(14)
+	Float64RawKind       = byte(15)
+	EmptyRawKind         = byte(16)
+	NetipAddrRawKind     = byte(17)
+	NetipPrefixRawKind   = byte(18)
+	BytesVarRawKind      = byte(19)
+	StringVarRawKind     = byte(20)
+	HardwareAddrRawKind  = byte(21)
+	TimeZoneRawKind      = byte(22)
+	BigFloatRawKind      = byte(23)
+	FixedSliceRawKind    = byte(24)
+	URLRawKind           = byte(25)
+	ColorRawKind         = byte(26)
+	SetRawKind           = byte(27)
+	FloatTextRawKind     = byte(28)
+	CheckedUint64RawKind = byte(29)
+	StringUTF16RawKind   = byte(30)
+	RegexpRawKind        = byte(31)
+	SparseSliceRawKind   = byte(32)
+	TimeRangeRawKind     = byte(33)
+	LatLngRawKind        = byte(34)
+	FlagsRawKind         = byte(35)
+	RLESliceRawKind      = byte(36)
+	StaticUint32RawKind  = byte(37)
+	ResultRawKind        = byte(38)
+	FlagByteRawKind      = byte(39)
+	RatRawKind           = byte(40)
+	PackedSliceRawKind   = byte(41)
+	Base64RawKind        = byte(42)
+	PrefixUint64RawKind  = byte(43)
+	PrefixInt64RawKind   = byte(44)
+	DeltaMapRawKind      = byte(45)
+	HistogramRawKind     = byte(46)
+	UnixMillisRawKind    = byte(47)
+	ChecksumSliceRawKind = byte(48)
+	AddrRawKind          = byte(49)
+	ScheduleRawKind      = byte(50)
 )
 
 type Kind byte
 
 var (
-	NilKind     = Kind(NilRawKind)
-	SliceKind   = Kind(SliceRawKind)
-	MapKind     = Kind(MapRawKind)
-	AnyKind     = Kind(AnyRawKind)
-	BytesKind   = Kind(BytesRawKind)
-	StringKind  = Kind(StringRawKind)
-	ErrorKind   = Kind(ErrorRawKind)
-	BoolKind    = Kind(BoolRawKind)
-	Uint8Kind   = Kind(Uint8RawKind)
-	Uint16Kind  = Kind(Uint16RawKind)
-	Uint32Kind  = Kind(Uint32RawKind)
-	Uint64Kind  = Kind(Uint64RawKind)
-	Int32Kind   = Kind(Int32RawKind)
-	Int64Kind   = Kind(Int64RawKind)
-	Float32Kind = Kind(Float32RawKind)
-	Float64Kind = Kind(Float64RawKind)
+	NilKind           = Kind(NilRawKind)
+	SliceKind         = Kind(SliceRawKind)
+	MapKind           = Kind(MapRawKind)
+	AnyKind           = Kind(AnyRawKind)
+	BytesKind         = Kind(BytesRawKind)
+	StringKind        = Kind(StringRawKind)
+	ErrorKind         = Kind(ErrorRawKind)
+	BoolKind          = Kind(BoolRawKind)
+	Uint8Kind         = Kind(Uint8RawKind)
+	Uint16Kind        = Kind(Uint16RawKind)
+	Uint32Kind        = Kind(Uint32RawKind)
+	Uint64Kind        = Kind(Uint64RawKind)
+	Int32Kind         = Kind(Int32RawKind)
+	Int64Kind         = Kind(Int64RawKind)
+	Float32Kind       = Kind(Float32RawKind)
+	Float64Kind       = Kind(Float64RawKind)
+	EmptyKind         = Kind(EmptyRawKind)
+	NetipAddrKind     = Kind(NetipAddrRawKind)
+	NetipPrefixKind   = Kind(NetipPrefixRawKind)
+	BytesVarKind      = Kind(BytesVarRawKind)
+	StringVarKind     = Kind(StringVarRawKind)
+	HardwareAddrKind  = Kind(HardwareAddrRawKind)
+	TimeZoneKind      = Kind(TimeZoneRawKind)
+	BigFloatKind      = Kind(BigFloatRawKind)
+	FixedSliceKind    = Kind(FixedSliceRawKind)
+	URLKind           = Kind(URLRawKind)
+	ColorKind         = Kind(ColorRawKind)
+	SetKind           = Kind(SetRawKind)
+	FloatTextKind     = Kind(FloatTextRawKind)
+	CheckedUint64Kind = Kind(CheckedUint64RawKind)
+	StringUTF16Kind   = Kind(StringUTF16RawKind)
+	RegexpKind        = Kind(RegexpRawKind)
+	SparseSliceKind   = Kind(SparseSliceRawKind)
+	TimeRangeKind     = Kind(TimeRangeRawKind)
+	LatLngKind        = Kind(LatLngRawKind)
+	FlagsKind         = Kind(FlagsRawKind)
+	RLESliceKind      = Kind(RLESliceRawKind)
+	StaticUint32Kind  = Kind(StaticUint32RawKind)
+	ResultKind        = Kind(ResultRawKind)
+	FlagByteKind      = Kind(FlagByteRawKind)
+	RatKind           = Kind(RatRawKind)
+	PackedSliceKind   = Kind(PackedSliceRawKind)
+	Base64Kind        = Kind(Base64RawKind)
+	PrefixUint64Kind  = Kind(PrefixUint64RawKind)
+	PrefixInt64Kind   = Kind(PrefixInt64RawKind)
+	DeltaMapKind      = Kind(DeltaMapRawKind)
+	HistogramKind     = Kind(HistogramRawKind)
+	UnixMillisKind    = Kind(UnixMillisRawKind)
+	ChecksumSliceKind = Kind(ChecksumSliceRawKind)
+	AddrKind          = Kind(AddrRawKind)
+	ScheduleKind      = Kind(ScheduleRawKind)
 )
 
+var kindNames = map[Kind]string{
+	NilKind:           "Nil",
+	SliceKind:         "Slice",
+	MapKind:           "Map",
+	AnyKind:           "Any",
+	BytesKind:         "Bytes",
+	StringKind:        "String",
+	ErrorKind:         "Error",
+	BoolKind:          "Bool",
+	Uint8Kind:         "Uint8",
+	Uint16Kind:        "Uint16",
+	Uint32Kind:        "Uint32",
+	Uint64Kind:        "Uint64",
+	Int32Kind:         "Int32",
+	Int64Kind:         "Int64",
+	Float32Kind:       "Float32",
+	Float64Kind:       "Float64",
+	EmptyKind:         "Empty",
+	NetipAddrKind:     "NetipAddr",
+	NetipPrefixKind:   "NetipPrefix",
+	BytesVarKind:      "BytesVar",
+	StringVarKind:     "StringVar",
+	HardwareAddrKind:  "HardwareAddr",
+	TimeZoneKind:      "TimeZone",
+	BigFloatKind:      "BigFloat",
+	FixedSliceKind:    "FixedSlice",
+	URLKind:           "URL",
+	ColorKind:         "Color",
+	SetKind:           "Set",
+	FloatTextKind:     "FloatText",
+	CheckedUint64Kind: "CheckedUint64",
+	StringUTF16Kind:   "StringUTF16",
+	RegexpKind:        "Regexp",
+	SparseSliceKind:   "SparseSlice",
+	TimeRangeKind:     "TimeRange",
+	LatLngKind:        "LatLng",
+	FlagsKind:         "Flags",
+	RLESliceKind:      "RLESlice",
+	StaticUint32Kind:  "StaticUint32",
+	ResultKind:        "Result",
+	FlagByteKind:      "FlagByte",
+	RatKind:           "Rat",
+	PackedSliceKind:   "PackedSlice",
+	Base64Kind:        "Base64",
+	PrefixUint64Kind:  "PrefixUint64",
+	PrefixInt64Kind:   "PrefixInt64",
+	DeltaMapKind:      "DeltaMap",
+	HistogramKind:     "Histogram",
+	UnixMillisKind:    "UnixMillis",
+	ChecksumSliceKind: "ChecksumSlice",
+	AddrKind:          "Addr",
+	ScheduleKind:      "Schedule",
+}
+
+func (k Kind) String() string {
+	if name, ok := kindNames[k]; ok {
+		return name
+	}
+	return fmt.Sprintf("Kind(%d)", byte(k))
+}
+
 var (
 	falseBool = byte(0)
 	trueBool  = byte(1)
@@ -80,6 +212,7 @@ const (
 	uint64Size  = 1 + VarIntLen64
 	float32Size = 5
 	float64Size = 9
+	emptySize   = 1
 )
 
 func encodeNil(b *Buffer) {
@@ -710,3 +843,27 @@ func encodeFloat64(b *Buffer, value float64) {
 	b.b[offset] = byte(castValue)
 	b.offset = offset + 1
 }
+
+func encodeEmpty(b *Buffer) {
+	b.Grow(emptySize)
+	b.b[b.offset] = EmptyRawKind
+	b.offset++
+}
+
+// encodeBytesVar writes the length as an untagged varint rather than a full Uint32 value, so short
+// values cost 2 bytes of overhead instead of 3. The tradeoff is that the length can't be decoded on its own.
+func encodeBytesVar(b *Buffer, value []byte) {
+	b.Grow(1 + VarIntLen32 + len(value))
+	b.b[b.offset] = BytesVarRawKind
+	b.offset++
+	writeUvarint(b, uint64(len(value)))
+	b.offset += copy(b.b[b.offset:], value)
+}
+
+func encodeStringVar(b *Buffer, value string) {
+	b.Grow(1 + VarIntLen32 + len(value))
+	b.b[b.offset] = StringVarRawKind
+	b.offset++
+	writeUvarint(b, uint64(len(value)))
+	b.offset += copy(b.b[b.offset:], value)
+}
@@ -313,3 +313,32 @@ func TestEncodeFloat64(t *testing.T) {
 	})
 	assert.Zero(t, n)
 }
+
+func TestEncodeEmpty(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	encodeEmpty(p)
+
+	assert.Equal(t, 1, len(p.Bytes()))
+	assert.Equal(t, EmptyKind, Kind(p.Bytes()[0]))
+}
+
+func TestEncodeStringVar(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	encodeStringVar(p, "key")
+	assert.Equal(t, 1+1+3, len(p.Bytes()))
+	assert.Equal(t, StringVarKind, Kind(p.Bytes()[0]))
+	assert.Equal(t, byte(3), p.Bytes()[1])
+
+	q := NewBuffer()
+	encodeString(q, "key")
+	assert.Equal(t, len(q.Bytes())-1, len(p.Bytes()))
+
+	p.Reset()
+	encodeBytesVar(p, make([]byte, 300))
+	assert.Equal(t, 1+2+300, len(p.Bytes()))
+	assert.Equal(t, BytesVarKind, Kind(p.Bytes()[0]))
+}
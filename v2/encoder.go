@@ -22,6 +22,19 @@ func Encoder(b *Buffer) *BufferEncoder {
 	return (*BufferEncoder)(b)
 }
 
+// NewEncoderSize returns an Encoder writing to a new Buffer with room for a message of size
+// bytes, which can be added up with the Size functions, so that encoding it takes a single
+// allocation. Encoders make sure there's room for the longest encoding of a varint before writing
+// one, so the Buffer is a few bytes larger than size to avoid growing it for the last value.
+func NewEncoderSize(size int) *BufferEncoder {
+	return Encoder(NewBufferSize(size + uint64Size))
+}
+
+// Buffer returns the Buffer e writes to.
+func (e *BufferEncoder) Buffer() *Buffer {
+	return (*Buffer)(e)
+}
+
 func (e *BufferEncoder) Nil() *BufferEncoder {
 	encodeNil((*Buffer)(e))
 	return e
@@ -37,6 +50,20 @@ func (e *BufferEncoder) Slice(size uint32, kind Kind) *BufferEncoder {
 	return e
 }
 
+// SliceHeader writes only the header of a slice, after which the caller is
+// expected to encode exactly n elements of the given kind.
+func (e *BufferEncoder) SliceHeader(kind Kind, n uint32) *BufferEncoder {
+	encodeSlice((*Buffer)(e), n, kind)
+	return e
+}
+
+// RawMessage writes value, which must already be a complete encoded value such as
+// one returned by Decoder.RawMessage, without re-encoding it.
+func (e *BufferEncoder) RawMessage(value []byte) *BufferEncoder {
+	(*Buffer)(e).Write(value)
+	return e
+}
+
 func (e *BufferEncoder) Bytes(value []byte) *BufferEncoder {
 	encodeBytes((*Buffer)(e), value)
 	return e
@@ -47,6 +74,16 @@ func (e *BufferEncoder) String(value string) *BufferEncoder {
 	return e
 }
 
+func (e *BufferEncoder) BytesVar(value []byte) *BufferEncoder {
+	encodeBytesVar((*Buffer)(e), value)
+	return e
+}
+
+func (e *BufferEncoder) StringVar(value string) *BufferEncoder {
+	encodeStringVar((*Buffer)(e), value)
+	return e
+}
+
 func (e *BufferEncoder) Error(value error) *BufferEncoder {
 	encodeError((*Buffer)(e), value)
 	return e
@@ -96,3 +133,10 @@ func (e *BufferEncoder) Float64(value float64) *BufferEncoder {
 	encodeFloat64((*Buffer)(e), value)
 	return e
 }
+
+// Empty writes the canonical empty message, which is distinct from both Nil
+// and any zero-length value.
+func (e *BufferEncoder) Empty() *BufferEncoder {
+	encodeEmpty((*Buffer)(e))
+	return e
+}
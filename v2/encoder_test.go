@@ -98,6 +98,20 @@ func TestEncoderSlice(t *testing.T) {
 	assert.Zero(t, n)
 }
 
+func TestEncoderSliceHeader(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).SliceHeader(StringKind, 32)
+
+	q := NewBuffer()
+	Encoder(q).Slice(32, StringKind)
+
+	assert.Equal(t, q.Bytes(), p.Bytes())
+	assert.Equal(t, SliceKind, Kind(p.Bytes()[0]))
+	assert.Equal(t, StringKind, Kind(p.Bytes()[1]))
+}
+
 func TestEncoderBytes(t *testing.T) {
 	t.Parallel()
 
@@ -381,3 +395,14 @@ func TestEncoderFloat64(t *testing.T) {
 	})
 	assert.Zero(t, n)
 }
+
+func TestEncoderEmpty(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).Empty().String("")
+
+	assert.Equal(t, 1+1+1+1, len(p.Bytes()))
+	assert.Equal(t, EmptyKind, Kind(p.Bytes()[0]))
+	assert.Equal(t, StringKind, Kind(p.Bytes()[1]))
+}
@@ -0,0 +1,62 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+// FieldCount starts a message that can evolve over time by writing the number of field slots
+// that follow it. Code generated by protoc-gen-go-polyglot with the evolution option writes one
+// slot for every field number up to the highest in the message, so:
+//
+//   - New fields must be given new, higher field numbers. An older reader skips the slots it
+//     doesn't know with SkipFields, and a newer reader leaves the fields missing from an older
+//     message set to their zero values.
+//   - A removed field's number must be reserved rather than reused. Its slot is still written,
+//     as a Nil with Reserved, and decodes as the zero value in readers that still know it.
+//   - A field's type must not change, since its slot is read with the same method it was
+//     written with.
+//
+// Field numbers should be kept dense, since every number up to the highest takes a slot.
+func (e *BufferEncoder) FieldCount(n uint32) *BufferEncoder {
+	return e.Uint32(n)
+}
+
+// Reserved writes n Nil slots in place of fields that have been removed from a message started
+// with FieldCount.
+func (e *BufferEncoder) Reserved(n uint32) *BufferEncoder {
+	b := (*Buffer)(e)
+	b.Grow(int(n))
+	for i := uint32(0); i < n; i++ {
+		b.b[b.offset+int(i)] = NilRawKind
+	}
+	b.offset += int(n)
+	return e
+}
+
+// FieldCount reads the number of field slots in a message written with FieldCount.
+func (d *BufferDecoder) FieldCount() (uint32, error) {
+	return d.Uint32()
+}
+
+// SkipFields skips n field slots, such as those written by a newer version of a message started
+// with FieldCount, or those of fields that have since been removed from it.
+func (d *BufferDecoder) SkipFields(n uint32) error {
+	for i := uint32(0); i < n; i++ {
+		if err := d.Skip(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
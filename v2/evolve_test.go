@@ -0,0 +1,172 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"testing"
+)
+
+// evolveV1 and evolveV2 are two versions of the same message, written the way
+// protoc-gen-go-polyglot generates them with the evolution option. Version 2
+// removed field 2 (Legacy), and added fields 4 (Tags) and 5 (Count).
+type evolveV1 struct {
+	Name   string
+	Legacy uint32
+	Score  float64
+}
+
+func (x *evolveV1) Encode(b *Buffer) {
+	Encoder(b).FieldCount(3).String(x.Name).Uint32(x.Legacy).Float64(x.Score)
+}
+
+func (x *evolveV1) decode(d *BufferDecoder) error {
+	fields, err := d.FieldCount()
+	if err != nil {
+		return err
+	}
+	if fields >= 1 && !d.Nil() {
+		if x.Name, err = d.String(); err != nil {
+			return err
+		}
+	} else {
+		x.Name = ""
+	}
+	if fields >= 2 && !d.Nil() {
+		if x.Legacy, err = d.Uint32(); err != nil {
+			return err
+		}
+	} else {
+		x.Legacy = 0
+	}
+	if fields >= 3 && !d.Nil() {
+		if x.Score, err = d.Float64(); err != nil {
+			return err
+		}
+	} else {
+		x.Score = 0
+	}
+	if fields > 3 {
+		return d.SkipFields(fields - 3)
+	}
+	return nil
+}
+
+type evolveV2 struct {
+	Name  string
+	Score float64
+	Tags  []string
+	Count uint64
+}
+
+func (x *evolveV2) Encode(b *Buffer) {
+	Encoder(b).FieldCount(5).String(x.Name).Reserved(1).Float64(x.Score).Slice(uint32(len(x.Tags)), StringKind)
+	for _, v := range x.Tags {
+		Encoder(b).String(v)
+	}
+	Encoder(b).Uint64(x.Count)
+}
+
+func (x *evolveV2) decode(d *BufferDecoder) error {
+	fields, err := d.FieldCount()
+	if err != nil {
+		return err
+	}
+	if fields >= 1 && !d.Nil() {
+		if x.Name, err = d.String(); err != nil {
+			return err
+		}
+	} else {
+		x.Name = ""
+	}
+	if fields >= 2 {
+		if err = d.SkipFields(min(fields, 2) - 1); err != nil {
+			return err
+		}
+	}
+	if fields >= 3 && !d.Nil() {
+		if x.Score, err = d.Float64(); err != nil {
+			return err
+		}
+	} else {
+		x.Score = 0
+	}
+	if fields >= 4 && !d.Nil() {
+		var size uint32
+		if size, err = d.Slice(StringKind); err != nil {
+			return err
+		}
+		x.Tags = make([]string, size)
+		for i := range x.Tags {
+			if x.Tags[i], err = d.String(); err != nil {
+				return err
+			}
+		}
+	} else {
+		x.Tags = nil
+	}
+	if fields >= 5 && !d.Nil() {
+		if x.Count, err = d.Uint64(); err != nil {
+			return err
+		}
+	} else {
+		x.Count = 0
+	}
+	if fields > 5 {
+		return d.SkipFields(fields - 5)
+	}
+	return nil
+}
+
+func TestFieldEvolution(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+
+	// An old reader ignores the fields added by a new writer, and reads the removed field as zero
+	v2 := &evolveV2{Name: "polyglot", Score: 1.5, Tags: []string{"a", "b"}, Count: 32}
+	v2.Encode(p)
+	Encoder(p).Bool(true)
+	v1 := &evolveV1{Legacy: 7}
+	d := Decoder(p.Bytes())
+	assert.NoError(t, v1.decode(d))
+	assert.Equal(t, &evolveV1{Name: "polyglot", Score: 1.5}, v1)
+	value, err := d.Bool()
+	assert.NoError(t, err)
+	assert.True(t, value)
+
+	// A new reader skips the removed field, and leaves the fields an old writer didn't know as zero
+	p.Reset()
+	(&evolveV1{Name: "polyglot", Legacy: 7, Score: 2.5}).Encode(p)
+	v2 = &evolveV2{Tags: []string{"stale"}, Count: 1}
+	assert.NoError(t, v2.decode(Decoder(p.Bytes())))
+	assert.Equal(t, &evolveV2{Name: "polyglot", Score: 2.5}, v2)
+
+	// Each reserved slot is a single Nil
+	p.Reset()
+	Encoder(p).Reserved(3)
+	assert.Equal(t, []byte{NilRawKind, NilRawKind, NilRawKind}, p.Bytes())
+
+	d = Decoder(p.Bytes())
+	assert.NoError(t, d.SkipFields(3))
+	assert.Equal(t, 0, d.Remaining())
+	assert.Error(t, Decoder(p.Bytes()).SkipFields(4))
+
+	_, err = Decoder(p.Bytes()).FieldCount()
+	assert.ErrorIs(t, err, ErrInvalidUint32)
+}
@@ -0,0 +1,59 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"fmt"
+)
+
+// FieldError is returned by WrapFieldError, naming the field of a message that failed to decode,
+// and matches the error it wraps with errors.Is.
+type FieldError struct {
+	// Field is the path to the field, with the names of the fields of nested messages
+	// separated by dots, like "inner.value".
+	Field string
+
+	err error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("field %s: %s", e.Field, e.err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.err
+}
+
+// WrapFieldError returns err as a *FieldError naming field, or nil if err is nil, so that a
+// decoder can report which field failed rather than a bare sentinel like ErrInvalidString:
+//
+//	if x.Name, err = d.String(); err != nil {
+//		return polyglot.WrapFieldError("name", err)
+//	}
+//
+// If err is already a *FieldError, returned by the decoder of a nested message, field is
+// prepended to its path instead of wrapping it again, giving errors like
+// "field inner.value: invalid uint32 encoding".
+func WrapFieldError(field string, err error) error {
+	if err == nil {
+		return nil
+	}
+	if fieldErr, ok := err.(*FieldError); ok {
+		return &FieldError{Field: field + "." + fieldErr.Field, err: fieldErr.err}
+	}
+	return &FieldError{Field: field, err: err}
+}
@@ -0,0 +1,141 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"errors"
+	"testing"
+)
+
+// fieldErrorInner and fieldErrorOuter are written the way protoc-gen-go-polyglot generates
+// messages, with every decoding error wrapped with the name of its field from the .proto file.
+type fieldErrorInner struct {
+	Value uint32
+}
+
+func (x *fieldErrorInner) Encode(b *Buffer) {
+	if x == nil {
+		Encoder(b).Nil()
+	} else {
+		Encoder(b).Uint32(x.Value)
+	}
+}
+
+func (x *fieldErrorInner) decode(d *BufferDecoder) error {
+	if d.Nil() {
+		return nil
+	}
+	var err error
+	x.Value, err = d.Uint32()
+	if err != nil {
+		return WrapFieldError("value", err)
+	}
+	return nil
+}
+
+type fieldErrorOuter struct {
+	Name  string
+	Tags  []string
+	Inner *fieldErrorInner
+}
+
+func (x *fieldErrorOuter) Encode(b *Buffer) {
+	Encoder(b).String(x.Name).Slice(uint32(len(x.Tags)), StringKind)
+	for _, v := range x.Tags {
+		Encoder(b).String(v)
+	}
+	x.Inner.Encode(b)
+}
+
+func (x *fieldErrorOuter) Decode(b []byte) error {
+	return x.decode(Decoder(b))
+}
+
+func (x *fieldErrorOuter) decode(d *BufferDecoder) error {
+	if d.Nil() {
+		return nil
+	}
+	var err error
+	x.Name, err = d.String()
+	if err != nil {
+		return WrapFieldError("name", err)
+	}
+	var sliceSize uint32
+	sliceSize, err = d.Slice(StringKind)
+	if err != nil {
+		return WrapFieldError("tags", err)
+	}
+	if uint32(len(x.Tags)) != sliceSize {
+		x.Tags = make([]string, sliceSize)
+	}
+	for i := uint32(0); i < sliceSize; i++ {
+		x.Tags[i], err = d.String()
+		if err != nil {
+			return WrapFieldError("tags", err)
+		}
+	}
+	if !d.Nil() {
+		x.Inner = &fieldErrorInner{}
+		err = x.Inner.decode(d)
+		if err != nil {
+			return WrapFieldError("inner", err)
+		}
+	}
+	return nil
+}
+
+func TestFieldError(t *testing.T) {
+	t.Parallel()
+
+	msg := &fieldErrorOuter{Name: "name", Tags: []string{"a", "b"}, Inner: &fieldErrorInner{Value: 32}}
+	p := NewBuffer()
+	msg.Encode(p)
+
+	decoded := new(fieldErrorOuter)
+	assert.NoError(t, decoded.Decode(p.Bytes()))
+	assert.Equal(t, msg, decoded)
+
+	// Truncating the message at each field reports that field by name
+	tests := []struct {
+		length int
+		field  string
+		err    error
+	}{
+		{0, "name", ErrInvalidString},
+		{8, "tags", ErrInvalidSlice},
+		{13, "tags", ErrInvalidString},
+		{19, "inner.value", ErrInvalidUint32},
+	}
+	for _, test := range tests {
+		err := new(fieldErrorOuter).Decode(p.Bytes()[:test.length])
+		assert.ErrorIs(t, err, test.err)
+		var fieldErr *FieldError
+		if assert.ErrorAs(t, err, &fieldErr) {
+			assert.Equal(t, test.field, fieldErr.Field)
+		}
+		assert.EqualError(t, err, "field "+test.field+": "+test.err.Error())
+	}
+
+	assert.NoError(t, WrapFieldError("name", nil))
+
+	// Only a *FieldError itself is merged, so other wrapping is kept
+	wrapped := WrapFieldError("outer", errors.Join(WrapFieldError("inner", ErrInvalidBool)))
+	assert.ErrorIs(t, wrapped, ErrInvalidBool)
+	assert.Equal(t, "outer", wrapped.(*FieldError).Field)
+}
@@ -0,0 +1,121 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// SchemaFingerprint returns a 32-bit fingerprint of the layout Marshal gives values of the
+// type of v, so that a sender and receiver can detect that their types have diverged.
+//
+// The fingerprint is the FNV-1a hash, as used by FieldHash, of a description of the type:
+// scalars are described by their kind, slices, arrays and maps by their kind followed by the
+// descriptions of their elements, keys and values, and pointers by the type they point to.
+// Structs are described by their kind, then '{', then the name, a zero byte and the
+// description of each field, then '}', with a struct nested in itself described by its
+// kind alone. It therefore depends on the options, like HashedFields, that change the layout.
+func (o MarshalOptions) SchemaFingerprint(v any) (uint32, error) {
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return 0, fmt.Errorf("%w: nil has no schema", ErrUnsupportedType)
+	}
+	f := fingerprint{
+		hash:    fieldHashOffset,
+		options: o,
+		visited: make(map[reflect.Type]bool),
+	}
+	if err := f.describe(t); err != nil {
+		return 0, err
+	}
+	return f.hash, nil
+}
+
+type fingerprint struct {
+	hash    uint32
+	options MarshalOptions
+	visited map[reflect.Type]bool
+}
+
+func (f *fingerprint) write(b ...byte) {
+	for _, c := range b {
+		f.hash ^= uint32(c)
+		f.hash *= fieldHashPrime
+	}
+}
+
+func (f *fingerprint) describe(t reflect.Type) error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	kind, err := f.options.kindOf(t)
+	if err != nil {
+		return err
+	}
+	f.write(byte(kind))
+	if kind == BytesKind || kind == AnyKind {
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return f.describe(t.Elem())
+	case reflect.Map:
+		if err = f.describe(t.Key()); err != nil {
+			return err
+		}
+		return f.describe(t.Elem())
+	case reflect.Struct:
+		if f.visited[t] {
+			return nil
+		}
+		f.visited[t] = true
+		defer delete(f.visited, t)
+		info, err := getStructInfo(t, f.options.IncludeUnexported)
+		if err != nil {
+			return err
+		}
+		if f.options.NamedFields {
+			// Keyed by name rather than by hash, which is otherwise described the same
+			f.write(byte(StringKind))
+		}
+		f.write('{')
+		for _, field := range info.fields {
+			f.write([]byte(field.name)...)
+			f.write(0)
+			if err = f.describe(t.Field(field.index).Type); err != nil {
+				return err
+			}
+		}
+		f.write('}')
+	}
+	return nil
+}
+
+// DecodeVerifySchema checks the fingerprint appended to b by Marshal with AppendFingerprint,
+// returning the message without it, or ErrSchemaMismatch if it isn't expected or is missing.
+func DecodeVerifySchema(b []byte, expected uint32) ([]byte, error) {
+	if len(b) < staticUint32Size {
+		return b, ErrSchemaMismatch
+	}
+	message := b[:len(b)-staticUint32Size]
+	_, value, err := decodeStaticUint32(b[len(message):])
+	if err != nil || value != expected {
+		return b, ErrSchemaMismatch
+	}
+	return message, nil
+}
@@ -0,0 +1,98 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"testing"
+)
+
+type fingerprintNode struct {
+	Value    string
+	Children []*fingerprintNode
+}
+
+func TestSchemaFingerprint(t *testing.T) {
+	t.Parallel()
+
+	fingerprint := func(o MarshalOptions, v any) uint32 {
+		f, err := o.SchemaFingerprint(v)
+		assert.NoError(t, err)
+		return f
+	}
+
+	v1 := fingerprint(MarshalOptions{}, marshalV1{})
+	assert.Equal(t, v1, fingerprint(MarshalOptions{}, &marshalV1{}))
+	assert.Equal(t, v1, fingerprint(MarshalOptions{}, struct {
+		ID   uint32
+		Name string
+		Tags []string
+	}{}))
+
+	// Renaming a field, changing its kind or adding one all change the fingerprint.
+	assert.NotEqual(t, v1, fingerprint(MarshalOptions{}, marshalV2{}))
+	assert.NotEqual(t, v1, fingerprint(MarshalOptions{}, struct {
+		Id   uint32
+		Name string
+		Tags []string
+	}{}))
+	assert.NotEqual(t, v1, fingerprint(MarshalOptions{}, struct {
+		ID   uint64
+		Name string
+		Tags []string
+	}{}))
+	assert.NotEqual(t, v1, fingerprint(MarshalOptions{HashedFields: true}, marshalV1{}))
+	assert.NotEqual(t, fingerprint(MarshalOptions{}, []uint32{}), fingerprint(MarshalOptions{}, map[uint32]bool{}))
+
+	// The fingerprint is stable across releases.
+	assert.Equal(t, uint32(0x14526a47), fingerprint(MarshalOptions{}, fingerprintNode{}))
+
+	_, err := MarshalOptions{}.SchemaFingerprint(nil)
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+	_, err = MarshalOptions{}.SchemaFingerprint(struct{ C chan int }{})
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+}
+
+func TestAppendFingerprint(t *testing.T) {
+	t.Parallel()
+
+	options := MarshalOptions{AppendFingerprint: true}
+	b, err := options.Marshal(marshalV1{ID: 32, Name: "Test", Tags: []string{"a"}})
+	assert.NoError(t, err)
+
+	var v1 marshalV1
+	assert.NoError(t, options.Unmarshal(b, &v1))
+	assert.Equal(t, marshalV1{ID: 32, Name: "Test", Tags: []string{"a"}}, v1)
+
+	var v2 marshalV2
+	assert.ErrorIs(t, options.Unmarshal(b, &v2), ErrSchemaMismatch)
+
+	fingerprint, err := options.SchemaFingerprint(v1)
+	assert.NoError(t, err)
+	message, err := DecodeVerifySchema(b, fingerprint)
+	assert.NoError(t, err)
+	plain, err := Marshal(v1)
+	assert.NoError(t, err)
+	assert.Equal(t, plain, message)
+
+	_, err = DecodeVerifySchema(plain, fingerprint)
+	assert.ErrorIs(t, err, ErrSchemaMismatch)
+	_, err = DecodeVerifySchema(nil, fingerprint)
+	assert.ErrorIs(t, err, ErrSchemaMismatch)
+	assert.ErrorIs(t, options.Unmarshal(b, v1), ErrInvalidTarget)
+}
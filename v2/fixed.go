@@ -0,0 +1,186 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"encoding/binary"
+	"unsafe"
+)
+
+// Fixed is the set of numeric types that can be encoded as a FixedSlice.
+type Fixed interface {
+	uint32 | uint64 | int32 | int64 | float32 | float64
+}
+
+var nativeLittleEndian = binary.NativeEndian.Uint16([]byte{1, 0}) == 1
+
+// fixedKindSize returns the width in bytes of each element of a FixedSlice
+// with the given element kind, or 0 if the kind isn't supported.
+func fixedKindSize(kind byte) int {
+	switch kind {
+	case Uint32RawKind, Int32RawKind, Float32RawKind:
+		return 4
+	case Uint64RawKind, Int64RawKind, Float64RawKind:
+		return 8
+	}
+	return 0
+}
+
+func fixedKind[T Fixed]() byte {
+	var zero T
+	switch any(zero).(type) {
+	case uint32:
+		return Uint32RawKind
+	case uint64:
+		return Uint64RawKind
+	case int32:
+		return Int32RawKind
+	case int64:
+		return Int64RawKind
+	case float32:
+		return Float32RawKind
+	default:
+		return Float64RawKind
+	}
+}
+
+// encodeFixedSlice writes the element kind and count followed by every element as
+// little-endian fixed-width bytes, which on little-endian hosts is a single copy.
+func encodeFixedSlice[T Fixed](b *Buffer, values []T) {
+	size := int(unsafe.Sizeof(T(0)))
+	b.Grow(2 + VarIntLen64 + len(values)*size)
+	b.b[b.offset] = FixedSliceRawKind
+	b.b[b.offset+1] = fixedKind[T]()
+	b.offset += 2
+	writeUvarint(b, uint64(len(values)))
+	if len(values) == 0 {
+		return
+	}
+	if nativeLittleEndian {
+		b.offset += copy(b.b[b.offset:], unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(values))), len(values)*size))
+		return
+	}
+	for i := range values {
+		if size == 4 {
+			binary.LittleEndian.PutUint32(b.b[b.offset:], *(*uint32)(unsafe.Pointer(&values[i])))
+		} else {
+			binary.LittleEndian.PutUint64(b.b[b.offset:], *(*uint64)(unsafe.Pointer(&values[i])))
+		}
+		b.offset += size
+	}
+}
+
+// decodeFixedSliceHeader returns the element data of a FixedSlice of T along with its length.
+func decodeFixedSliceHeader[T Fixed](b []byte) ([]byte, []byte, int, error) {
+	if len(b) > 2 && b[0] == FixedSliceRawKind && b[1] == fixedKind[T]() {
+		size := int(unsafe.Sizeof(T(0)))
+		remaining, n, ok := readUvarint(b[2:])
+		if ok && uint64(len(remaining))/uint64(size) >= n {
+			return remaining[int(n)*size:], remaining[:int(n)*size], int(n), nil
+		}
+	}
+	return b, nil, 0, ErrInvalidFixedSlice
+}
+
+func decodeFixedSlice[T Fixed](b []byte, ret []T) ([]byte, []T, error) {
+	remaining, data, n, err := decodeFixedSliceHeader[T](b)
+	if err != nil {
+		return b, nil, err
+	}
+	if cap(ret) < n {
+		ret = make([]T, n)
+	}
+	ret = ret[:n]
+	if n == 0 {
+		return remaining, ret, nil
+	}
+	if nativeLittleEndian {
+		copy(unsafe.Slice((*byte)(unsafe.Pointer(unsafe.SliceData(ret))), len(data)), data)
+		return remaining, ret, nil
+	}
+	size := len(data) / n
+	for i := range ret {
+		if size == 4 {
+			*(*uint32)(unsafe.Pointer(&ret[i])) = binary.LittleEndian.Uint32(data[i*size:])
+		} else {
+			*(*uint64)(unsafe.Pointer(&ret[i])) = binary.LittleEndian.Uint64(data[i*size:])
+		}
+	}
+	return remaining, ret, nil
+}
+
+func unsafeDecodeFixedSlice[T Fixed](b []byte) ([]byte, []T, error) {
+	remaining, data, n, err := decodeFixedSliceHeader[T](b)
+	if err != nil {
+		return b, nil, err
+	}
+	if n > 0 && nativeLittleEndian && uintptr(unsafe.Pointer(&data[0]))%unsafe.Alignof(T(0)) == 0 {
+		return remaining, unsafe.Slice((*T)(unsafe.Pointer(&data[0])), n), nil
+	}
+	return decodeFixedSlice[T](b, nil)
+}
+
+func decodeFixedSliceTyped(b []byte) ([]byte, any, error) {
+	if len(b) < 2 {
+		return b, nil, ErrInvalidFixedSlice
+	}
+	switch b[1] {
+	case Uint32RawKind:
+		return decodeFixedSlice[uint32](b, nil)
+	case Uint64RawKind:
+		return decodeFixedSlice[uint64](b, nil)
+	case Int32RawKind:
+		return decodeFixedSlice[int32](b, nil)
+	case Int64RawKind:
+		return decodeFixedSlice[int64](b, nil)
+	case Float32RawKind:
+		return decodeFixedSlice[float32](b, nil)
+	case Float64RawKind:
+		return decodeFixedSlice[float64](b, nil)
+	}
+	return b, nil, ErrInvalidFixedSlice
+}
+
+// EncodeFixedSlice encodes values as a FixedSlice, with every element stored as
+// little-endian fixed-width bytes rather than as a tagged varint. This is larger
+// than a Slice for small values, but is much faster to encode and decode.
+func EncodeFixedSlice[T Fixed](e *BufferEncoder, values []T) *BufferEncoder {
+	encodeFixedSlice((*Buffer)(e), values)
+	return e
+}
+
+// DecodeFixedSlice decodes a FixedSlice of T, reusing ret if it has enough capacity
+// unless the Decoder has the ReadOnly option set.
+func DecodeFixedSlice[T Fixed](d *BufferDecoder, ret []T) (value []T, err error) {
+	if d.options.ReadOnly {
+		ret = nil
+	}
+	d.b, value, err = decodeFixedSlice(d.b, ret)
+	err = d.step(err)
+	return
+}
+
+// UnsafeDecodeFixedSlice decodes a FixedSlice of T without copying, by reinterpreting
+// the encoded elements in place. The returned slice aliases the Decoder's buffer, so
+// it must not be modified or retained once the buffer is reused. The zero-copy path
+// is only taken on little-endian hosts when the elements happen to be aligned for T,
+// otherwise the elements are copied into a new slice exactly as DecodeFixedSlice does.
+func UnsafeDecodeFixedSlice[T Fixed](d *BufferDecoder) (value []T, err error) {
+	d.b, value, err = unsafeDecodeFixedSlice[T](d.b)
+	err = d.step(err)
+	return
+}
@@ -0,0 +1,105 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"math"
+	"testing"
+)
+
+func testFixedSlice[T Fixed](t *testing.T, values []T) {
+	p := NewBuffer()
+	EncodeFixedSlice(Encoder(p), values)
+
+	d := Decoder(p.Bytes())
+	value, err := DecodeFixedSlice[T](d, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, values, value)
+	assert.Equal(t, 0, d.Remaining())
+
+	d = Decoder(p.Bytes())
+	value, err = UnsafeDecodeFixedSlice[T](d)
+	assert.NoError(t, err)
+	assert.Equal(t, values, value)
+	assert.Equal(t, 0, d.Remaining())
+
+	d = Decoder(p.Bytes())
+	kind, typed, err := d.ReadTyped()
+	assert.NoError(t, err)
+	assert.Equal(t, FixedSliceKind, kind)
+	assert.Equal(t, values, typed)
+
+	d = Decoder(p.Bytes())
+	assert.NoError(t, d.Skip())
+	assert.Equal(t, 0, d.Remaining())
+
+	for i := 0; i < len(p.Bytes()); i++ {
+		d = Decoder(p.Bytes()[:i])
+		_, err = DecodeFixedSlice[T](d, nil)
+		assert.ErrorIs(t, err, ErrInvalidFixedSlice)
+		assert.Equal(t, i, d.Remaining())
+	}
+}
+
+func TestFixedSlice(t *testing.T) {
+	t.Parallel()
+
+	testFixedSlice(t, []uint32{0, 1, math.MaxUint32, 1 << 20})
+	testFixedSlice(t, []uint64{0, 1, math.MaxUint64, 1 << 40})
+	testFixedSlice(t, []int32{0, -1, math.MinInt32, math.MaxInt32})
+	testFixedSlice(t, []int64{0, -1, math.MinInt64, math.MaxInt64})
+	testFixedSlice(t, []float32{0, -1.5, math.MaxFloat32, float32(math.Inf(-1))})
+	testFixedSlice(t, []float64{0, -1.5, math.MaxFloat64, math.SmallestNonzeroFloat64})
+	testFixedSlice[uint32](t, nil)
+
+	p := NewBuffer()
+	EncodeFixedSlice(Encoder(p), []uint32{1, 2, 3})
+
+	ret := make([]uint32, 0, 8)
+	value, err := DecodeFixedSlice(Decoder(p.Bytes()), ret)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{1, 2, 3}, value)
+	assert.Equal(t, &ret[:1][0], &value[0])
+
+	_, err = DecodeFixedSlice[int32](Decoder(p.Bytes()), nil)
+	assert.ErrorIs(t, err, ErrInvalidFixedSlice)
+
+	assert.ErrorIs(t, Decoder([]byte{FixedSliceRawKind, StringRawKind, 0}).Skip(), ErrInvalidFixedSlice)
+}
+
+func TestUnsafeFixedSliceAliasing(t *testing.T) {
+	t.Parallel()
+
+	if !nativeLittleEndian {
+		t.Skip("zero-copy decoding is only used on little-endian hosts")
+	}
+
+	// The Nil pads the header so that the elements start at an aligned offset.
+	p := NewBuffer()
+	EncodeFixedSlice(Encoder(p).Nil(), []uint32{1, 2, 3})
+
+	d := Decoder(p.Bytes())
+	assert.True(t, d.Nil())
+	value, err := UnsafeDecodeFixedSlice[uint32](d)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint32{1, 2, 3}, value)
+
+	p.Bytes()[4] = 10
+	assert.Equal(t, []uint32{10, 2, 3}, value)
+}
@@ -0,0 +1,150 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"math/bits"
+)
+
+// encodeFlags writes the number of bytes up to and including the one holding the highest set
+// bit, followed by those bytes in little-endian order. Unlike a varint, every byte holds 8 bits,
+// so flags in the high bits take at most 10 bytes rather than 11, and zero takes 2.
+func encodeFlags(b *Buffer, value uint64) {
+	size := (bits.Len64(value) + 7) / 8
+	b.Grow(2 + size)
+	b.b[b.offset] = FlagsRawKind
+	b.b[b.offset+1] = byte(size)
+	b.offset += 2
+	for i := 0; i < size; i++ {
+		b.b[b.offset+i] = byte(value >> (8 * i))
+	}
+	b.offset += size
+}
+
+// decodeFlags rejects trailing zero bytes, so every value has a single encoding.
+func decodeFlags(b []byte) ([]byte, uint64, error) {
+	if len(b) > 1 && b[0] == FlagsRawKind {
+		size := int(b[1])
+		if size <= 8 && len(b)-2 >= size && (size == 0 || b[1+size] != 0) {
+			var value uint64
+			for i := 0; i < size; i++ {
+				value |= uint64(b[2+i]) << (8 * i)
+			}
+			return b[2+size:], value, nil
+		}
+	}
+	return b, 0, ErrInvalidFlags
+}
+
+// Flags encodes a set of bit flags, trimmed to the bytes up to the highest set bit.
+func (e *BufferEncoder) Flags(value uint64) *BufferEncoder {
+	encodeFlags((*Buffer)(e), value)
+	return e
+}
+
+func (d *BufferDecoder) Flags() (value uint64, err error) {
+	d.b, value, err = decodeFlags(d.b)
+	err = d.step(err)
+	return
+}
+
+// FlagGroup packs up to 8 booleans into a single byte, so they take 2 bytes on the wire
+// together rather than 2 bytes each as Bools.
+type FlagGroup struct {
+	bits byte
+	n    int
+}
+
+// Add appends a flag to the group, and panics if the group already holds 8.
+func (g *FlagGroup) Add(value bool) *FlagGroup {
+	if g.n == 8 {
+		panic("polyglot: FlagGroup holds at most 8 flags")
+	}
+	if value {
+		g.bits |= 1 << g.n
+	}
+	g.n++
+	return g
+}
+
+// Get returns the flag at index i, in the order they were added. Flags
+// that weren't added, including any at an index of 8 or more, are false.
+func (g FlagGroup) Get(i int) bool {
+	return i >= 0 && i < 8 && g.bits&(1<<i) != 0
+}
+
+func encodeFlagByte(b *Buffer, value byte) {
+	b.Grow(2)
+	b.b[b.offset] = FlagByteRawKind
+	b.b[b.offset+1] = value
+	b.offset += 2
+}
+
+func decodeFlagByte(b []byte) ([]byte, FlagGroup, error) {
+	if len(b) > 1 && b[0] == FlagByteRawKind {
+		return b[2:], FlagGroup{bits: b[1], n: 8}, nil
+	}
+	return b, FlagGroup{}, ErrInvalidFlags
+}
+
+func (e *BufferEncoder) FlagGroup(value FlagGroup) *BufferEncoder {
+	encodeFlagByte((*Buffer)(e), value.bits)
+	return e
+}
+
+// FlagGroup decodes a group encoded with FlagGroup, whose flags are read back with Get.
+func (d *BufferDecoder) FlagGroup() (value FlagGroup, err error) {
+	d.b, value, err = decodeFlagByte(d.b)
+	err = d.step(err)
+	return
+}
+
+func decodeBoolRaw(b []byte) ([]byte, bool, error) {
+	if len(b) > 0 {
+		switch b[0] {
+		case trueBool:
+			return b[1:], true, nil
+		case falseBool:
+			return b[1:], false, nil
+		}
+	}
+	return b, false, ErrInvalidBool
+}
+
+// EncodeBoolRaw writes value as a single byte, 1 or 0, without the kind byte that Bool writes, for
+// messages where a schema, like generated code, already says the field is a boolean. It can
+// only be read back with DecodeBoolRaw.
+func EncodeBoolRaw(e *BufferEncoder, value bool) *BufferEncoder {
+	b := (*Buffer)(e)
+	b.Grow(1)
+	if value {
+		b.b[b.offset] = trueBool
+	} else {
+		b.b[b.offset] = falseBool
+	}
+	b.offset++
+	return e
+}
+
+// DecodeBoolRaw reads a boolean written by EncodeBoolRaw. There's no kind byte to check, so the
+// byte is taken as a value even where it isn't one; mixing raw booleans with AllowedKinds or a
+// Schema makes those check the value byte as if it were a kind.
+func DecodeBoolRaw(d *BufferDecoder) (value bool, err error) {
+	d.b, value, err = decodeBoolRaw(d.b)
+	err = d.step(err)
+	return
+}
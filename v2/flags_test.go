@@ -0,0 +1,133 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"math"
+	"testing"
+)
+
+func TestFlags(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value uint64
+		size  int
+	}{
+		{0, 2},
+		{1, 3},
+		{1 << 9, 4},
+		{1 << 63, 10},
+		{math.MaxUint64, 10},
+		{1<<40 | 1, 8},
+	}
+
+	p := NewBuffer()
+	for _, test := range tests {
+		p.Reset()
+		Encoder(p).Flags(test.value)
+		assert.Equal(t, test.size, p.Len())
+
+		d := Decoder(p.Bytes())
+		value, err := d.Flags()
+		assert.NoError(t, err)
+		assert.Equal(t, test.value, value)
+		assert.Equal(t, 0, d.Remaining())
+
+		d = Decoder(p.Bytes())
+		assert.NoError(t, d.Skip())
+		assert.Equal(t, 0, d.Remaining())
+
+		for i := 0; i < p.Len(); i++ {
+			_, _, err = decodeFlags((p.Bytes())[:i])
+			assert.ErrorIs(t, err, ErrInvalidFlags)
+		}
+	}
+
+	_, _, err := decodeFlags([]byte{FlagsRawKind, 2, 1, 0})
+	assert.ErrorIs(t, err, ErrInvalidFlags)
+	_, _, err = decodeFlags([]byte{FlagsRawKind, 9, 1, 1, 1, 1, 1, 1, 1, 1, 1})
+	assert.ErrorIs(t, err, ErrInvalidFlags)
+}
+
+func TestFlagGroup(t *testing.T) {
+	t.Parallel()
+
+	flags := []bool{true, false, false, true, true, false, true, false}
+	var g FlagGroup
+	for _, f := range flags {
+		g.Add(f)
+	}
+	assert.Panics(t, func() { g.Add(true) })
+
+	p := NewBuffer()
+	Encoder(p).FlagGroup(g).FlagGroup(*new(FlagGroup).Add(true))
+	assert.Equal(t, 4, p.Len())
+
+	d := Decoder(p.Bytes())
+	decoded, err := d.FlagGroup()
+	assert.NoError(t, err)
+	for i, f := range flags {
+		assert.Equal(t, f, decoded.Get(i))
+	}
+	assert.False(t, decoded.Get(8))
+	assert.False(t, decoded.Get(-1))
+
+	decoded, err = d.FlagGroup()
+	assert.NoError(t, err)
+	assert.True(t, decoded.Get(0))
+	assert.False(t, decoded.Get(1))
+	assert.Equal(t, 0, d.Remaining())
+
+	d = Decoder(p.Bytes())
+	assert.NoError(t, d.Skip())
+	_, err = d.Flags()
+	assert.ErrorIs(t, err, ErrInvalidFlags)
+	_, err = d.FlagGroup()
+	assert.NoError(t, err)
+
+	_, err = Decoder(p.Bytes()[:1]).FlagGroup()
+	assert.ErrorIs(t, err, ErrInvalidFlags)
+}
+
+func TestBoolRaw(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	EncodeBoolRaw(Encoder(p), true)
+	EncodeBoolRaw(Encoder(p), false).Bool(true)
+	assert.Equal(t, []byte{trueBool, falseBool, BoolRawKind, trueBool}, p.Bytes())
+
+	d := Decoder(p.Bytes())
+	value, err := DecodeBoolRaw(d)
+	assert.NoError(t, err)
+	assert.True(t, value)
+	value, err = DecodeBoolRaw(d)
+	assert.NoError(t, err)
+	assert.False(t, value)
+	// A self-describing Bool starts with its kind, which isn't a raw boolean
+	_, err = DecodeBoolRaw(d)
+	assert.ErrorIs(t, err, ErrInvalidBool)
+	value, err = d.Bool()
+	assert.NoError(t, err)
+	assert.True(t, value)
+
+	_, err = DecodeBoolRaw(d)
+	assert.ErrorIs(t, err, ErrInvalidBool)
+}
@@ -0,0 +1,61 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"strconv"
+)
+
+// floatTextLen is enough for the longest shortest representation of a float64,
+// such as -2.2250738585072014e-308, so the length is always a single byte varint.
+const floatTextLen = 24
+
+// encodeFloatText writes the shortest decimal representation of value that parses back to
+// exactly the same float64, so that dumps of the buffer show a readable value.
+func encodeFloatText(b *Buffer, value float64) {
+	b.Grow(2 + floatTextLen)
+	b.b[b.offset] = FloatTextRawKind
+	text := strconv.AppendFloat(b.b[b.offset+2:b.offset+2], value, 'g', -1, 64)
+	b.b[b.offset+1] = byte(len(text))
+	b.offset += 2 + len(text)
+}
+
+func decodeFloatText(b []byte) ([]byte, float64, error) {
+	if len(b) > 1 && b[0] == FloatTextRawKind {
+		remaining, size, ok := readUvarint(b[1:])
+		if ok && size <= uint64(len(remaining)) {
+			value, err := strconv.ParseFloat(string(remaining[:size]), 64)
+			if err == nil {
+				return remaining[size:], value, nil
+			}
+		}
+	}
+	return b, 0, ErrInvalidFloatText
+}
+
+// FloatText encodes value as its shortest decimal string rather than as a Float64, which is
+// larger but readable in a dump of the buffer. It must be decoded with FloatText.
+func (e *BufferEncoder) FloatText(value float64) *BufferEncoder {
+	encodeFloatText((*Buffer)(e), value)
+	return e
+}
+
+func (d *BufferDecoder) FloatText() (value float64, err error) {
+	d.b, value, err = decodeFloatText(d.b)
+	err = d.step(err)
+	return
+}
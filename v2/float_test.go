@@ -0,0 +1,68 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"math"
+	"testing"
+)
+
+func TestFloatText(t *testing.T) {
+	t.Parallel()
+
+	values := []float64{0, 0.1, -1.5, 1e21, 123456789.125, math.MaxFloat64, -math.SmallestNonzeroFloat64, -2.2250738585072014e-308, math.Inf(1), math.Inf(-1), math.Copysign(0, -1)}
+
+	p := NewBuffer()
+	e := Encoder(p)
+	for _, v := range values {
+		e.FloatText(v)
+	}
+	e.FloatText(math.NaN())
+
+	d := Decoder(p.Bytes())
+	for _, v := range values {
+		value, err := d.FloatText()
+		assert.NoError(t, err)
+		assert.Equal(t, math.Float64bits(v), math.Float64bits(value))
+	}
+	value, err := d.FloatText()
+	assert.NoError(t, err)
+	assert.True(t, math.IsNaN(value))
+	assert.Equal(t, 0, d.Remaining())
+
+	p.Reset()
+	Encoder(p).FloatText(0.1)
+	assert.Equal(t, []byte{FloatTextRawKind, 3, '0', '.', '1'}, p.Bytes())
+
+	d = Decoder(p.Bytes())
+	kind, typed, err := d.ReadTyped()
+	assert.NoError(t, err)
+	assert.Equal(t, FloatTextKind, kind)
+	assert.Equal(t, 0.1, typed)
+	assert.NoError(t, Decoder(p.Bytes()).Skip())
+
+	_, err = Decoder(p.Bytes()).Float64()
+	assert.ErrorIs(t, err, ErrInvalidFloat64)
+
+	_, err = Decoder(p.Bytes()[:4]).FloatText()
+	assert.ErrorIs(t, err, ErrInvalidFloatText)
+
+	_, err = Decoder([]byte{FloatTextRawKind, 3, '0', 'x', 'z'}).FloatText()
+	assert.ErrorIs(t, err, ErrInvalidFloatText)
+}
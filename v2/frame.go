@@ -0,0 +1,167 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+)
+
+// DefaultMaxFrameSize is the largest frame payload a FrameReader accepts by default.
+const DefaultMaxFrameSize = 16 << 20
+
+// frameMagic starts every frame, and is what Resync scans for. A 4-byte magic appears
+// at any given offset of random data with a probability of 1 in 2^32, and even then the
+// frame is only accepted if its CRC-32C also matches, so a false match only costs an
+// extra ReadFrame error and Resync.
+var frameMagic = []byte{0xFF, 'P', 'G', 'F'}
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// frameHeaderSize is the magic, the longest length varint and the checksum.
+var frameHeaderSize = len(frameMagic) + VarIntLen64 + 4
+
+// WriteFrame writes payload to w as a frame, made up of the frame magic, the
+// payload length as an untagged varint, the CRC-32C of the payload and the payload.
+func WriteFrame(w io.Writer, payload []byte) error {
+	header := make([]byte, 0, frameHeaderSize)
+	header = append(header, frameMagic...)
+	header = binary.AppendUvarint(header, uint64(len(payload)))
+	header = binary.LittleEndian.AppendUint32(header, crc32.Checksum(payload, crcTable))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
+
+// FrameReader reads frames written by WriteFrame, and can skip past corrupt frames with Resync.
+type FrameReader struct {
+	// MaxFrameSize is the largest payload accepted, defaulting to DefaultMaxFrameSize.
+	MaxFrameSize int
+
+	r   io.Reader
+	buf []byte
+	err error
+}
+
+func NewFrameReader(r io.Reader) *FrameReader {
+	return &FrameReader{
+		MaxFrameSize: DefaultMaxFrameSize,
+		r:            r,
+	}
+}
+
+// fill reads until at least n bytes are buffered, returning false if the reader fails first.
+func (f *FrameReader) fill(n int) bool {
+	for len(f.buf) < n {
+		if f.err != nil {
+			return false
+		}
+		if cap(f.buf)-len(f.buf) < bytes.MinRead {
+			buf := make([]byte, len(f.buf), 2*cap(f.buf)+max(n, bytes.MinRead))
+			copy(buf, f.buf)
+			f.buf = buf
+		}
+		var read int
+		read, f.err = f.r.Read(f.buf[len(f.buf):cap(f.buf)])
+		f.buf = f.buf[:len(f.buf)+read]
+	}
+	return true
+}
+
+// readErr returns the error that stopped fill, with io.EOF only
+// returned if there were no buffered bytes left to read at all.
+func (f *FrameReader) readErr() error {
+	if f.err == io.EOF && len(f.buf) > 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return f.err
+}
+
+// ReadFrame returns the payload of the next frame, which is only valid until the next call
+// to ReadFrame or Resync. If the frame is corrupt ErrInvalidFrame is returned without
+// consuming anything, and Resync can be used to skip to the start of the next frame.
+func (f *FrameReader) ReadFrame() ([]byte, error) {
+	if !f.fill(len(frameMagic)) {
+		return nil, f.readErr()
+	}
+	if !bytes.Equal(f.buf[:len(frameMagic)], frameMagic) {
+		return nil, ErrInvalidFrame
+	}
+
+	var size uint64
+	offset := len(frameMagic)
+	for shift := 0; ; shift += 7 {
+		if !f.fill(offset + 1) {
+			return nil, f.readErr()
+		}
+		if offset == len(frameMagic)+VarIntLen64 {
+			return nil, ErrInvalidFrame
+		}
+		c := f.buf[offset]
+		offset++
+		size |= uint64(c&(continuation-1)) << shift
+		if c < continuation {
+			break
+		}
+	}
+	if size > uint64(f.MaxFrameSize) {
+		return nil, ErrInvalidFrame
+	}
+
+	end := offset + 4 + int(size)
+	if !f.fill(end) {
+		return nil, f.readErr()
+	}
+	payload := f.buf[offset+4 : end]
+	if binary.LittleEndian.Uint32(f.buf[offset:]) != crc32.Checksum(payload, crcTable) {
+		return nil, ErrInvalidFrame
+	}
+	f.discard(end)
+	return payload, nil
+}
+
+// discard drops the first n buffered bytes. Bytes are moved to the front of the buffer lazily,
+// so a payload returned by ReadFrame stays intact until the following call.
+func (f *FrameReader) discard(n int) {
+	f.buf = f.buf[n:]
+}
+
+// Resync skips at least one byte, and then everything up to the start of the next frame magic,
+// so that a ReadFrame that failed with ErrInvalidFrame can be retried from the next frame. It
+// returns io.EOF if the reader ends before another frame magic is found.
+func (f *FrameReader) Resync() error {
+	if !f.fill(1) {
+		return f.readErr()
+	}
+	f.discard(1)
+	for {
+		if i := bytes.Index(f.buf, frameMagic); i >= 0 {
+			f.discard(i)
+			return nil
+		}
+		// Keep a possible partial magic at the end
+		f.discard(max(0, len(f.buf)-len(frameMagic)+1))
+		if !f.fill(len(f.buf) + 1) {
+			f.buf = f.buf[:0]
+			return f.err
+		}
+	}
+}
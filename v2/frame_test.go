@@ -0,0 +1,100 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"bytes"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestFrame(t *testing.T) {
+	t.Parallel()
+
+	payloads := [][]byte{[]byte("first"), make([]byte, 10000), {}, []byte("last")}
+	for i := range payloads[1] {
+		payloads[1][i] = byte(i)
+	}
+
+	var buf bytes.Buffer
+	for _, payload := range payloads {
+		assert.NoError(t, WriteFrame(&buf, payload))
+	}
+
+	f := NewFrameReader(iotest.OneByteReader(bytes.NewReader(buf.Bytes())))
+	for _, payload := range payloads {
+		value, err := f.ReadFrame()
+		assert.NoError(t, err)
+		assert.Equal(t, payload, value)
+	}
+	_, err := f.ReadFrame()
+	assert.ErrorIs(t, err, io.EOF)
+
+	_, err = NewFrameReader(bytes.NewReader(buf.Bytes()[:10])).ReadFrame()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	f = NewFrameReader(bytes.NewReader(buf.Bytes()))
+	f.MaxFrameSize = 1000
+	_, err = f.ReadFrame()
+	assert.NoError(t, err)
+	_, err = f.ReadFrame()
+	assert.ErrorIs(t, err, ErrInvalidFrame)
+}
+
+func TestFrameResync(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	buf.WriteString("garbage")
+	assert.NoError(t, WriteFrame(&buf, []byte("first")))
+	corrupt := buf.Len()
+	assert.NoError(t, WriteFrame(&buf, []byte("second")))
+	assert.NoError(t, WriteFrame(&buf, []byte("third")))
+	buf.WriteString("\xffPG")
+
+	// Flip a bit in the payload of the second frame
+	b := buf.Bytes()
+	b[corrupt+len(frameMagic)+1+4] ^= 0x01
+
+	f := NewFrameReader(bytes.NewReader(b))
+	_, err := f.ReadFrame()
+	assert.ErrorIs(t, err, ErrInvalidFrame)
+	assert.NoError(t, f.Resync())
+
+	value, err := f.ReadFrame()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("first"), value)
+
+	_, err = f.ReadFrame()
+	assert.ErrorIs(t, err, ErrInvalidFrame)
+	_, err = f.ReadFrame()
+	assert.ErrorIs(t, err, ErrInvalidFrame)
+	assert.NoError(t, f.Resync())
+
+	value, err = f.ReadFrame()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("third"), value)
+
+	_, err = f.ReadFrame()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.ErrorIs(t, f.Resync(), io.EOF)
+	_, err = f.ReadFrame()
+	assert.ErrorIs(t, err, io.EOF)
+}
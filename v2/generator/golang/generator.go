@@ -25,6 +25,8 @@ import (
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/types/pluginpb"
 
+	"fmt"
+	"strconv"
 	"text/template"
 )
 
@@ -34,6 +36,13 @@ type Generator struct {
 	CustomFields func() string
 	CustomEncode func() string
 	CustomDecode func() string
+
+	// Evolution makes messages encode a field count followed by a slot for every
+	// field number, so that fields can be added and removed without breaking older
+	// or newer readers, as described by polyglot.BufferEncoder.FieldCount. It's set
+	// by the evolution=true plugin parameter (--go-polyglot_opt=evolution=true with
+	// protoc), and changes the encoding of every message in the generated files.
+	Evolution bool
 }
 
 func New() *Generator {
@@ -52,6 +61,8 @@ func New() *Generator {
 		"GetEncodingFields":  GetEncodingFields,
 		"GetDecodingFields":  GetDecodingFields,
 		"GetKindLUT":         GetKindLUT,
+		"GetEvolvingFields":  GetEvolvingFields,
+		"ZeroValue":          ZeroValue,
 		"CustomFields": func() string {
 			return g.CustomFields()
 		},
@@ -61,10 +72,22 @@ func New() *Generator {
 		"CustomDecode": func() string {
 			return g.CustomDecode()
 		},
+		"Evolution": func() bool {
+			return g.Evolution
+		},
 	}).ParseFS(templates.FS, "*"))
 	g = &Generator{
 		options: &protogen.Options{
-			ParamFunc:         func(name string, value string) error { return nil },
+			ParamFunc: func(name string, value string) error {
+				if name == "evolution" {
+					evolution, err := strconv.ParseBool(value)
+					if err != nil {
+						return fmt.Errorf("invalid evolution parameter: %w", err)
+					}
+					g.Evolution = evolution
+				}
+				return nil
+			},
 			ImportRewriteFunc: func(path protogen.GoImportPath) protogen.GoImportPath { return path },
 		},
 		templ:        templ,
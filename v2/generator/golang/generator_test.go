@@ -0,0 +1,145 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package golang
+
+import (
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+)
+
+// evolvingFile describes a message that had fields 2 and 3 removed and reserved,
+// with field 8 added in a later version.
+func evolvingFile() *descriptorpb.FileDescriptorProto {
+	optional := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum()
+	repeated := descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	field := func(name string, number int32, label *descriptorpb.FieldDescriptorProto_Label, kind descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:   proto.String(name),
+			Number: proto.Int32(number),
+			Label:  label,
+			Type:   kind.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("evolve.proto"),
+		Package: proto.String("evolve"),
+		Syntax:  proto.String("proto3"),
+		Options: &descriptorpb.FileOptions{GoPackage: proto.String("example.com/evolve")},
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Level"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("LOW"), Number: proto.Int32(0)},
+				{Name: proto.String("HIGH"), Number: proto.Int32(1)},
+			},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{
+				Name:  proto.String("Inner"),
+				Field: []*descriptorpb.FieldDescriptorProto{field("value", 1, optional, descriptorpb.FieldDescriptorProto_TYPE_UINT32, "")},
+			},
+			{
+				Name: proto.String("Message"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("name", 1, optional, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+					field("tags", 4, repeated, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+					field("inner", 5, optional, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".evolve.Inner"),
+					field("labels", 6, repeated, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".evolve.Message.LabelsEntry"),
+					field("level", 7, optional, descriptorpb.FieldDescriptorProto_TYPE_ENUM, ".evolve.Level"),
+					field("data", 8, optional, descriptorpb.FieldDescriptorProto_TYPE_BYTES, ""),
+				},
+				NestedType: []*descriptorpb.DescriptorProto{{
+					Name: proto.String("LabelsEntry"),
+					Field: []*descriptorpb.FieldDescriptorProto{
+						field("key", 1, optional, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+						field("value", 2, optional, descriptorpb.FieldDescriptorProto_TYPE_UINT32, ""),
+					},
+					Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+				}},
+				ReservedRange: []*descriptorpb.DescriptorProto_ReservedRange{{Start: proto.Int32(2), End: proto.Int32(4)}},
+			},
+		},
+	}
+}
+
+func generate(t *testing.T, parameter string) (string, error) {
+	req := &pluginpb.CodeGeneratorRequest{
+		FileToGenerate: []string{"evolve.proto"},
+		ProtoFile:      []*descriptorpb.FileDescriptorProto{evolvingFile()},
+	}
+	if parameter != "" {
+		req.Parameter = proto.String(parameter)
+	}
+	res, err := New().Generate(req)
+	if err != nil {
+		return "", err
+	}
+	if !assert.Nil(t, res.Error, res.GetError()) || !assert.Len(t, res.File, 1) {
+		t.FailNow()
+	}
+	content := res.File[0].GetContent()
+	_, err = parser.ParseFile(token.NewFileSet(), res.File[0].GetName(), content, 0)
+	assert.NoError(t, err)
+	return content, nil
+}
+
+func TestGenerateEvolution(t *testing.T) {
+	t.Parallel()
+
+	content, err := generate(t, "evolution=true")
+	assert.NoError(t, err)
+
+	// Both messages start with a field count, and the reserved numbers of Message take a slot each
+	assert.Contains(t, content, "polyglot.Encoder(b).FieldCount(1)")
+	assert.Contains(t, content, "polyglot.Encoder(b).FieldCount(8)")
+	assert.Contains(t, content, "polyglot.Encoder(b).Reserved(2)")
+	assert.Contains(t, content, "err = d.SkipFields(min(fields, 3) - 2 + 1)")
+
+	// Fields missing from an older message are reset, and unknown ones from a newer message skipped
+	assert.Contains(t, content, "if fields >= 8 && !d.Nil() {")
+	assert.Contains(t, content, "x.Data = nil")
+	assert.Contains(t, content, "x.Level = 0")
+	assert.Contains(t, content, `x.Name = ""`)
+	assert.Contains(t, content, "return d.SkipFields(fields - 8)")
+
+	// Fields are written in field number order, not grouped by kind
+	encode := content[strings.Index(content, "func (x *EvolveMessage) Encode"):]
+	encode = encode[:strings.Index(encode, "\n}\n")]
+	var last int
+	for _, call := range []string{".String(x.Name)", ".Reserved(2)", "x.Tags", "x.Inner.Encode(b)", "x.Labels.Encode(b)", ".Uint32(uint32(x.Level))", ".Bytes(x.Data)"} {
+		index := strings.Index(encode, call)
+		assert.Greater(t, index, last, call)
+		last = index
+	}
+
+	content, err = generate(t, "")
+	assert.NoError(t, err)
+	assert.NotContains(t, content, "FieldCount")
+
+	_, err = generate(t, "evolution=maybe")
+	assert.Error(t, err)
+}
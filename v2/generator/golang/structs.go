@@ -166,11 +166,7 @@ func GetEncodingFields(fields protoreflect.FieldDescriptors) EncodingFields {
 					panic(errUnknownKind)
 				}
 			} else {
-				if field.Kind() == protoreflect.EnumKind {
-					values = append(values, fmt.Sprintf("%s(uint32(x.%s))", encoder, utils.CamelCase(string(field.Name()))))
-				} else {
-					values = append(values, fmt.Sprintf("%s(x.%s)", encoder, utils.CamelCase(string(field.Name()))))
-				}
+				values = append(values, encodeValue(field, encoder))
 			}
 		}
 	}
@@ -181,6 +177,13 @@ func GetEncodingFields(fields protoreflect.FieldDescriptors) EncodingFields {
 	}
 }
 
+func encodeValue(field protoreflect.FieldDescriptor, encoder string) string {
+	if field.Kind() == protoreflect.EnumKind {
+		return fmt.Sprintf("%s(uint32(x.%s))", encoder, utils.CamelCase(string(field.Name())))
+	}
+	return fmt.Sprintf("%s(x.%s)", encoder, utils.CamelCase(string(field.Name())))
+}
+
 type DecodingFields struct {
 	MessageFields []protoreflect.FieldDescriptor
 	SliceFields   []protoreflect.FieldDescriptor
@@ -242,3 +245,93 @@ func GetLUTDecoder(kind protoreflect.Kind) string {
 func GetKindLUT(kind protoreflect.Kind) string {
 	return kindLUT[kind]
 }
+
+// Slot is a field slot of a message generated with the Evolution option. It's either
+// a field, or a run of field numbers from Number to Last that have no field because
+// the fields they belonged to were removed and the numbers reserved.
+type Slot struct {
+	Field   protoreflect.FieldDescriptor
+	Number  int32
+	Last    int32
+	Slice   bool
+	Message bool
+	Value   string
+}
+
+// Reserved returns the number of reserved field numbers in the Slot,
+// or 0 if it's a field.
+func (s Slot) Reserved() int32 {
+	if s.Field != nil {
+		return 0
+	}
+	return s.Last - s.Number + 1
+}
+
+type EvolvingFields struct {
+	Slots     []Slot
+	Count     int32
+	HasSlices bool
+}
+
+// GetEvolvingFields returns a slot for every field number from 1 up to the highest number used by fields,
+// in order, with consecutive numbers that aren't used by any field combined into a single reserved Slot.
+func GetEvolvingFields(fields protoreflect.FieldDescriptors) EvolvingFields {
+	byNumber := make(map[int32]protoreflect.FieldDescriptor, fields.Len())
+	var count int32
+	for i := 0; i < fields.Len(); i++ {
+		field := fields.Get(i)
+		byNumber[int32(field.Number())] = field
+		count = max(count, int32(field.Number()))
+	}
+
+	var slots []Slot
+	var hasSlices bool
+	for number := int32(1); number <= count; number++ {
+		field, ok := byNumber[number]
+		if !ok {
+			if len(slots) > 0 && slots[len(slots)-1].Field == nil {
+				slots[len(slots)-1].Last = number
+			} else {
+				slots = append(slots, Slot{Number: number, Last: number})
+			}
+			continue
+		}
+		slot := Slot{Field: field, Number: number, Last: number}
+		if field.Cardinality() == protoreflect.Repeated && !field.IsMap() {
+			slot.Slice = true
+			hasSlices = true
+		} else if encoder, ok := encodeLUT[field.Kind()]; !ok {
+			switch field.Kind() {
+			case protoreflect.MessageKind:
+				slot.Message = true
+			default:
+				panic(errUnknownKind)
+			}
+		} else {
+			slot.Value = encodeValue(field, encoder)
+		}
+		slots = append(slots, slot)
+	}
+	return EvolvingFields{
+		Slots:     slots,
+		Count:     count,
+		HasSlices: hasSlices,
+	}
+}
+
+// ZeroValue returns the zero value of the struct field generated for field.
+func ZeroValue(field protoreflect.FieldDescriptor) string {
+	if field.Cardinality() == protoreflect.Repeated {
+		return "nil"
+	}
+	switch field.Kind() {
+	case protoreflect.BoolKind:
+		return "false"
+	case protoreflect.StringKind:
+		return `""`
+	case protoreflect.BytesKind, protoreflect.MessageKind:
+		return "nil"
+	default:
+		return "0"
+	}
+}
@@ -0,0 +1,83 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+const (
+	// latLngSize is the kind followed by the latitude and longitude as little-endian int32s.
+	latLngSize = 9
+
+	// latLngScale stores coordinates in units of 1e-7 degrees, as OpenStreetMap does, which
+	// is about a centimetre at the equator and keeps ±180 degrees within an int32.
+	latLngScale = 1e7
+)
+
+// LatLng is a latitude and longitude in degrees, as encoded by BufferEncoder.LatLng.
+type LatLng struct {
+	Lat float64
+	Lng float64
+}
+
+func validLatLng(lat, lng float64) bool {
+	return lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180
+}
+
+// encodeLatLng writes out of range or NaN coordinates as math.MinInt32, which is below
+// -180 degrees, so that they fail to decode rather than being silently clamped.
+func encodeLatLng(b *Buffer, lat, lng float64) {
+	scaledLat, scaledLng := int32(math.MinInt32), int32(math.MinInt32)
+	if validLatLng(lat, lng) {
+		scaledLat, scaledLng = int32(math.Round(lat*latLngScale)), int32(math.Round(lng*latLngScale))
+	}
+	b.Grow(latLngSize)
+	b.b[b.offset] = LatLngRawKind
+	binary.LittleEndian.PutUint32(b.b[b.offset+1:], uint32(scaledLat))
+	binary.LittleEndian.PutUint32(b.b[b.offset+5:], uint32(scaledLng))
+	b.offset += latLngSize
+}
+
+func decodeLatLng(b []byte) ([]byte, LatLng, error) {
+	if len(b) >= latLngSize && b[0] == LatLngRawKind {
+		value := LatLng{
+			Lat: float64(int32(binary.LittleEndian.Uint32(b[1:]))) / latLngScale,
+			Lng: float64(int32(binary.LittleEndian.Uint32(b[5:]))) / latLngScale,
+		}
+		if validLatLng(value.Lat, value.Lng) {
+			return b[latLngSize:], value, nil
+		}
+	}
+	return b, LatLng{}, ErrInvalidLatLng
+}
+
+// LatLng encodes a coordinate pair in 9 bytes rather than the 18 of two Float64s, rounded
+// to the nearest 1e-7 degrees. Latitudes outside ±90 and longitudes outside ±180 degrees
+// are encoded so that they fail to decode with ErrInvalidLatLng.
+func (e *BufferEncoder) LatLng(lat, lng float64) *BufferEncoder {
+	encodeLatLng((*Buffer)(e), lat, lng)
+	return e
+}
+
+func (d *BufferDecoder) LatLng() (lat, lng float64, err error) {
+	var value LatLng
+	d.b, value, err = decodeLatLng(d.b)
+	err = d.step(err)
+	return value.Lat, value.Lng, err
+}
@@ -0,0 +1,77 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"math"
+	"testing"
+)
+
+func TestLatLng(t *testing.T) {
+	t.Parallel()
+
+	coords := []LatLng{
+		{51.5074, -0.1278},
+		{-33.8688, 151.2093},
+		{90, 180},
+		{-90, -180},
+		{0, 0},
+	}
+
+	p := NewBuffer()
+	e := Encoder(p)
+	for _, c := range coords {
+		e.LatLng(c.Lat, c.Lng)
+	}
+	assert.Equal(t, len(coords)*latLngSize, p.Len())
+
+	d := Decoder(p.Bytes())
+	for _, c := range coords {
+		lat, lng, err := d.LatLng()
+		assert.NoError(t, err)
+		assert.Equal(t, c.Lat, lat)
+		assert.Equal(t, c.Lng, lng)
+	}
+	assert.Equal(t, 0, d.Remaining())
+
+	d = Decoder(p.Bytes())
+	value, err := d.Any()
+	assert.NoError(t, err)
+	assert.Equal(t, coords[0], value)
+	assert.NoError(t, d.Skip())
+
+	p.Reset()
+	Encoder(p).LatLng(12.345678912, -98.765432198)
+	lat, lng, err := Decoder(p.Bytes()).LatLng()
+	assert.NoError(t, err)
+	assert.InDelta(t, 12.345678912, lat, 1e-7)
+	assert.InDelta(t, -98.765432198, lng, 1e-7)
+
+	for _, c := range []LatLng{{90.1, 0}, {0, -180.1}, {math.NaN(), 0}, {0, math.Inf(1)}} {
+		p.Reset()
+		Encoder(p).LatLng(c.Lat, c.Lng)
+		_, _, err = Decoder(p.Bytes()).LatLng()
+		assert.ErrorIs(t, err, ErrInvalidLatLng)
+	}
+
+	p.Reset()
+	Encoder(p).LatLng(1, 2)
+	_, _, err = decodeLatLng(p.Bytes()[:latLngSize-1])
+	assert.ErrorIs(t, err, ErrInvalidLatLng)
+}
@@ -0,0 +1,80 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"net/textproto"
+)
+
+// Header encodes an HTTP or MIME header, like an http.Header or a textproto.MIMEHeader, as a Map
+// of StringKind to SliceKind, with every value of a key held in a Slice of StringKind.
+func (e *BufferEncoder) Header(value map[string][]string) *BufferEncoder {
+	b := (*Buffer)(e)
+	encodeMap(b, uint32(len(value)), StringKind, SliceKind)
+	for key, values := range value {
+		encodeString(b, key)
+		encodeSlice(b, uint32(len(values)), StringKind)
+		for _, v := range values {
+			encodeString(b, v)
+		}
+	}
+	return e
+}
+
+// Header decodes a header encoded with Encoder.Header, canonicalizing its keys with
+// textproto.CanonicalMIMEHeaderKey. The values of keys that only differ by case are merged,
+// unless RejectDuplicateKeys is set. The result can be converted directly to an http.Header.
+func (d *BufferDecoder) Header() (textproto.MIMEHeader, error) {
+	size, err := d.Map(StringKind, SliceKind)
+	if err != nil {
+		return nil, err
+	}
+	if err = d.checkElements(uint64(size), 2, ErrInvalidMap); err != nil {
+		return nil, err
+	}
+	value := make(textproto.MIMEHeader, size)
+	for i := uint32(0); i < size; i++ {
+		key, err := d.String()
+		if err != nil {
+			return nil, err
+		}
+		key = textproto.CanonicalMIMEHeaderKey(key)
+		values, ok := value[key]
+		if ok && d.options.RejectDuplicateKeys {
+			return nil, ErrDuplicateKey
+		}
+		n, err := d.Slice(StringKind)
+		if err != nil {
+			return nil, err
+		}
+		if err = d.checkElements(uint64(n), 1, ErrInvalidSlice); err != nil {
+			return nil, err
+		}
+		if values == nil {
+			values = make([]string, 0, n)
+		}
+		for j := uint32(0); j < n; j++ {
+			v, err := d.String()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+		value[key] = values
+	}
+	return value, nil
+}
@@ -0,0 +1,70 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"net/http"
+	"net/textproto"
+	"testing"
+)
+
+func TestHeader(t *testing.T) {
+	t.Parallel()
+
+	h := http.Header{}
+	h.Add("Content-Type", "text/plain")
+	h.Add("Set-Cookie", "a=1")
+	h.Add("Set-Cookie", "b=2")
+	h["Empty"] = []string{}
+
+	p := NewBuffer()
+	Encoder(p).Header(h).Header(nil).Header(textproto.MIMEHeader{"x-lower": {"1"}, "X-Lower": {"2"}})
+
+	d := Decoder(p.Bytes())
+	value, err := d.Header()
+	assert.NoError(t, err)
+	assert.Equal(t, h, http.Header(value))
+	assert.Equal(t, []string{"a=1", "b=2"}, http.Header(value).Values("Set-Cookie"))
+
+	value, err = d.Header()
+	assert.NoError(t, err)
+	assert.NotNil(t, value)
+	assert.Empty(t, value)
+
+	c := d.Clone()
+	value, err = d.Header()
+	assert.NoError(t, err)
+	assert.Len(t, value, 1)
+	assert.ElementsMatch(t, []string{"1", "2"}, value.Values("X-Lower"))
+	assert.NoError(t, d.Finish())
+
+	c.options.RejectDuplicateKeys = true
+	_, err = c.Header()
+	assert.ErrorIs(t, err, ErrDuplicateKey)
+
+	p.Reset()
+	Encoder(p).Header(http.Header{"Accept": {"*/*"}})
+	_, err = Decoder(p.Bytes()[:p.Len()-1]).Header()
+	assert.ErrorIs(t, err, ErrInvalidString)
+
+	p.Reset()
+	Encoder(p).Map(1, StringKind, StringKind).String("Key").String("Value")
+	_, err = Decoder(p.Bytes()).Header()
+	assert.ErrorIs(t, err, ErrInvalidMap)
+}
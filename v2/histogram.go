@@ -0,0 +1,103 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"math"
+	"math/bits"
+)
+
+// HistogramBucket is the upper bound of a histogram bucket and the number of observations
+// counted in it, as encoded by BufferEncoder.Histogram.
+type HistogramBucket struct {
+	Bound float64
+	Count uint64
+}
+
+// encodeHistogram writes each bound as the XOR of its bits with the previous bound's, with the
+// bytes reversed so that the trailing zeros of round numbers like 0.5 or 100 become leading zeros
+// that the varint drops, and each count as a zig-zag varint of its difference from the previous.
+func encodeHistogram(b *Buffer, buckets []HistogramBucket) {
+	b.Grow(1 + VarIntLen64)
+	b.b[b.offset] = HistogramRawKind
+	b.offset++
+	writeUvarint(b, uint64(len(buckets)))
+	var prev uint64
+	for _, bucket := range buckets {
+		bound := math.Float64bits(bucket.Bound)
+		writeUvarint(b, bits.ReverseBytes64(bound^prev))
+		prev = bound
+	}
+	prev = 0
+	for _, bucket := range buckets {
+		writeVarint(b, int64(bucket.Count-prev))
+		prev = bucket.Count
+	}
+}
+
+func decodeHistogram(b []byte, maxElements int) ([]byte, []HistogramBucket, error) {
+	if len(b) < 2 || b[0] != HistogramRawKind {
+		return b, nil, ErrInvalidHistogram
+	}
+	remaining, size, ok := readUvarint(b[1:])
+	if !ok {
+		return b, nil, ErrInvalidHistogram
+	}
+	if maxElements > 0 && size > uint64(maxElements) {
+		return b, nil, ErrTooManyElements
+	}
+	// Every bucket takes at least a byte for its bound and another for its count
+	if size > uint64(len(remaining)/2) {
+		return b, nil, ErrInvalidHistogram
+	}
+	buckets := make([]HistogramBucket, size)
+	var prev uint64
+	for i := range buckets {
+		var bound uint64
+		if remaining, bound, ok = readUvarint(remaining); !ok {
+			return b, nil, ErrInvalidHistogram
+		}
+		prev ^= bits.ReverseBytes64(bound)
+		buckets[i].Bound = math.Float64frombits(prev)
+	}
+	prev = 0
+	for i := range buckets {
+		var delta int64
+		if remaining, delta, ok = readVarint(remaining); !ok {
+			return b, nil, ErrInvalidHistogram
+		}
+		prev += uint64(delta)
+		buckets[i].Count = prev
+	}
+	return remaining, buckets, nil
+}
+
+// Histogram encodes buckets as their bounds followed by their counts. Each bound is stored as
+// its difference in bits from the previous one, which takes a byte or two for the round numbers
+// bounds usually are, but up to ten for bounds like 1.5^n that use the whole mantissa, and each
+// count as the varint of its difference from the previous one, which is small whether the counts
+// are per bucket or cumulative. This is far smaller than a Slice of Float64 and Uint64 pairs.
+func (e *BufferEncoder) Histogram(buckets []HistogramBucket) *BufferEncoder {
+	encodeHistogram((*Buffer)(e), buckets)
+	return e
+}
+
+func (d *BufferDecoder) Histogram() (value []HistogramBucket, err error) {
+	d.b, value, err = decodeHistogram(d.b, d.options.MaxElements)
+	err = d.step(err)
+	return
+}
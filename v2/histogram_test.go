@@ -0,0 +1,104 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"io"
+	"math"
+	"testing"
+)
+
+func TestHistogram(t *testing.T) {
+	t.Parallel()
+
+	// The default request latency buckets of a Prometheus client, with cumulative counts
+	buckets := []HistogramBucket{
+		{0.005, 12}, {0.01, 57}, {0.025, 210}, {0.05, 1342}, {0.1, 4051}, {0.25, 9012},
+		{0.5, 11860}, {1, 12302}, {2.5, 12390}, {5, 12399}, {10, 12400}, {math.Inf(1), 12400},
+	}
+
+	p := NewBuffer()
+	Encoder(p).Histogram(buckets).Bool(true)
+
+	pairs := NewBuffer()
+	Encoder(pairs).Slice(uint32(len(buckets)), AnyKind)
+	for _, bucket := range buckets {
+		Encoder(pairs).Float64(bucket.Bound).Uint64(bucket.Count)
+	}
+	Encoder(pairs).Bool(true)
+	assert.Less(t, p.Len(), pairs.Len()*2/3)
+
+	d := Decoder(p.Bytes())
+	value, err := d.Histogram()
+	assert.NoError(t, err)
+	assert.Equal(t, buckets, value)
+	b, err := d.Bool()
+	assert.NoError(t, err)
+	assert.True(t, b)
+
+	// Per bucket counts, and bounds that use the whole mantissa, round trip exactly too
+	exponential := make([]HistogramBucket, 30)
+	for i := range exponential {
+		exponential[i] = HistogramBucket{Bound: 0.001 * math.Pow(1.5, float64(i)), Count: uint64(30 - i)}
+	}
+	p.Reset()
+	Encoder(p).Histogram(exponential)
+	value, err = Decoder(p.Bytes()).Histogram()
+	assert.NoError(t, err)
+	assert.Equal(t, exponential, value)
+
+	p.Reset()
+	Encoder(p).Histogram(nil)
+	value, err = Decoder(p.Bytes()).Histogram()
+	assert.NoError(t, err)
+	assert.Empty(t, value)
+
+	p.Reset()
+	Encoder(p).Histogram(buckets)
+	anyValue, err := Decoder(p.Bytes()).Any()
+	assert.NoError(t, err)
+	assert.Equal(t, buckets, anyValue)
+	q := NewBuffer()
+	assert.NoError(t, EncodeAny(Encoder(q), anyValue))
+	assert.Equal(t, p.Bytes(), q.Bytes())
+
+	n, complete, err := MessageComplete(p.Bytes())
+	assert.NoError(t, err)
+	assert.True(t, complete)
+	assert.Equal(t, p.Len(), n)
+	for i := 1; i < p.Len(); i++ {
+		_, complete, err = MessageComplete(p.Bytes()[:i])
+		assert.NoError(t, err)
+		assert.False(t, complete)
+		_, err = Decoder(p.Bytes()[:i]).Histogram()
+		assert.ErrorIs(t, err, ErrInvalidHistogram)
+	}
+
+	_, err = DecoderWithOptions(p.Bytes(), DecoderOptions{MaxElements: len(buckets) - 1}).Histogram()
+	assert.ErrorIs(t, err, ErrTooManyElements)
+
+	_, err = Decoder([]byte{HistogramRawKind, 0xFF, 0xFF, 0xFF, 0x0F}).Histogram()
+	assert.ErrorIs(t, err, ErrInvalidHistogram)
+
+	// A bucket count that wraps around to zero when doubled can't be skipped
+	wrap := []byte{HistogramRawKind, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01}
+	_, err = Decoder(wrap).Histogram()
+	assert.ErrorIs(t, err, ErrInvalidHistogram)
+	assert.ErrorIs(t, Decoder(wrap).Skip(), io.ErrUnexpectedEOF)
+}
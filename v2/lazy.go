@@ -0,0 +1,159 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"fmt"
+)
+
+// LazyMessage holds the offsets of every top-level value in a buffer,
+// so that individual values can be decoded on demand.
+type LazyMessage struct {
+	b       []byte
+	offsets []int
+}
+
+// LazyDecode scans b with Skip to find where each top-level value starts, without decoding any
+// of them. The LazyMessage aliases b, which must not be modified while the message is in use.
+func LazyDecode(b []byte) (*LazyMessage, error) {
+	m := &LazyMessage{
+		b: b,
+	}
+	for remaining := b; len(remaining) > 0; {
+		m.offsets = append(m.offsets, len(b)-len(remaining))
+		var err error
+		if remaining, err = skipValue(remaining, 0); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// PartialDecodeError is returned by DecodePartial for the first top-level value that couldn't be
+// decoded, and matches the error it failed with using errors.Is.
+type PartialDecodeError struct {
+	// Field is the index of the value, which is also the number of values decoded before it.
+	Field int
+	// Offset is where the value starts in the buffer passed to DecodePartial.
+	Offset int
+
+	err error
+}
+
+func (e *PartialDecodeError) Error() string {
+	return fmt.Sprintf("field %d at offset %d: %s", e.Field, e.Offset, e.err)
+}
+
+func (e *PartialDecodeError) Unwrap() error {
+	return e.err
+}
+
+// DecodePartial decodes the top-level values of b with Any for as long as it can, which is
+// meant for recovering what's left of a damaged message. Along with the values before the first
+// one that fails to decode, it returns a *PartialDecodeError for that value, recording where it
+// starts so that the rest of the message can be inspected. The options apply as they would to
+// a Decoder, so for instance MaxElements bounds what a corrupted size can allocate.
+func DecodePartial(b []byte, options DecoderOptions) ([]any, error) {
+	d := DecoderWithOptions(b, options)
+	var decoded []any
+	for d.Remaining() > 0 {
+		checkpoint := len(b) - d.Remaining()
+		value, err := d.Any()
+		if err != nil {
+			return decoded, &PartialDecodeError{Field: len(decoded), Offset: checkpoint, err: err}
+		}
+		decoded = append(decoded, value)
+	}
+	return decoded, nil
+}
+
+// Len returns the number of top-level values in the message.
+func (m *LazyMessage) Len() int {
+	return len(m.offsets)
+}
+
+// Raw returns the encoded bytes of value i, including its kind.
+func (m *LazyMessage) Raw(i int) ([]byte, error) {
+	if i < 0 || i >= len(m.offsets) {
+		return nil, ErrFieldOutOfRange
+	}
+	end := len(m.b)
+	if i+1 < len(m.offsets) {
+		end = m.offsets[i+1]
+	}
+	return m.b[m.offsets[i]:end], nil
+}
+
+// Kind returns the kind of value i.
+func (m *LazyMessage) Kind(i int) (Kind, error) {
+	raw, err := m.Raw(i)
+	if err != nil {
+		return NilKind, err
+	}
+	return Kind(raw[0]), nil
+}
+
+// Decoder returns a Decoder positioned at value i, which can be used
+// to decode kinds without an accessor, such as a Slice and its elements.
+func (m *LazyMessage) Decoder(i int) (*BufferDecoder, error) {
+	raw, err := m.Raw(i)
+	if err != nil {
+		return nil, err
+	}
+	return Decoder(raw), nil
+}
+
+func lazyValue[T any](m *LazyMessage, i int, decode func([]byte) ([]byte, T, error)) (value T, err error) {
+	raw, err := m.Raw(i)
+	if err != nil {
+		return value, err
+	}
+	_, value, err = decode(raw)
+	return value, err
+}
+
+func (m *LazyMessage) Bool(i int) (bool, error) {
+	return lazyValue(m, i, decodeBool)
+}
+
+func (m *LazyMessage) Uint32(i int) (uint32, error) {
+	return lazyValue(m, i, decodeUint32)
+}
+
+func (m *LazyMessage) Uint64(i int) (uint64, error) {
+	return lazyValue(m, i, decodeUint64)
+}
+
+func (m *LazyMessage) Int32(i int) (int32, error) {
+	return lazyValue(m, i, decodeInt32)
+}
+
+func (m *LazyMessage) Int64(i int) (int64, error) {
+	return lazyValue(m, i, decodeInt64)
+}
+
+func (m *LazyMessage) Float64(i int) (float64, error) {
+	return lazyValue(m, i, decodeFloat64)
+}
+
+func (m *LazyMessage) String(i int) (string, error) {
+	return lazyValue(m, i, decodeString)
+}
+
+func (m *LazyMessage) Bytes(i int) ([]byte, error) {
+	return lazyValue(m, i, decodeBytesExact)
+}
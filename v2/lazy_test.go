@@ -0,0 +1,138 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"fmt"
+	"io"
+	"testing"
+)
+
+func TestLazyDecode(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).String("Test String").Slice(2, Uint32Kind).Uint32(1).Uint32(2).Uint32(32).Bool(true).
+		Int64(-64).Float64(64.64).Bytes([]byte("Test Bytes")).Uint64(64).Int32(-32)
+
+	m, err := LazyDecode(p.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, 9, m.Len())
+
+	u32, err := m.Uint32(2)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(32), u32)
+
+	s, err := m.String(0)
+	assert.NoError(t, err)
+	assert.Equal(t, "Test String", s)
+
+	b, err := m.Bool(3)
+	assert.NoError(t, err)
+	assert.True(t, b)
+
+	i64, err := m.Int64(4)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-64), i64)
+
+	f64, err := m.Float64(5)
+	assert.NoError(t, err)
+	assert.Equal(t, 64.64, f64)
+
+	bs, err := m.Bytes(6)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("Test Bytes"), bs)
+
+	u64, err := m.Uint64(7)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(64), u64)
+
+	i32, err := m.Int32(8)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(-32), i32)
+
+	kind, err := m.Kind(1)
+	assert.NoError(t, err)
+	assert.Equal(t, SliceKind, kind)
+
+	d, err := m.Decoder(1)
+	assert.NoError(t, err)
+	size, err := d.Slice(Uint32Kind)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2), size)
+
+	_, err = m.Uint32(0)
+	assert.ErrorIs(t, err, ErrInvalidUint32)
+
+	_, err = m.Uint32(9)
+	assert.ErrorIs(t, err, ErrFieldOutOfRange)
+
+	_, err = m.Raw(-1)
+	assert.ErrorIs(t, err, ErrFieldOutOfRange)
+
+	_, err = LazyDecode(p.Bytes()[:len(p.Bytes())-1])
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	m, err = LazyDecode(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, m.Len())
+}
+
+func TestDecodePartial(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).String("name").Slice(2, Uint32Kind).Uint32(1).Uint32(2).Bool(true)
+	corruptAt := p.Len()
+	Encoder(p).Uint32(7).Int64(-1)
+
+	decoded, err := DecodePartial(p.Bytes(), DecoderOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"name", []any{uint32(1), uint32(2)}, true, uint32(7), int64(-1)}, decoded)
+
+	// Corrupting the kind of the fourth value keeps the three before it
+	damaged := append([]byte{}, p.Bytes()...)
+	damaged[corruptAt] = 0xFF
+	decoded, err = DecodePartial(damaged, DecoderOptions{})
+	assert.ErrorIs(t, err, ErrUnsupportedKind)
+	assert.Equal(t, []any{"name", []any{uint32(1), uint32(2)}, true}, decoded)
+	var partial *PartialDecodeError
+	assert.ErrorAs(t, err, &partial)
+	assert.Equal(t, 3, partial.Field)
+	assert.Equal(t, corruptAt, partial.Offset)
+	assert.EqualError(t, err, fmt.Sprintf("field 3 at offset %d: %s", corruptAt, ErrUnsupportedKind))
+
+	// A truncated message keeps everything before the truncated value
+	decoded, err = DecodePartial(p.Bytes()[:p.Len()-1], DecoderOptions{})
+	assert.ErrorIs(t, err, ErrInvalidInt64)
+	assert.Len(t, decoded, 4)
+	assert.ErrorAs(t, err, &partial)
+	assert.Equal(t, 4, partial.Field)
+
+	// A corrupted size inside a value fails that value as a whole
+	damaged = append([]byte{}, p.Bytes()...)
+	damaged[10] = 100
+	decoded, err = DecodePartial(damaged, DecoderOptions{MaxElements: 10})
+	assert.ErrorIs(t, err, ErrTooManyElements)
+	assert.Equal(t, []any{"name"}, decoded)
+
+	decoded, err = DecodePartial(nil, DecoderOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, decoded)
+}
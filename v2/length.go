@@ -0,0 +1,90 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// staticUint32Size is the kind followed by the value as a little-endian uint32.
+const staticUint32Size = 5
+
+// encodeStaticUint32 writes value at a fixed width, unlike Uint32, so that it can be
+// overwritten in place once the value is known.
+func encodeStaticUint32(b *Buffer, value uint32) {
+	b.Grow(staticUint32Size)
+	b.b[b.offset] = StaticUint32RawKind
+	binary.LittleEndian.PutUint32(b.b[b.offset+1:], value)
+	b.offset += staticUint32Size
+}
+
+func decodeStaticUint32(b []byte) ([]byte, uint32, error) {
+	if len(b) >= staticUint32Size && b[0] == StaticUint32RawKind {
+		return b[staticUint32Size:], binary.LittleEndian.Uint32(b[1:]), nil
+	}
+	return b, 0, ErrInvalidUint32
+}
+
+func (e *BufferEncoder) StaticUint32(value uint32) *BufferEncoder {
+	encodeStaticUint32((*Buffer)(e), value)
+	return e
+}
+
+func (d *BufferDecoder) StaticUint32() (value uint32, err error) {
+	d.b, value, err = decodeStaticUint32(d.b)
+	err = d.step(err)
+	return
+}
+
+// LengthPlaceholder writes a StaticUint32 of zero and returns its offset, to be passed
+// to PatchLength once the message it's the length of has been encoded after it.
+func (e *BufferEncoder) LengthPlaceholder() int {
+	offset := e.offset
+	encodeStaticUint32((*Buffer)(e), 0)
+	return offset
+}
+
+// PatchLength overwrites the placeholder written at offset by LengthPlaceholder with
+// the number of bytes encoded since, failing with ErrMessageTooLarge beyond 4GiB.
+func (e *BufferEncoder) PatchLength(offset int) error {
+	length := e.offset - offset - staticUint32Size
+	if uint64(length) > math.MaxUint32 {
+		return ErrMessageTooLarge
+	}
+	binary.LittleEndian.PutUint32(e.b[offset+1:], uint32(length))
+	return nil
+}
+
+// MessageLength reads the length prefix of a message, either a StaticUint32 written by
+// LengthPlaceholder or a Uint32, leaving the Decoder at the start of the message body.
+// The body isn't read, so the caller can check that it has fully arrived with Remaining
+// before decoding it, and MessageLength fails with ErrInvalidUint32 for any other kind.
+func (d *BufferDecoder) MessageLength() (uint32, error) {
+	if len(d.b) > 0 && d.b[0] == StaticUint32RawKind {
+		return d.StaticUint32()
+	}
+	return d.Uint32()
+}
+
+// LengthPrefixed encodes the values written by fn prefixed with their total length in bytes,
+// without having to know it up front, by writing a placeholder and patching it afterwards.
+func (e *BufferEncoder) LengthPrefixed(fn func(*BufferEncoder)) error {
+	offset := e.LengthPlaceholder()
+	fn(e)
+	return e.PatchLength(offset)
+}
@@ -0,0 +1,108 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"testing"
+)
+
+func TestMessageLength(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	e := Encoder(p).String("before")
+	offset := e.LengthPlaceholder()
+	e.String("body").Uint64(1 << 40)
+	assert.NoError(t, e.PatchLength(offset))
+
+	d := Decoder(p.Bytes())
+	_, err := d.String()
+	assert.NoError(t, err)
+	length, err := d.MessageLength()
+	assert.NoError(t, err)
+	assert.Equal(t, d.Remaining(), int(length))
+	body, err := d.String()
+	assert.NoError(t, err)
+	assert.Equal(t, "body", body)
+
+	// A message that hasn't fully arrived
+	d = Decoder(p.Bytes()[:p.Len()-1])
+	assert.NoError(t, d.Skip())
+	length, err = d.MessageLength()
+	assert.NoError(t, err)
+	assert.Less(t, d.Remaining(), int(length))
+
+	p.Reset()
+	Encoder(p).Uint32(3).Bool(true)
+	d = Decoder(p.Bytes())
+	length, err = d.MessageLength()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(3), length)
+
+	p.Reset()
+	Encoder(p).StaticUint32(1 << 30).String("next")
+	d = Decoder(p.Bytes())
+	value, err := d.Any()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1<<30), value)
+	d = Decoder(p.Bytes())
+	assert.NoError(t, d.Skip())
+	_, err = d.String()
+	assert.NoError(t, err)
+
+	_, err = Decoder(p.Bytes()[:staticUint32Size-1]).MessageLength()
+	assert.ErrorIs(t, err, ErrInvalidUint32)
+	_, err = Decoder([]byte{StringRawKind}).MessageLength()
+	assert.ErrorIs(t, err, ErrInvalidUint32)
+}
+
+func TestLengthPrefixed(t *testing.T) {
+	t.Parallel()
+
+	p := NewBufferSize(8)
+	e := Encoder(p)
+	assert.NoError(t, e.LengthPrefixed(func(e *BufferEncoder) {
+		e.String("outer")
+		assert.NoError(t, e.LengthPrefixed(func(e *BufferEncoder) {
+			e.Bytes(make([]byte, 1000))
+		}))
+	}))
+	e.Bool(true)
+
+	d := Decoder(p.Bytes())
+	length, err := d.MessageLength()
+	assert.NoError(t, err)
+	assert.Equal(t, p.Len()-staticUint32Size-2, int(length))
+
+	start := d.Remaining()
+	_, err = d.String()
+	assert.NoError(t, err)
+	inner, err := d.MessageLength()
+	assert.NoError(t, err)
+	innerStart := d.Remaining()
+	value, err := d.Bytes(nil)
+	assert.NoError(t, err)
+	assert.Len(t, value, 1000)
+	assert.Equal(t, int(inner), innerStart-d.Remaining())
+	assert.Equal(t, int(length), start-d.Remaining())
+
+	_, err = d.Bool()
+	assert.NoError(t, err)
+	assert.Equal(t, 0, d.Remaining())
+}
@@ -0,0 +1,898 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+const (
+	fieldHashOffset = 2166136261
+	fieldHashPrime  = 16777619
+)
+
+// MarshalOptions configures how Marshal and Unmarshal map Go values onto the wire.
+//
+// Structs are encoded positionally by default, as a Slice of AnyKind holding every exported
+// field in declaration order. A field's name can be overridden with a `polyglot:"name"` tag,
+// and a field can be excluded with `polyglot:"-"`. A field tagged with `polyglot:",omitempty"`,
+// optionally after its name, is encoded as Nil when it holds the zero value for its type, or
+// left out entirely with HashedFields or NamedFields. A Nil decoded into any field sets it to
+// its zero value.
+//
+// Interface fields are encoded using their dynamic value, and decoded with Decoder.Any, so
+// they hold the type Any picks for the kind on the wire, such as int64 for an int. Dynamic
+// values that Any can't reconstruct, like structs and pointers, fail with ErrUnsupportedType.
+//
+// Values nested more than 512 deep, counting pointers, struct fields and elements, fail with
+// ErrTooDeep, as do cyclic pointers when encoding, so that neither deeply nested input nor a
+// cycle can overflow the stack.
+type MarshalOptions struct {
+	// HashedFields encodes structs as a Map of Uint32Kind to AnyKind instead, keyed by the
+	// FieldHash of each field's name. Fields can then be reordered or added without
+	// breaking existing readers, and unknown fields are skipped when decoding.
+	HashedFields bool
+
+	// NamedFields encodes structs as a Map of StringKind to AnyKind instead, keyed by the name
+	// of each field, so consumers that access fields by name, like dynamically typed languages
+	// decoding the message with Any, can find them. Like HashedFields, unknown fields are skipped
+	// and missing fields left untouched when decoding, at the cost of a larger encoding. It takes
+	// precedence over HashedFields.
+	NamedFields bool
+
+	// FieldMask, if set, makes Marshal encode only the named fields of the top-level struct,
+	// for partial updates. Positionally encoded structs are then preceded by a bitset of the
+	// fields present. Unmarshal recognizes masked structs without needing the option, and
+	// only sets the fields present, leaving the rest of the target struct untouched.
+	FieldMask []string
+
+	// IncludeUnexported encodes and decodes unexported struct fields too, for persisting or
+	// checkpointing internal state. They're accessed with package unsafe, sidestepping the
+	// encapsulation of their types, including types from other packages whose unexported fields
+	// can change in any release, so this is only meant for trusted data of types under the
+	// caller's control. Both sides must agree on the option, as it changes the fields on the wire.
+	IncludeUnexported bool
+
+	// PackStructs encodes slices and arrays of structs whose fields are all booleans, integers
+	// or floats as a PackedSlice, which lists the kinds of the fields once and then holds the
+	// fields of every element back to back at a fixed width, without a kind byte for each.
+	// Unmarshal recognizes packed slices without needing the option. It has no effect on
+	// structs encoded with HashedFields or NamedFields.
+	PackStructs bool
+
+	// BinaryFallback encodes values whose type implements encoding.BinaryMarshaler as Bytes
+	// holding the result of MarshalBinary, and decodes them with UnmarshalBinary, rather than
+	// encoding their fields. This covers types like time.Time and netip.Addr whose state is
+	// unexported. Both sides must agree on the option, as it changes the shape on the wire.
+	BinaryFallback bool
+
+	// AppendFingerprint makes Marshal append the SchemaFingerprint of the value's type to the
+	// message as a StaticUint32, and makes Unmarshal fail with ErrSchemaMismatch unless the
+	// message ends with the fingerprint of the target's type. Other decoders can check it
+	// with DecodeVerifySchema.
+	AppendFingerprint bool
+
+	// PrefixVarints encodes 64-bit integers, including int and uint, as PrefixVarints with
+	// PrefixUint64 and PrefixInt64 rather than the LEB128 varints used otherwise. They're only
+	// faster to decode for values of more than 56 bits, and slower for small ones. Unmarshal
+	// accepts either without needing the option, but the Rust and TypeScript decoders only read
+	// LEB128, so it must only be set for messages that are decoded in Go.
+	PrefixVarints bool
+}
+
+type structField struct {
+	name      string
+	hash      uint32
+	index     int
+	exported  bool
+	omitEmpty bool
+}
+
+type structInfoKey struct {
+	t          reflect.Type
+	unexported bool
+}
+
+type structInfo struct {
+	fields []structField
+	hashes map[uint32]int
+}
+
+var structInfoCache sync.Map
+
+var (
+	binaryMarshalerType   = reflect.TypeOf((*encoding.BinaryMarshaler)(nil)).Elem()
+	binaryUnmarshalerType = reflect.TypeOf((*encoding.BinaryUnmarshaler)(nil)).Elem()
+)
+
+// FieldHash returns the 32-bit FNV-1a hash of the UTF-8 bytes of name,
+// which identifies a struct field when MarshalOptions.HashedFields is set.
+func FieldHash(name string) uint32 {
+	hash := uint32(fieldHashOffset)
+	for i := 0; i < len(name); i++ {
+		hash ^= uint32(name[i])
+		hash *= fieldHashPrime
+	}
+	return hash
+}
+
+func Marshal(v any) ([]byte, error) {
+	return MarshalOptions{}.Marshal(v)
+}
+
+func Unmarshal(b []byte, v any) error {
+	return MarshalOptions{}.Unmarshal(b, v)
+}
+
+// MarshalAsMap encodes v with NamedFields, so that every struct becomes a Map keyed by field name
+// that consumers can access by name, such as dynamically typed languages decoding it with Any.
+func MarshalAsMap(v any) ([]byte, error) {
+	return MarshalOptions{NamedFields: true}.Marshal(v)
+}
+
+// UnmarshalFromMap decodes b, encoded by MarshalAsMap, into v, matching fields by name. Keys that
+// don't name a field of v are skipped, and fields without a key are left untouched.
+func UnmarshalFromMap(b []byte, v any) error {
+	return MarshalOptions{NamedFields: true}.Unmarshal(b, v)
+}
+
+func (o MarshalOptions) Marshal(v any) ([]byte, error) {
+	b := NewBuffer()
+	var err error
+	if o.FieldMask != nil {
+		err = o.encodeMasked(b, reflect.ValueOf(v))
+	} else {
+		err = o.encode(b, reflect.ValueOf(v), 0)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if o.AppendFingerprint {
+		fingerprint, err := o.SchemaFingerprint(v)
+		if err != nil {
+			return nil, err
+		}
+		encodeStaticUint32(b, fingerprint)
+	}
+	return b.Bytes(), nil
+}
+
+func (o MarshalOptions) Unmarshal(b []byte, v any) error {
+	if o.AppendFingerprint {
+		value := reflect.ValueOf(v)
+		if value.Kind() != reflect.Pointer || value.IsNil() {
+			return ErrInvalidTarget
+		}
+		fingerprint, err := o.SchemaFingerprint(v)
+		if err != nil {
+			return err
+		}
+		if b, err = DecodeVerifySchema(b, fingerprint); err != nil {
+			return err
+		}
+	}
+	return o.Decode(Decoder(b), v)
+}
+
+// Decode is like Unmarshal, but decodes the next value from d, honouring its DecoderOptions.
+func (o MarshalOptions) Decode(d *BufferDecoder, v any) error {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Pointer || value.IsNil() {
+		return ErrInvalidTarget
+	}
+	return o.decode(d, value.Elem(), 0)
+}
+
+func getStructInfo(t reflect.Type, unexported bool) (*structInfo, error) {
+	key := structInfoKey{t: t, unexported: unexported}
+	if info, ok := structInfoCache.Load(key); ok {
+		return info.(*structInfo), nil
+	}
+	info := &structInfo{
+		hashes: make(map[uint32]int),
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() && !unexported {
+			continue
+		}
+		name := field.Name
+		var omitEmpty bool
+		if tag, ok := field.Tag.Lookup("polyglot"); ok {
+			if tag == "-" {
+				continue
+			}
+			tag, options, _ := strings.Cut(tag, ",")
+			if tag != "" {
+				name = tag
+			}
+			omitEmpty = options == "omitempty"
+		}
+		hash := FieldHash(name)
+		if _, ok := info.hashes[hash]; ok {
+			return nil, fmt.Errorf("%w: %s.%s", ErrDuplicateField, t, name)
+		}
+		info.hashes[hash] = len(info.fields)
+		info.fields = append(info.fields, structField{
+			name:      name,
+			hash:      hash,
+			index:     i,
+			exported:  field.IsExported(),
+			omitEmpty: omitEmpty,
+		})
+	}
+	structInfoCache.Store(key, info)
+	return info, nil
+}
+
+// usesBinary reports whether values of type t, which isn't a pointer, are
+// encoded with MarshalBinary because of the BinaryFallback option.
+func (o MarshalOptions) usesBinary(t reflect.Type) bool {
+	return o.BinaryFallback && t.Kind() != reflect.Pointer && t.Kind() != reflect.Interface &&
+		(t.Implements(binaryMarshalerType) || reflect.PointerTo(t).Implements(binaryMarshalerType))
+}
+
+func (o MarshalOptions) kindOf(t reflect.Type) (Kind, error) {
+	if o.usesBinary(t) {
+		return BytesKind, nil
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return o.kindOf(t.Elem())
+	case reflect.Bool:
+		return BoolKind, nil
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return Int32Kind, nil
+	case reflect.Int, reflect.Int64:
+		return Int64Kind, nil
+	case reflect.Uint8:
+		return Uint8Kind, nil
+	case reflect.Uint16:
+		return Uint16Kind, nil
+	case reflect.Uint32:
+		return Uint32Kind, nil
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return Uint64Kind, nil
+	case reflect.Float32:
+		return Float32Kind, nil
+	case reflect.Float64:
+		return Float64Kind, nil
+	case reflect.String:
+		return StringKind, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return BytesKind, nil
+		}
+		return SliceKind, nil
+	case reflect.Map:
+		return MapKind, nil
+	case reflect.Struct:
+		if o.keyed() {
+			return MapKind, nil
+		}
+		return SliceKind, nil
+	case reflect.Interface:
+		return AnyKind, nil
+	}
+	return NilKind, fmt.Errorf("%w: %s", ErrUnsupportedType, t)
+}
+
+func (o MarshalOptions) encode(b *Buffer, v reflect.Value, depth int) error {
+	if depth >= maxSkipDepth {
+		return ErrTooDeep
+	}
+	if !v.IsValid() {
+		encodeNil(b)
+		return nil
+	}
+	if o.usesBinary(v.Type()) {
+		return o.encodeBinary(b, v)
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			encodeNil(b)
+			return nil
+		}
+		return o.encode(b, v.Elem(), depth+1)
+	case reflect.Bool:
+		encodeBool(b, v.Bool())
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		encodeInt32(b, int32(v.Int()))
+	case reflect.Int, reflect.Int64:
+		if o.PrefixVarints {
+			encodePrefixInt64(b, v.Int())
+		} else {
+			encodeInt64(b, v.Int())
+		}
+	case reflect.Uint8:
+		encodeUint8(b, uint8(v.Uint()))
+	case reflect.Uint16:
+		encodeUint16(b, uint16(v.Uint()))
+	case reflect.Uint32:
+		encodeUint32(b, uint32(v.Uint()))
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		if o.PrefixVarints {
+			encodePrefixUint64(b, v.Uint())
+		} else {
+			encodeUint64(b, v.Uint())
+		}
+	case reflect.Float32:
+		encodeFloat32(b, float32(v.Float()))
+	case reflect.Float64:
+		encodeFloat64(b, v.Float())
+	case reflect.String:
+		encodeString(b, v.String())
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			if v.Kind() == reflect.Slice {
+				encodeBytes(b, v.Bytes())
+			} else {
+				value := make([]byte, v.Len())
+				reflect.Copy(reflect.ValueOf(value), v)
+				encodeBytes(b, value)
+			}
+			return nil
+		}
+		if o.PackStructs {
+			kinds, err := o.packedStruct(v.Type().Elem())
+			if err != nil {
+				return err
+			}
+			if kinds != nil {
+				o.encodePacked(b, v, kinds)
+				return nil
+			}
+		}
+		kind, err := o.kindOf(v.Type().Elem())
+		if err != nil {
+			return err
+		}
+		encodeSlice(b, uint32(v.Len()), kind)
+		for i := 0; i < v.Len(); i++ {
+			if err = o.encode(b, v.Index(i), depth+1); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		keyKind, err := o.kindOf(v.Type().Key())
+		if err != nil {
+			return err
+		}
+		valueKind, err := o.kindOf(v.Type().Elem())
+		if err != nil {
+			return err
+		}
+		encodeMap(b, uint32(v.Len()), keyKind, valueKind)
+		iter := v.MapRange()
+		for iter.Next() {
+			if err = o.encode(b, iter.Key(), depth+1); err != nil {
+				return err
+			}
+			if err = o.encode(b, iter.Value(), depth+1); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		return o.encodeStruct(b, v, depth)
+	case reflect.Interface:
+		if v.IsNil() {
+			encodeNil(b)
+			return nil
+		}
+		if err := checkDynamic(v.Elem().Type()); err != nil {
+			return err
+		}
+		return o.encode(b, v.Elem(), depth+1)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+	}
+	return nil
+}
+
+// encodeBinary encodes v as Bytes holding the result of its MarshalBinary method, which
+// may have a pointer receiver, in which case an unaddressable v is copied first.
+func (o MarshalOptions) encodeBinary(b *Buffer, v reflect.Value) error {
+	m, ok := v.Interface().(encoding.BinaryMarshaler)
+	if !ok {
+		if !v.CanAddr() {
+			c := reflect.New(v.Type()).Elem()
+			c.Set(v)
+			v = c
+		}
+		m = v.Addr().Interface().(encoding.BinaryMarshaler)
+	}
+	value, err := m.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	encodeBytes(b, value)
+	return nil
+}
+
+// checkDynamic returns ErrUnsupportedType if a value of type t held in an interface couldn't be
+// reconstructed by Any, which only decodes the kinds on the wire into predeclared types.
+func checkDynamic(t reflect.Type) error {
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.String, reflect.Interface:
+		return nil
+	case reflect.Slice, reflect.Array:
+		return checkDynamic(t.Elem())
+	case reflect.Map:
+		if err := checkDynamic(t.Key()); err != nil {
+			return err
+		}
+		return checkDynamic(t.Elem())
+	}
+	return fmt.Errorf("%w: %s can't be decoded into an interface", ErrUnsupportedType, t)
+}
+
+// structFieldValue returns the value of field in v, which must be addressable if the field is
+// unexported, in which case the value is reached through its address so it can be read and set.
+func structFieldValue(v reflect.Value, field structField) reflect.Value {
+	f := v.Field(field.index)
+	if !field.exported {
+		f = reflect.NewAt(f.Type(), unsafe.Pointer(f.UnsafeAddr())).Elem()
+	}
+	return f
+}
+
+// addressable returns v, or an addressable copy of it when unexported fields have to be read.
+func (o MarshalOptions) addressable(v reflect.Value) reflect.Value {
+	if !o.IncludeUnexported || v.CanAddr() {
+		return v
+	}
+	c := reflect.New(v.Type()).Elem()
+	c.Set(v)
+	return c
+}
+
+func (o MarshalOptions) encodeStruct(b *Buffer, v reflect.Value, depth int) error {
+	info, err := getStructInfo(v.Type(), o.IncludeUnexported)
+	if err != nil {
+		return err
+	}
+	v = o.addressable(v)
+	if o.keyed() {
+		size := len(info.fields)
+		for _, field := range info.fields {
+			if field.omitEmpty && structFieldValue(v, field).IsZero() {
+				size--
+			}
+		}
+		o.encodeFieldMap(b, size)
+	} else {
+		encodeSlice(b, uint32(len(info.fields)), AnyKind)
+	}
+	for _, field := range info.fields {
+		if err = o.encodeField(b, v, field, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keyed reports whether structs are encoded as a Map keyed by their fields' names or hashes.
+func (o MarshalOptions) keyed() bool {
+	return o.NamedFields || o.HashedFields
+}
+
+// encodeFieldMap encodes the Map header of a struct with size fields for NamedFields or HashedFields.
+func (o MarshalOptions) encodeFieldMap(b *Buffer, size int) {
+	if o.NamedFields {
+		encodeMap(b, uint32(size), StringKind, AnyKind)
+	} else {
+		encodeMap(b, uint32(size), Uint32Kind, AnyKind)
+	}
+}
+
+// encodeField encodes field of the struct v, preceded by its name with NamedFields or its
+// hash with HashedFields, or as Nil, or with either of those not at all, if it's tagged
+// omitempty and empty.
+func (o MarshalOptions) encodeField(b *Buffer, v reflect.Value, field structField, depth int) error {
+	value := structFieldValue(v, field)
+	if field.omitEmpty && value.IsZero() {
+		if !o.keyed() {
+			encodeNil(b)
+		}
+		return nil
+	}
+	if o.NamedFields {
+		encodeString(b, field.name)
+	} else if o.HashedFields {
+		encodeUint32(b, field.hash)
+	}
+	return o.encode(b, value, depth+1)
+}
+
+func (o MarshalOptions) encodeMasked(b *Buffer, v reflect.Value) error {
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("%w: FieldMask requires a struct, not %s", ErrUnsupportedType, v.Type())
+	}
+	info, err := getStructInfo(v.Type(), o.IncludeUnexported)
+	if err != nil {
+		return err
+	}
+	v = o.addressable(v)
+	present := make([]byte, (len(info.fields)+7)/8)
+	count := 0
+	for _, name := range o.FieldMask {
+		i, ok := info.hashes[FieldHash(name)]
+		if !ok || info.fields[i].name != name {
+			return fmt.Errorf("%w: %s.%s", ErrUnknownField, v.Type(), name)
+		}
+		if present[i/8]&(1<<(i%8)) == 0 {
+			present[i/8] |= 1 << (i % 8)
+			if !o.keyed() || !info.fields[i].omitEmpty || !structFieldValue(v, info.fields[i]).IsZero() {
+				count++
+			}
+		}
+	}
+	if o.keyed() {
+		o.encodeFieldMap(b, count)
+	} else {
+		encodeBytes(b, present)
+		encodeSlice(b, uint32(count), AnyKind)
+	}
+	for i, field := range info.fields {
+		if present[i/8]&(1<<(i%8)) == 0 {
+			continue
+		}
+		if err = o.encodeField(b, v, field, 0); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o MarshalOptions) decode(d *BufferDecoder, v reflect.Value, depth int) error {
+	if depth >= maxSkipDepth {
+		return ErrTooDeep
+	}
+	var err error
+	if o.BinaryFallback && v.Kind() != reflect.Pointer && v.Kind() != reflect.Interface &&
+		reflect.PointerTo(v.Type()).Implements(binaryUnmarshalerType) {
+		var value []byte
+		if value, err = d.Bytes(nil); err == nil {
+			err = v.Addr().Interface().(encoding.BinaryUnmarshaler).UnmarshalBinary(value)
+		}
+		return err
+	}
+	switch v.Kind() {
+	case reflect.Pointer:
+		if d.Nil() {
+			v.SetZero()
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return o.decode(d, v.Elem(), depth+1)
+	case reflect.Bool:
+		var value bool
+		if value, err = d.Bool(); err == nil {
+			v.SetBool(value)
+		}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		var value int32
+		if value, err = d.Int32(); err == nil {
+			if v.OverflowInt(int64(value)) {
+				return ErrInvalidInt32
+			}
+			v.SetInt(int64(value))
+		}
+	case reflect.Int, reflect.Int64:
+		var value int64
+		if value, err = d.Int64(); err == nil {
+			if v.OverflowInt(value) {
+				return ErrInvalidInt64
+			}
+			v.SetInt(value)
+		}
+	case reflect.Uint8:
+		var value uint8
+		if value, err = d.Uint8(); err == nil {
+			v.SetUint(uint64(value))
+		}
+	case reflect.Uint16:
+		var value uint16
+		if value, err = d.Uint16(); err == nil {
+			v.SetUint(uint64(value))
+		}
+	case reflect.Uint32:
+		var value uint32
+		if value, err = d.Uint32(); err == nil {
+			v.SetUint(uint64(value))
+		}
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		var value uint64
+		if value, err = d.Uint64(); err == nil {
+			if v.OverflowUint(value) {
+				return ErrInvalidUint64
+			}
+			v.SetUint(value)
+		}
+	case reflect.Float32:
+		var value float32
+		if value, err = d.Float32(); err == nil {
+			v.SetFloat(float64(value))
+		}
+	case reflect.Float64:
+		var value float64
+		if value, err = d.Float64(); err == nil {
+			v.SetFloat(value)
+		}
+	case reflect.String:
+		var value string
+		if value, err = d.String(); err == nil {
+			v.SetString(value)
+		}
+	case reflect.Slice:
+		return o.decodeSlice(d, v, depth)
+	case reflect.Array:
+		return o.decodeArray(d, v, depth)
+	case reflect.Map:
+		return o.decodeMap(d, v, depth)
+	case reflect.Struct:
+		return o.decodeStruct(d, v, depth)
+	case reflect.Interface:
+		var value any
+		if value, err = d.Any(); err == nil {
+			if value == nil {
+				v.SetZero()
+				return nil
+			}
+			if !reflect.TypeOf(value).AssignableTo(v.Type()) {
+				return fmt.Errorf("%w: %s can't hold %T", ErrUnsupportedType, v.Type(), value)
+			}
+			v.Set(reflect.ValueOf(value))
+		}
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedType, v.Type())
+	}
+	return err
+}
+
+func (o MarshalOptions) decodeSlice(d *BufferDecoder, v reflect.Value, depth int) error {
+	if v.Type().Elem().Kind() == reflect.Struct && d.Remaining() > 0 && d.b[0] == PackedSliceRawKind {
+		return o.decodePacked(d, v)
+	}
+	if v.Type().Elem().Kind() == reflect.Uint8 {
+		value, err := d.Bytes(nil)
+		if err != nil {
+			return err
+		}
+		v.SetBytes(value)
+		return nil
+	}
+	kind, err := o.kindOf(v.Type().Elem())
+	if err != nil {
+		return err
+	}
+	size, err := d.Slice(kind)
+	if err != nil {
+		return err
+	}
+	// Every element takes at least one byte, which bounds
+	// the allocation for a corrupt or malicious size.
+	if int(size) > d.Remaining() {
+		return ErrInvalidSlice
+	}
+	value := reflect.MakeSlice(v.Type(), int(size), int(size))
+	for i := 0; i < int(size); i++ {
+		if err = o.decode(d, value.Index(i), depth+1); err != nil {
+			return err
+		}
+	}
+	v.Set(value)
+	return nil
+}
+
+func (o MarshalOptions) decodeArray(d *BufferDecoder, v reflect.Value, depth int) error {
+	if v.Type().Elem().Kind() == reflect.Struct && d.Remaining() > 0 && d.b[0] == PackedSliceRawKind {
+		return o.decodePacked(d, v)
+	}
+	if v.Type().Elem().Kind() == reflect.Uint8 {
+		value, err := d.Bytes(nil)
+		if err != nil {
+			return err
+		}
+		if len(value) != v.Len() {
+			return ErrInvalidBytes
+		}
+		reflect.Copy(v, reflect.ValueOf(value))
+		return nil
+	}
+	kind, err := o.kindOf(v.Type().Elem())
+	if err != nil {
+		return err
+	}
+	size, err := d.Slice(kind)
+	if err != nil {
+		return err
+	}
+	if int(size) != v.Len() {
+		return ErrInvalidSlice
+	}
+	for i := 0; i < v.Len(); i++ {
+		if err = o.decode(d, v.Index(i), depth+1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o MarshalOptions) decodeMap(d *BufferDecoder, v reflect.Value, depth int) error {
+	keyKind, err := o.kindOf(v.Type().Key())
+	if err != nil {
+		return err
+	}
+	valueKind, err := o.kindOf(v.Type().Elem())
+	if err != nil {
+		return err
+	}
+	size, err := d.Map(keyKind, valueKind)
+	if err != nil {
+		return err
+	}
+	if int(size) > d.Remaining()/2 {
+		return ErrInvalidMap
+	}
+	value := reflect.MakeMapWithSize(v.Type(), int(size))
+	for i := uint32(0); i < size; i++ {
+		key := reflect.New(v.Type().Key()).Elem()
+		if err = o.decode(d, key, depth+1); err != nil {
+			return err
+		}
+		if d.options.RejectDuplicateKeys && value.MapIndex(key).IsValid() {
+			return ErrDuplicateKey
+		}
+		elem := reflect.New(v.Type().Elem()).Elem()
+		if err = o.decode(d, elem, depth+1); err != nil {
+			return err
+		}
+		value.SetMapIndex(key, elem)
+	}
+	v.Set(value)
+	return nil
+}
+
+func (o MarshalOptions) decodeStruct(d *BufferDecoder, v reflect.Value, depth int) error {
+	info, err := getStructInfo(v.Type(), o.IncludeUnexported)
+	if err != nil {
+		return err
+	}
+	if o.NamedFields {
+		size, err := d.Map(StringKind, AnyKind)
+		if err != nil {
+			return err
+		}
+		for i := uint32(0); i < size; i++ {
+			name, err := d.String()
+			if err != nil {
+				return err
+			}
+			index, ok := info.hashes[FieldHash(name)]
+			if !ok || info.fields[index].name != name {
+				if err = d.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err = o.decodeField(d, v, info.fields[index], depth); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if o.HashedFields {
+		size, err := d.Map(Uint32Kind, AnyKind)
+		if err != nil {
+			return err
+		}
+		for i := uint32(0); i < size; i++ {
+			hash, err := d.Uint32()
+			if err != nil {
+				return err
+			}
+			index, ok := info.hashes[hash]
+			if !ok {
+				if err = d.Skip(); err != nil {
+					return err
+				}
+				continue
+			}
+			if err = o.decodeField(d, v, info.fields[index], depth); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if d.Remaining() > 0 && d.b[0] == BytesRawKind {
+		return o.decodeMasked(d, v, info, depth)
+	}
+	size, err := d.Slice(AnyKind)
+	if err != nil {
+		return err
+	}
+	for i := 0; i < int(size); i++ {
+		if i >= len(info.fields) {
+			if err = d.Skip(); err != nil {
+				return err
+			}
+			continue
+		}
+		if err = o.decodeField(d, v, info.fields[i], depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeField decodes field of the struct v, setting it to its zero
+// value if it's Nil, as it is when it's omitted with omitempty.
+func (o MarshalOptions) decodeField(d *BufferDecoder, v reflect.Value, field structField, depth int) error {
+	value := structFieldValue(v, field)
+	if len(d.b) > 0 && d.b[0] == NilRawKind {
+		d.Nil()
+		value.SetZero()
+		return nil
+	}
+	return o.decode(d, value, depth+1)
+}
+
+// decodeMasked decodes a positionally encoded struct preceded by a bitset of the fields present,
+// skipping the values of any fields beyond those known to this version of the struct.
+func (o MarshalOptions) decodeMasked(d *BufferDecoder, v reflect.Value, info *structInfo, depth int) error {
+	present, err := d.Bytes(nil)
+	if err != nil {
+		return err
+	}
+	size, err := d.Slice(AnyKind)
+	if err != nil {
+		return err
+	}
+	i := 0
+	for n := uint32(0); n < size; n++ {
+		for i < len(present)*8 && present[i/8]&(1<<(i%8)) == 0 {
+			i++
+		}
+		switch {
+		case i == len(present)*8:
+			return ErrInvalidSlice
+		case i >= len(info.fields):
+			err = d.Skip()
+		default:
+			err = o.decodeField(d, v, info.fields[i], depth)
+		}
+		if err != nil {
+			return err
+		}
+		i++
+	}
+	return nil
+}
@@ -0,0 +1,480 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"bytes"
+	"errors"
+	"net/netip"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type marshalNested struct {
+	Name  string
+	Score float64
+}
+
+type marshalStruct struct {
+	Bool    bool
+	Int8    int8
+	Int     int
+	Uint16  uint16
+	Uint    uint
+	Float32 float32
+	String  string `polyglot:"str"`
+	Bytes   []byte
+	Array   [4]byte
+	Slice   []uint32
+	Map     map[string]int64
+	Nested  marshalNested
+	Pointer *marshalNested
+	Nil     *marshalNested
+	Structs []*marshalNested
+	Ignored string `polyglot:"-"`
+	private string
+}
+
+type marshalV1 struct {
+	ID   uint32
+	Name string
+	Tags []string
+}
+
+type marshalV2 struct {
+	Extra  bool
+	Tags   []string
+	ID     uint32
+	Labels map[string]string
+}
+
+func testMarshalStruct() marshalStruct {
+	return marshalStruct{
+		Bool:    true,
+		Int8:    -8,
+		Int:     -1 << 40,
+		Uint16:  16,
+		Uint:    1 << 50,
+		Float32: 32.32,
+		String:  "Test String",
+		Bytes:   []byte("Test Bytes"),
+		Array:   [4]byte{1, 2, 3, 4},
+		Slice:   []uint32{1, 2, 3},
+		Map:     map[string]int64{"1": -1, "2": -2},
+		Nested:  marshalNested{Name: "Nested", Score: 1.5},
+		Pointer: &marshalNested{Name: "Pointer", Score: 2.5},
+		Structs: []*marshalNested{{Name: "1"}, nil, {Name: "3"}},
+		Ignored: "Ignored",
+		private: "private",
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	t.Parallel()
+
+	for _, o := range []MarshalOptions{{}, {HashedFields: true}, {NamedFields: true}} {
+		v := testMarshalStruct()
+		b, err := o.Marshal(&v)
+		assert.NoError(t, err)
+
+		var decoded marshalStruct
+		assert.NoError(t, o.Unmarshal(b, &decoded))
+
+		v.Ignored = ""
+		v.private = ""
+		assert.Equal(t, v, decoded)
+	}
+}
+
+func TestMarshalPositional(t *testing.T) {
+	t.Parallel()
+
+	b, err := Marshal(marshalNested{Name: "Test", Score: 1})
+	assert.NoError(t, err)
+
+	d := Decoder(b)
+	size, err := d.Slice(AnyKind)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2), size)
+
+	name, err := d.String()
+	assert.NoError(t, err)
+	assert.Equal(t, "Test", name)
+
+	score, err := d.Float64()
+	assert.NoError(t, err)
+	assert.Equal(t, float64(1), score)
+}
+
+func TestMarshalHashedFields(t *testing.T) {
+	t.Parallel()
+
+	o := MarshalOptions{HashedFields: true}
+
+	b, err := o.Marshal(marshalV1{ID: 32, Name: "Test", Tags: []string{"1", "2"}})
+	assert.NoError(t, err)
+
+	var v2 marshalV2
+	assert.NoError(t, o.Unmarshal(b, &v2))
+	assert.Equal(t, marshalV2{ID: 32, Tags: []string{"1", "2"}}, v2)
+
+	v2.Extra = true
+	v2.Labels = map[string]string{"1": "2"}
+	b, err = o.Marshal(v2)
+	assert.NoError(t, err)
+
+	var v1 marshalV1
+	assert.NoError(t, o.Unmarshal(b, &v1))
+	assert.Equal(t, marshalV1{ID: 32, Tags: []string{"1", "2"}}, v1)
+}
+
+func TestMarshalAsMap(t *testing.T) {
+	t.Parallel()
+
+	b, err := MarshalAsMap(marshalNested{Name: "Test", Score: 1.5})
+	assert.NoError(t, err)
+
+	// Fields can be looked up by name without knowing the type
+	value, err := Decoder(b).Any()
+	assert.NoError(t, err)
+	assert.Equal(t, map[any]any{"Name": "Test", "Score": 1.5}, value)
+
+	v := testMarshalStruct()
+	b, err = MarshalAsMap(&v)
+	assert.NoError(t, err)
+	value, err = Decoder(b).Any()
+	assert.NoError(t, err)
+	fields := value.(map[any]any)
+	assert.Equal(t, "Test String", fields["str"])
+	assert.Equal(t, map[any]any{"Name": "Nested", "Score": 1.5}, fields["Nested"])
+	assert.NotContains(t, fields, "Ignored")
+	assert.NotContains(t, fields, "String")
+
+	var decoded marshalStruct
+	assert.NoError(t, UnmarshalFromMap(b, &decoded))
+	v.Ignored = ""
+	v.private = ""
+	assert.Equal(t, v, decoded)
+
+	// Extra keys are skipped and missing keys leave their fields untouched
+	b, err = MarshalAsMap(marshalV1{ID: 32, Name: "Test", Tags: []string{"1", "2"}})
+	assert.NoError(t, err)
+	v2 := marshalV2{Extra: true}
+	assert.NoError(t, UnmarshalFromMap(b, &v2))
+	assert.Equal(t, marshalV2{Extra: true, ID: 32, Tags: []string{"1", "2"}}, v2)
+
+	v2.Labels = map[string]string{"1": "2"}
+	b, err = MarshalAsMap(v2)
+	assert.NoError(t, err)
+	v1 := marshalV1{Name: "Kept"}
+	assert.NoError(t, UnmarshalFromMap(b, &v1))
+	assert.Equal(t, marshalV1{ID: 32, Name: "Kept", Tags: []string{"1", "2"}}, v1)
+
+	// Keys that only share a hash with a field aren't mistaken for it
+	p := NewBuffer()
+	Encoder(p).Map(1, StringKind, AnyKind).String("id").Uint32(7)
+	v1 = marshalV1{}
+	assert.NoError(t, UnmarshalFromMap(p.Bytes(), &v1))
+	assert.Equal(t, marshalV1{}, v1)
+
+	// Maps keyed by hash aren't accepted in place of names
+	b, err = MarshalOptions{HashedFields: true}.Marshal(v1)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, UnmarshalFromMap(b, &v1), ErrInvalidMap)
+
+	named, err := MarshalOptions{NamedFields: true}.SchemaFingerprint(v1)
+	assert.NoError(t, err)
+	hashed, err := MarshalOptions{HashedFields: true}.SchemaFingerprint(v1)
+	assert.NoError(t, err)
+	assert.NotEqual(t, named, hashed)
+}
+
+func TestMarshalInterface(t *testing.T) {
+	t.Parallel()
+
+	type dynamic struct {
+		String any
+		Int    any
+		Slice  any
+		Map    any
+		Nil    any
+		Nested []any
+	}
+
+	v := dynamic{
+		String: "Test String",
+		Int:    -64,
+		Slice:  []string{"a", "b"},
+		Map:    map[string]uint32{"1": 1},
+		Nested: []any{int8(8), []byte("Test Bytes"), []any{true, 1.5}},
+	}
+
+	for _, o := range []MarshalOptions{{}, {HashedFields: true}, {NamedFields: true}} {
+		b, err := o.Marshal(v)
+		assert.NoError(t, err)
+
+		var decoded dynamic
+		assert.NoError(t, o.Unmarshal(b, &decoded))
+		assert.Equal(t, dynamic{
+			String: "Test String",
+			Int:    int64(-64),
+			Slice:  []any{"a", "b"},
+			Map:    map[any]any{"1": uint32(1)},
+			Nested: []any{int32(8), []byte("Test Bytes"), []any{true, 1.5}},
+		}, decoded)
+	}
+
+	_, err := Marshal(struct{ Value any }{Value: marshalNested{}})
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+
+	_, err = Marshal(struct{ Value any }{Value: []*marshalNested{}})
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+
+	b, err := Marshal(struct{ Value any }{Value: "Test String"})
+	assert.NoError(t, err)
+	var stringer struct{ Value interface{ String() string } }
+	assert.ErrorIs(t, Unmarshal(b, &stringer), ErrUnsupportedType)
+}
+
+func TestMarshalFieldMask(t *testing.T) {
+	t.Parallel()
+
+	for _, o := range []MarshalOptions{{}, {HashedFields: true}, {NamedFields: true}} {
+		o.FieldMask = []string{"Tags", "ID", "Tags"}
+		b, err := o.Marshal(&marshalV2{Extra: true, Tags: []string{"a"}, ID: 32, Labels: map[string]string{"1": "1"}})
+		assert.NoError(t, err)
+
+		existing := marshalV2{Extra: false, Tags: []string{"b", "c"}, ID: 1, Labels: map[string]string{"2": "2"}}
+		assert.NoError(t, o.Unmarshal(b, &existing))
+		assert.Equal(t, marshalV2{Extra: false, Tags: []string{"a"}, ID: 32, Labels: map[string]string{"2": "2"}}, existing)
+
+		// A plain Unmarshal recognizes a masked struct too
+		existing = marshalV2{Extra: true}
+		assert.NoError(t, MarshalOptions{HashedFields: o.HashedFields, NamedFields: o.NamedFields}.Unmarshal(b, &existing))
+		assert.Equal(t, marshalV2{Extra: true, Tags: []string{"a"}, ID: 32}, existing)
+	}
+
+	b, err := MarshalOptions{FieldMask: []string{}}.Marshal(marshalV1{ID: 32, Name: "Test"})
+	assert.NoError(t, err)
+	existing := marshalV1{ID: 1, Name: "Existing"}
+	assert.NoError(t, Unmarshal(b, &existing))
+	assert.Equal(t, marshalV1{ID: 1, Name: "Existing"}, existing)
+
+	// Fields beyond those known to the target are skipped
+	type extended struct {
+		ID    uint32
+		Name  string
+		Tags  []string
+		Extra string
+	}
+	b, err = MarshalOptions{FieldMask: []string{"Name", "Extra"}}.Marshal(extended{Name: "Test", Extra: "Extra"})
+	assert.NoError(t, err)
+	assert.NoError(t, Unmarshal(b, &existing))
+	assert.Equal(t, marshalV1{ID: 1, Name: "Test"}, existing)
+
+	_, err = MarshalOptions{FieldMask: []string{"Missing"}}.Marshal(marshalV1{})
+	assert.ErrorIs(t, err, ErrUnknownField)
+
+	_, err = MarshalOptions{FieldMask: []string{"ID"}}.Marshal([]uint32{})
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+
+	// A bitset with fewer fields present than values
+	p := NewBuffer()
+	Encoder(p).Bytes([]byte{1}).Slice(2, AnyKind).Uint32(1).String("Test")
+	assert.ErrorIs(t, Unmarshal(p.Bytes(), &existing), ErrInvalidSlice)
+}
+
+type marshalUnexported struct {
+	Name    string
+	count   int
+	weights map[string]float64
+	inner   marshalNested
+	skipped string `polyglot:"-"`
+}
+
+func TestMarshalUnexported(t *testing.T) {
+	t.Parallel()
+
+	v := marshalUnexported{
+		Name:    "checkpoint",
+		count:   42,
+		weights: map[string]float64{"a": 0.5},
+		inner:   marshalNested{Name: "nested", Score: 1.5},
+		skipped: "skipped",
+	}
+
+	for _, o := range []MarshalOptions{{IncludeUnexported: true}, {IncludeUnexported: true, HashedFields: true}} {
+		b, err := o.Marshal(v)
+		assert.NoError(t, err)
+
+		var decoded marshalUnexported
+		assert.NoError(t, o.Unmarshal(b, &decoded))
+		expected := v
+		expected.skipped = ""
+		assert.Equal(t, expected, decoded)
+
+		// Pointers and non-addressable values are handled alike
+		pb, err := o.Marshal(&v)
+		assert.NoError(t, err)
+		assert.Equal(t, b, pb)
+	}
+
+	b, err := Marshal(v)
+	assert.NoError(t, err)
+	var decoded marshalUnexported
+	assert.NoError(t, Unmarshal(b, &decoded))
+	assert.Equal(t, marshalUnexported{Name: "checkpoint"}, decoded)
+}
+
+type marshalBinary struct {
+	At    time.Time
+	Addrs map[netip.Addr]bool
+	Link  *url.URL
+	Seen  []time.Time
+}
+
+type failingBinary struct{}
+
+func (failingBinary) MarshalBinary() ([]byte, error) {
+	return nil, errors.New("can't marshal")
+}
+
+func TestMarshalBinaryFallback(t *testing.T) {
+	t.Parallel()
+
+	v := marshalBinary{
+		At:    time.Date(2023, 4, 5, 6, 7, 8, 9, time.FixedZone("", 3600)),
+		Addrs: map[netip.Addr]bool{netip.MustParseAddr("10.0.0.1"): true},
+		Link:  &url.URL{Scheme: "https", Host: "example.com", Path: "/a"},
+		Seen:  []time.Time{time.Unix(1, 0).UTC()},
+	}
+	options := MarshalOptions{BinaryFallback: true}
+
+	b, err := options.Marshal(v)
+	assert.NoError(t, err)
+
+	var decoded marshalBinary
+	assert.NoError(t, options.Unmarshal(b, &decoded))
+	assert.True(t, v.At.Equal(decoded.At))
+	assert.Equal(t, v.Addrs, decoded.Addrs)
+	assert.Equal(t, v.Link.String(), decoded.Link.String())
+	assert.Equal(t, v.Seen, decoded.Seen)
+
+	d := Decoder(b)
+	_, err = d.Slice(AnyKind)
+	assert.NoError(t, err)
+	at, err := d.Bytes(nil)
+	assert.NoError(t, err)
+	expected, _ := v.At.MarshalBinary()
+	assert.Equal(t, expected, at)
+
+	// Without the option the unexported state of time.Time is lost.
+	b, err = Marshal(v.At)
+	assert.NoError(t, err)
+	var zero time.Time
+	assert.NoError(t, Unmarshal(b, &zero))
+	assert.True(t, zero.IsZero())
+
+	_, err = options.Marshal(struct{ F failingBinary }{})
+	assert.EqualError(t, err, "can't marshal")
+}
+
+func TestFieldHash(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, uint32(0x811c9dc5), FieldHash(""))
+	assert.Equal(t, uint32(0xe40c292c), FieldHash("a"))
+	assert.Equal(t, uint32(0xbf9cf968), FieldHash("foobar"))
+}
+
+func TestMarshalErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := Marshal(struct{ C chan int }{})
+	assert.ErrorIs(t, err, ErrUnsupportedType)
+
+	_, err = Marshal(struct {
+		A string `polyglot:"name"`
+		B string `polyglot:"name"`
+	}{})
+	assert.ErrorIs(t, err, ErrDuplicateField)
+
+	var v marshalV1
+	assert.ErrorIs(t, Unmarshal(nil, v), ErrInvalidTarget)
+
+	b, err := Marshal(marshalV1{ID: 32, Name: "Test"})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, Unmarshal(b, &marshalNested{}), ErrInvalidString)
+
+	var small struct{ Value int8 }
+	b, err = Marshal(struct{ Value int32 }{Value: 1 << 20})
+	assert.NoError(t, err)
+	assert.ErrorIs(t, Unmarshal(b, &small), ErrInvalidInt32)
+
+	var slice []uint32
+	assert.ErrorIs(t, Unmarshal([]byte{SliceRawKind, Uint32RawKind, Uint32RawKind, 0x7F}, &slice), ErrInvalidSlice)
+}
+
+type marshalTree struct {
+	Children []marshalTree
+}
+
+type marshalCycle struct {
+	Next *marshalCycle
+}
+
+func TestMarshalDepth(t *testing.T) {
+	t.Parallel()
+
+	chain := func(n int) marshalTree {
+		var tree marshalTree
+		for i := 0; i < n; i++ {
+			tree = marshalTree{Children: []marshalTree{tree}}
+		}
+		return tree
+	}
+
+	// Every level of the tree is a struct and the slice of its children
+	b, err := Marshal(chain(200))
+	assert.NoError(t, err)
+	var tree marshalTree
+	assert.NoError(t, Unmarshal(b, &tree))
+	again, err := Marshal(tree)
+	assert.NoError(t, err)
+	assert.Equal(t, b, again)
+
+	_, err = Marshal(chain(300))
+	assert.ErrorIs(t, err, ErrTooDeep)
+
+	// Input nested far deeper than any value that could be encoded fails rather than overflowing
+	// the stack, without each level having to be built first
+	leaf, err := Marshal(chain(0))
+	assert.NoError(t, err)
+	level, err := Marshal(chain(1))
+	assert.NoError(t, err)
+	level = level[:len(level)-len(leaf)]
+	deep := append(bytes.Repeat(level, 1<<20), leaf...)
+	assert.ErrorIs(t, Unmarshal(deep, &tree), ErrTooDeep)
+
+	cycle := &marshalCycle{}
+	cycle.Next = cycle
+	_, err = Marshal(cycle)
+	assert.ErrorIs(t, err, ErrTooDeep)
+}
@@ -0,0 +1,61 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+// PolyglotMarshaler is implemented by messages that encode themselves into a Buffer,
+// such as those generated by protoc-gen-go-polyglot.
+type PolyglotMarshaler interface {
+	Encode(b *Buffer)
+}
+
+// PolyglotUnmarshaler is implemented by messages that decode themselves
+// from the current position of a Decoder, advancing it past the message.
+type PolyglotUnmarshaler interface {
+	DecodeFrom(d *BufferDecoder) error
+}
+
+// EncodeMessages encodes msgs as a Slice of AnyKind, with each element encoding itself. As with
+// repeated message fields in generated code, an element can span several values, so the Slice
+// can't be skipped with Skip.
+func EncodeMessages[T PolyglotMarshaler](e *BufferEncoder, msgs []T) *BufferEncoder {
+	e.Slice(uint32(len(msgs)), AnyKind)
+	for _, msg := range msgs {
+		msg.Encode((*Buffer)(e))
+	}
+	return e
+}
+
+// DecodeMessages decodes a Slice encoded with EncodeMessages, calling newT to
+// construct each element before decoding into it.
+func DecodeMessages[T PolyglotUnmarshaler](d *BufferDecoder, newT func() T) ([]T, error) {
+	size, err := d.Slice(AnyKind)
+	if err != nil {
+		return nil, err
+	}
+	if err = d.checkElements(uint64(size), 1, ErrInvalidSlice); err != nil {
+		return nil, err
+	}
+	msgs := make([]T, 0, size)
+	for i := uint32(0); i < size; i++ {
+		msg := newT()
+		if err = msg.DecodeFrom(d); err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, nil
+}
@@ -0,0 +1,99 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"testing"
+)
+
+type testMessage struct {
+	Name  string
+	Value uint32
+	Tags  []string
+}
+
+func (m *testMessage) Encode(b *Buffer) {
+	if m == nil {
+		Encoder(b).Nil()
+		return
+	}
+	e := Encoder(b).String(m.Name).Uint32(m.Value).Slice(uint32(len(m.Tags)), StringKind)
+	for _, tag := range m.Tags {
+		e.String(tag)
+	}
+}
+
+func (m *testMessage) DecodeFrom(d *BufferDecoder) (err error) {
+	if d.Nil() {
+		return nil
+	}
+	if m.Name, err = d.String(); err != nil {
+		return err
+	}
+	if m.Value, err = d.Uint32(); err != nil {
+		return err
+	}
+	size, err := d.Slice(StringKind)
+	if err != nil {
+		return err
+	}
+	m.Tags = make([]string, size)
+	for i := range m.Tags {
+		if m.Tags[i], err = d.String(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestMessages(t *testing.T) {
+	t.Parallel()
+
+	msgs := []*testMessage{
+		{Name: "first", Value: 1, Tags: []string{"a", "b"}},
+		{Name: "second", Value: 2, Tags: []string{}},
+		{Name: "third", Value: 1 << 20, Tags: []string{"c"}},
+	}
+
+	p := NewBuffer()
+	EncodeMessages(Encoder(p), msgs).Bool(true)
+
+	d := Decoder(p.Bytes())
+	value, err := DecodeMessages(d, func() *testMessage {
+		return new(testMessage)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, msgs, value)
+
+	b, err := d.Bool()
+	assert.NoError(t, err)
+	assert.True(t, b)
+
+	_, err = DecodeMessages(Decoder(p.Bytes()[:len(p.Bytes())-4]), func() *testMessage {
+		return new(testMessage)
+	})
+	assert.ErrorIs(t, err, ErrInvalidString)
+
+	p.Reset()
+	Encoder(p).Slice(1<<20, AnyKind)
+	_, err = DecodeMessages(Decoder(p.Bytes()), func() *testMessage {
+		return new(testMessage)
+	})
+	assert.ErrorIs(t, err, ErrInvalidSlice)
+}
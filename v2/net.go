@@ -0,0 +1,168 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// validHardwareAddrLength reports whether size is the length of a
+// MAC-48/EUI-48, EUI-64 or 20-byte IP over InfiniBand address. Zero
+// is also accepted so that a nil address round-trips.
+func validHardwareAddrLength(size int) bool {
+	return size == 0 || size == 6 || size == 8 || size == 20
+}
+
+func encodeHardwareAddr(b *Buffer, value net.HardwareAddr) error {
+	if !validHardwareAddrLength(len(value)) {
+		return fmt.Errorf("%w: length %d", ErrInvalidHardwareAddr, len(value))
+	}
+	b.Grow(2 + len(value))
+	offset := b.offset
+	b.b[offset] = HardwareAddrRawKind
+	offset++
+	b.b[offset] = byte(len(value))
+	offset++
+	b.offset = offset + copy(b.b[offset:], value)
+	return nil
+}
+
+func decodeHardwareAddr(b []byte) ([]byte, net.HardwareAddr, error) {
+	if len(b) > 1 && b[0] == HardwareAddrRawKind {
+		size := int(b[1])
+		if validHardwareAddrLength(size) && len(b)-2 >= size {
+			if size == 0 {
+				return b[2:], nil, nil
+			}
+			value := make(net.HardwareAddr, size)
+			copy(value, b[2:])
+			return b[2+size:], value, nil
+		}
+	}
+	return b, nil, ErrInvalidHardwareAddr
+}
+
+// HardwareAddr encodes a 6, 8 or 20 byte hardware address, or a nil one. Addresses of any
+// other length fail with ErrInvalidHardwareAddr, leaving the buffer unchanged.
+func (e *BufferEncoder) HardwareAddr(value net.HardwareAddr) error {
+	return encodeHardwareAddr((*Buffer)(e), value)
+}
+
+func (d *BufferDecoder) HardwareAddr() (value net.HardwareAddr, err error) {
+	d.b, value, err = decodeHardwareAddr(d.b)
+	err = d.step(err)
+	return
+}
+
+// The networks of the net.Addr types supported by Addr, which are encoded after the kind.
+const (
+	addrTCP = iota + 1
+	addrUDP
+	addrUnix
+	addrUnixgram
+	addrUnixpacket
+)
+
+var unixNetworks = []string{"unix", "unixgram", "unixpacket"}
+
+// encodeAddr writes the network, then for TCP and UDP the IP address as it's encoded by NetipAddr
+// followed by the port as two big-endian bytes, or for Unix sockets the length-prefixed name.
+func encodeAddr(b *Buffer, value net.Addr) error {
+	var network byte
+	var addrPort netip.AddrPort
+	var name string
+	switch value := value.(type) {
+	case *net.TCPAddr:
+		if value == nil {
+			return fmt.Errorf("%w: nil %T", ErrUnsupportedAddr, value)
+		}
+		network, addrPort = addrTCP, value.AddrPort()
+	case *net.UDPAddr:
+		if value == nil {
+			return fmt.Errorf("%w: nil %T", ErrUnsupportedAddr, value)
+		}
+		network, addrPort = addrUDP, value.AddrPort()
+	case *net.UnixAddr:
+		if value == nil {
+			return fmt.Errorf("%w: nil %T", ErrUnsupportedAddr, value)
+		}
+		for i, n := range unixNetworks {
+			if value.Net == n {
+				network = byte(addrUnix + i)
+			}
+		}
+		if network == 0 {
+			return fmt.Errorf("%w: network %q", ErrUnsupportedAddr, value.Net)
+		}
+		name = value.Name
+	default:
+		return fmt.Errorf("%w: %T", ErrUnsupportedAddr, value)
+	}
+	b.Grow(2 + VarIntLen64 + len(name))
+	b.b[b.offset] = AddrRawKind
+	b.b[b.offset+1] = network
+	b.offset += 2
+	if network >= addrUnix {
+		writeUvarint(b, uint64(len(name)))
+		b.offset += copy(b.b[b.offset:], name)
+		return nil
+	}
+	writeNetipAddr(b, addrPort.Addr())
+	b.Grow(2)
+	binary.BigEndian.PutUint16(b.b[b.offset:], addrPort.Port())
+	b.offset += 2
+	return nil
+}
+
+func decodeAddr(b []byte) ([]byte, net.Addr, error) {
+	if len(b) > 2 && b[0] == AddrRawKind {
+		switch network := b[1]; network {
+		case addrTCP, addrUDP:
+			remaining, addr, ok := readNetipAddr(b[2:])
+			if ok && len(remaining) >= 2 {
+				addrPort := netip.AddrPortFrom(addr, binary.BigEndian.Uint16(remaining))
+				if network == addrTCP {
+					return remaining[2:], net.TCPAddrFromAddrPort(addrPort), nil
+				}
+				return remaining[2:], net.UDPAddrFromAddrPort(addrPort), nil
+			}
+		case addrUnix, addrUnixgram, addrUnixpacket:
+			remaining, size, ok := readUvarint(b[2:])
+			if ok && size <= uint64(len(remaining)) {
+				return remaining[size:], &net.UnixAddr{Name: string(remaining[:size]), Net: unixNetworks[network-addrUnix]}, nil
+			}
+		}
+	}
+	return b, nil, ErrInvalidAddr
+}
+
+// Addr encodes a *net.TCPAddr, *net.UDPAddr or *net.UnixAddr, like the addresses of a
+// net.Conn, so that it's decoded as the same type. Any other type, a nil pointer or a
+// Unix network other than unix, unixgram or unixpacket fails with ErrUnsupportedAddr,
+// leaving the buffer unchanged. An IPv4 address keeps its 4 or 16 byte form.
+func (e *BufferEncoder) Addr(value net.Addr) error {
+	return encodeAddr((*Buffer)(e), value)
+}
+
+func (d *BufferDecoder) Addr() (value net.Addr, err error) {
+	d.b, value, err = decodeAddr(d.b)
+	err = d.step(err)
+	return
+}
@@ -0,0 +1,148 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"net"
+	"testing"
+)
+
+func TestHardwareAddr(t *testing.T) {
+	t.Parallel()
+
+	addrs := []net.HardwareAddr{nil}
+	for _, s := range []string{
+		"00:00:5e:00:53:01",
+		"02:00:5e:10:00:00:00:01",
+		"00:00:00:00:fe:80:00:00:00:00:00:00:02:00:5e:10:00:00:00:01",
+	} {
+		addr, err := net.ParseMAC(s)
+		assert.NoError(t, err)
+		addrs = append(addrs, addr)
+	}
+
+	p := NewBuffer()
+	e := Encoder(p)
+	for _, addr := range addrs {
+		assert.NoError(t, e.HardwareAddr(addr))
+	}
+	assert.Equal(t, 2+6, len(p.Bytes())-2-8-2-20-2)
+
+	d := Decoder(p.Bytes())
+	for _, addr := range addrs {
+		value, err := d.HardwareAddr()
+		assert.NoError(t, err)
+		assert.Equal(t, addr, value)
+	}
+	assert.Equal(t, 0, d.Remaining())
+
+	// A length byte that would wrap around to a valid length is never written
+	p.Reset()
+	assert.ErrorIs(t, Encoder(p).HardwareAddr(make(net.HardwareAddr, 262)), ErrInvalidHardwareAddr)
+	assert.ErrorIs(t, Encoder(p).HardwareAddr(net.HardwareAddr{1, 2, 3, 4}), ErrInvalidHardwareAddr)
+	assert.Equal(t, 0, p.Len())
+
+	_, err := Decoder([]byte{HardwareAddrRawKind, 4, 1, 2, 3, 4}).HardwareAddr()
+	assert.ErrorIs(t, err, ErrInvalidHardwareAddr)
+
+	p.Reset()
+	assert.NoError(t, Encoder(p).HardwareAddr(addrs[1]))
+	_, err = Decoder((p.Bytes())[:len(p.Bytes())-1]).HardwareAddr()
+	assert.ErrorIs(t, err, ErrInvalidHardwareAddr)
+
+	_, err = Decoder(p.Bytes()).Bytes(nil)
+	assert.ErrorIs(t, err, ErrInvalidBytes)
+}
+
+type customAddr struct{}
+
+func (customAddr) Network() string { return "custom" }
+func (customAddr) String() string  { return "custom" }
+
+func TestAddr(t *testing.T) {
+	t.Parallel()
+
+	addrs := []net.Addr{
+		&net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 8080},
+		&net.TCPAddr{IP: net.IP{10, 0, 0, 1}, Port: 443},
+		&net.TCPAddr{Port: 80},
+		&net.TCPAddr{IP: net.ParseIP("fe80::1"), Port: 65535, Zone: "eth0"},
+		&net.UDPAddr{IP: net.ParseIP("2001:db8::1"), Port: 53},
+		&net.UDPAddr{IP: net.IP{192, 168, 1, 1}},
+		&net.UnixAddr{Name: "/var/run/app.sock", Net: "unix"},
+		&net.UnixAddr{Name: "@abstract", Net: "unixgram"},
+		&net.UnixAddr{Net: "unixpacket"},
+	}
+
+	p := NewBuffer()
+	for _, addr := range addrs {
+		assert.NoError(t, Encoder(p).Addr(addr))
+	}
+
+	d := Decoder(p.Bytes())
+	for _, addr := range addrs {
+		value, err := d.Addr()
+		assert.NoError(t, err)
+		assert.Equal(t, addr, value)
+		assert.Equal(t, addr.String(), value.String())
+		assert.Equal(t, addr.Network(), value.Network())
+	}
+	assert.Equal(t, 0, d.Remaining())
+
+	d = Decoder(p.Bytes())
+	for _, addr := range addrs {
+		value, err := d.Any()
+		assert.NoError(t, err)
+		assert.Equal(t, addr, value)
+		q := NewBuffer()
+		assert.NoError(t, EncodeAny(Encoder(q), value))
+		value, err = Decoder(q.Bytes()).Addr()
+		assert.NoError(t, err)
+		assert.Equal(t, addr, value)
+	}
+
+	for _, addr := range addrs {
+		p.Reset()
+		assert.NoError(t, Encoder(p).Addr(addr))
+		n, complete, err := MessageComplete(p.Bytes())
+		assert.NoError(t, err)
+		assert.True(t, complete)
+		assert.Equal(t, p.Len(), n)
+		for i := 0; i < p.Len(); i++ {
+			_, err = Decoder(p.Bytes()[:i]).Addr()
+			assert.ErrorIs(t, err, ErrInvalidAddr)
+			if i > 0 {
+				_, complete, err = MessageComplete(p.Bytes()[:i])
+				assert.NoError(t, err)
+				assert.False(t, complete)
+			}
+		}
+	}
+
+	p.Reset()
+	for _, addr := range []net.Addr{nil, customAddr{}, (*net.TCPAddr)(nil), (*net.UnixAddr)(nil), &net.UnixAddr{Name: "x", Net: "tcp"}, &net.IPAddr{IP: net.IPv4(1, 2, 3, 4)}} {
+		assert.ErrorIs(t, Encoder(p).Addr(addr), ErrUnsupportedAddr)
+		assert.Equal(t, 0, p.Len())
+	}
+
+	_, err := Decoder([]byte{AddrRawKind, 0, 0}).Addr()
+	assert.ErrorIs(t, err, ErrInvalidAddr)
+	_, err = Decoder([]byte{AddrRawKind, addrUnixpacket + 1, 0}).Addr()
+	assert.ErrorIs(t, err, ErrInvalidAddr)
+}
@@ -0,0 +1,154 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"net/netip"
+)
+
+const (
+	netipAddrSize   = 2 + 16
+	netipPrefixSize = netipAddrSize + 1
+
+	// netipZoned replaces the address length of an IPv6 address with a zone,
+	// and is followed by the address bytes and then the length-prefixed zone.
+	netipZoned = 0x80 | 16
+)
+
+// A netip.Addr is encoded as its kind, a single byte holding the address length (0, 4 or 16)
+// and the raw address bytes. An IPv6 address with a zone has 0x90 (netipZoned) in place of the
+// length, and the 16 address bytes are followed by the length of the zone as an untagged LEB128
+// varint and then the zone's bytes. A netip.Prefix, which can't have a zone, additionally
+// appends the number of prefix bits.
+func encodeNetipAddr(b *Buffer, value netip.Addr) {
+	b.Grow(1)
+	b.b[b.offset] = NetipAddrRawKind
+	b.offset++
+	writeNetipAddr(b, value)
+}
+
+// writeNetipAddr writes value as it's encoded by encodeNetipAddr, without the kind.
+func writeNetipAddr(b *Buffer, value netip.Addr) {
+	zone := value.Zone()
+	b.Grow(netipAddrSize - 1 + VarIntLen64 + len(zone))
+	b.offset = appendNetipAddr(b, b.offset, value)
+	if zone != "" {
+		// Only IPv6 addresses have zones, so the length byte is 16 bytes back
+		b.b[b.offset-1-16] = netipZoned
+		writeUvarint(b, uint64(len(zone)))
+		b.offset += copy(b.b[b.offset:], zone)
+	}
+}
+
+func encodeNetipPrefix(b *Buffer, value netip.Prefix) {
+	b.Grow(netipPrefixSize)
+	b.b[b.offset] = NetipPrefixRawKind
+	offset := b.offset + 1
+	if value.IsValid() {
+		offset = appendNetipAddr(b, offset, value.Addr())
+		b.b[offset] = byte(value.Bits())
+	} else {
+		b.b[offset] = 0
+		offset++
+		b.b[offset] = 0
+	}
+	b.offset = offset + 1
+}
+
+func appendNetipAddr(b *Buffer, offset int, value netip.Addr) int {
+	switch {
+	case value.Is4():
+		b.b[offset] = 4
+		a := value.As4()
+		return offset + 1 + copy(b.b[offset+1:], a[:])
+	case value.IsValid():
+		b.b[offset] = 16
+		a := value.As16()
+		return offset + 1 + copy(b.b[offset+1:], a[:])
+	default:
+		b.b[offset] = 0
+		return offset + 1
+	}
+}
+
+func decodeNetipAddr(b []byte) ([]byte, netip.Addr, error) {
+	if len(b) > 1 && b[0] == NetipAddrRawKind {
+		if remaining, value, ok := readNetipAddr(b[1:]); ok {
+			return remaining, value, nil
+		}
+	}
+	return b, netip.Addr{}, ErrInvalidNetipAddr
+}
+
+func decodeNetipPrefix(b []byte) ([]byte, netip.Prefix, error) {
+	if len(b) > 2 && b[0] == NetipPrefixRawKind {
+		remaining, addr, ok := readNetipAddr(b[1:])
+		if ok && len(remaining) > 0 && addr.Zone() == "" {
+			bits := int(remaining[0])
+			if !addr.IsValid() {
+				if bits == 0 {
+					return remaining[1:], netip.Prefix{}, nil
+				}
+			} else if bits <= addr.BitLen() {
+				return remaining[1:], netip.PrefixFrom(addr, bits), nil
+			}
+		}
+	}
+	return b, netip.Prefix{}, ErrInvalidPrefix
+}
+
+func readNetipAddr(b []byte) ([]byte, netip.Addr, bool) {
+	if len(b) > 0 {
+		switch size := int(b[0]); {
+		case size == 0:
+			return b[1:], netip.Addr{}, true
+		case size == 4 && len(b) > 4:
+			return b[5:], netip.AddrFrom4([4]byte(b[1:5])), true
+		case size == 16 && len(b) > 16:
+			return b[17:], netip.AddrFrom16([16]byte(b[1:17])), true
+		case size == netipZoned && len(b) > 17:
+			addr := netip.AddrFrom16([16]byte(b[1:17]))
+			remaining, n, ok := readUvarint(b[17:])
+			if ok && n > 0 && n <= uint64(len(remaining)) {
+				return remaining[n:], addr.WithZone(string(remaining[:n])), true
+			}
+		}
+	}
+	return b, netip.Addr{}, false
+}
+
+func (e *BufferEncoder) NetipAddr(value netip.Addr) *BufferEncoder {
+	encodeNetipAddr((*Buffer)(e), value)
+	return e
+}
+
+func (e *BufferEncoder) NetipPrefix(value netip.Prefix) *BufferEncoder {
+	encodeNetipPrefix((*Buffer)(e), value)
+	return e
+}
+
+func (d *BufferDecoder) NetipAddr() (value netip.Addr, err error) {
+	d.b, value, err = decodeNetipAddr(d.b)
+	err = d.step(err)
+	return
+}
+
+func (d *BufferDecoder) NetipPrefix() (value netip.Prefix, err error) {
+	d.b, value, err = decodeNetipPrefix(d.b)
+	err = d.step(err)
+	return
+}
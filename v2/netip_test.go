@@ -0,0 +1,155 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"net/netip"
+	"testing"
+)
+
+func TestNetipAddr(t *testing.T) {
+	t.Parallel()
+
+	addrs := []netip.Addr{
+		{},
+		netip.MustParseAddr("0.0.0.0"),
+		netip.MustParseAddr("192.168.1.1"),
+		netip.MustParseAddr("::"),
+		netip.MustParseAddr("2001:db8::1"),
+		netip.MustParseAddr("::ffff:10.0.0.1"),
+	}
+
+	p := NewBuffer()
+	e := Encoder(p)
+	for _, addr := range addrs {
+		e.NetipAddr(addr)
+	}
+
+	d := Decoder(p.Bytes())
+	for _, addr := range addrs {
+		value, err := d.NetipAddr()
+		assert.NoError(t, err)
+		assert.Equal(t, addr, value)
+	}
+	assert.Equal(t, 0, d.Remaining())
+
+	p.Reset()
+	encodeNetipAddr(p, addrs[4])
+	_, _, err := decodeNetipAddr((p.Bytes())[:len(p.Bytes())-1])
+	assert.ErrorIs(t, err, ErrInvalidNetipAddr)
+
+	(p.Bytes())[1] = 5
+	_, _, err = decodeNetipAddr(p.Bytes())
+	assert.ErrorIs(t, err, ErrInvalidNetipAddr)
+}
+
+func TestNetipAddrZone(t *testing.T) {
+	t.Parallel()
+
+	addrs := []netip.Addr{
+		netip.MustParseAddr("fe80::1"),
+		netip.MustParseAddr("fe80::1%eth0"),
+		netip.MustParseAddr("fe80::abcd:1%en0"),
+		netip.MustParseAddr("ff02::1%2"),
+		netip.MustParseAddr("::ffff:10.0.0.1%eth1"),
+	}
+
+	p := NewBuffer()
+	e := Encoder(p)
+	for _, addr := range addrs {
+		e.NetipAddr(addr)
+	}
+
+	d := Decoder(p.Bytes())
+	for _, addr := range addrs {
+		value, err := d.NetipAddr()
+		assert.NoError(t, err)
+		assert.Equal(t, addr, value)
+		assert.Equal(t, addr.Zone(), value.Zone())
+	}
+	assert.Equal(t, 0, d.Remaining())
+
+	d = Decoder(p.Bytes())
+	for range addrs {
+		assert.NoError(t, d.Skip())
+	}
+	assert.Equal(t, 0, d.Remaining())
+
+	p.Reset()
+	encodeNetipAddr(p, addrs[0])
+	assert.Equal(t, netipAddrSize, p.Len())
+
+	p.Reset()
+	encodeNetipAddr(p, addrs[1])
+	assert.Equal(t, netipAddrSize+1+len("eth0"), p.Len())
+	_, _, err := decodeNetipAddr((p.Bytes())[:len(p.Bytes())-1])
+	assert.ErrorIs(t, err, ErrInvalidNetipAddr)
+
+	(p.Bytes())[netipAddrSize] = 0
+	_, _, err = decodeNetipAddr(p.Bytes())
+	assert.ErrorIs(t, err, ErrInvalidNetipAddr)
+
+	zoned := append([]byte{NetipPrefixRawKind}, p.Bytes()[1:netipAddrSize]...)
+	zoned = append(zoned, 1, 'a', 64)
+	_, _, err = decodeNetipPrefix(zoned)
+	assert.ErrorIs(t, err, ErrInvalidPrefix)
+}
+
+func TestNetipPrefix(t *testing.T) {
+	t.Parallel()
+
+	prefixes := []netip.Prefix{
+		{},
+		netip.MustParsePrefix("0.0.0.0/0"),
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.168.1.1/32"),
+		netip.MustParsePrefix("::/0"),
+		netip.MustParsePrefix("2001:db8::/32"),
+		netip.MustParsePrefix("2001:db8::1/128"),
+	}
+
+	p := NewBuffer()
+	e := Encoder(p)
+	for _, prefix := range prefixes {
+		e.NetipPrefix(prefix)
+	}
+
+	d := Decoder(p.Bytes())
+	for _, prefix := range prefixes {
+		value, err := d.NetipPrefix()
+		assert.NoError(t, err)
+		assert.Equal(t, prefix, value)
+	}
+	assert.Equal(t, 0, d.Remaining())
+
+	p.Reset()
+	encodeNetipPrefix(p, netip.MustParsePrefix("10.0.0.0/8"))
+	(p.Bytes())[len(p.Bytes())-1] = 33
+	_, _, err := decodeNetipPrefix(p.Bytes())
+	assert.ErrorIs(t, err, ErrInvalidPrefix)
+
+	p.Reset()
+	encodeNetipPrefix(p, netip.MustParsePrefix("2001:db8::/32"))
+	(p.Bytes())[len(p.Bytes())-1] = 129
+	_, _, err = decodeNetipPrefix(p.Bytes())
+	assert.ErrorIs(t, err, ErrInvalidPrefix)
+
+	_, _, err = decodeNetipPrefix((p.Bytes())[:len(p.Bytes())-1])
+	assert.ErrorIs(t, err, ErrInvalidPrefix)
+}
@@ -0,0 +1,38 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+// OmitEmpty encodes value with encode, or as Nil if it's the zero value for its type, which
+// shrinks messages with fields that are usually empty. Decode it with DecodeOmitEmpty.
+func OmitEmpty[T comparable](e *BufferEncoder, value T, encode func(*BufferEncoder, T) *BufferEncoder) *BufferEncoder {
+	var zero T
+	if value == zero {
+		return e.Nil()
+	}
+	return encode(e, value)
+}
+
+// DecodeOmitEmpty decodes a value encoded with OmitEmpty, returning
+// the zero value for its type if it was omitted.
+func DecodeOmitEmpty[T any](d *BufferDecoder, decode func(*BufferDecoder) (T, error)) (T, error) {
+	if len(d.b) > 0 && d.b[0] == NilRawKind {
+		d.Nil()
+		var zero T
+		return zero, nil
+	}
+	return decode(d)
+}
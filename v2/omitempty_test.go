@@ -0,0 +1,106 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"testing"
+)
+
+type omitEmptyStruct struct {
+	ID      uint64            `polyglot:"id,omitempty"`
+	Name    string            `polyglot:",omitempty"`
+	Score   float64           `polyglot:",omitempty"`
+	Enabled bool              `polyglot:",omitempty"`
+	Tags    []string          `polyglot:",omitempty"`
+	Labels  map[string]string `polyglot:",omitempty"`
+	Nested  marshalNested     `polyglot:",omitempty"`
+	Count   int32
+}
+
+func TestOmitEmpty(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	e := Encoder(p)
+	OmitEmpty(e, "", (*BufferEncoder).String)
+	OmitEmpty(e, "Test", (*BufferEncoder).String)
+	OmitEmpty(e, 0, (*BufferEncoder).Uint32)
+
+	d := Decoder(p.Bytes())
+	s, err := DecodeOmitEmpty(d, (*BufferDecoder).String)
+	assert.NoError(t, err)
+	assert.Equal(t, "", s)
+	s, err = DecodeOmitEmpty(d, (*BufferDecoder).String)
+	assert.NoError(t, err)
+	assert.Equal(t, "Test", s)
+	u, err := DecodeOmitEmpty(d, (*BufferDecoder).Uint32)
+	assert.NoError(t, err)
+	assert.Zero(t, u)
+	assert.NoError(t, d.Finish())
+}
+
+func TestMarshalOmitEmpty(t *testing.T) {
+	t.Parallel()
+
+	full, err := Marshal(omitEmptyStruct{Name: "Test", Tags: []string{}, Count: 32})
+	assert.NoError(t, err)
+	empty, err := Marshal(omitEmptyStruct{Count: 32})
+	assert.NoError(t, err)
+	assert.Less(t, len(empty), len(full))
+
+	// The header, a Nil for each empty field and the one that isn't tagged.
+	d := Decoder(empty)
+	size, err := d.Slice(AnyKind)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(8), size)
+	for i := 0; i < 7; i++ {
+		assert.True(t, d.Nil())
+	}
+	count, err := d.Int32()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(32), count)
+	assert.NoError(t, d.Finish())
+
+	// Omitted fields are zeroed, even if the target already had a value.
+	decoded := omitEmptyStruct{ID: 1, Name: "Stale", Nested: marshalNested{Name: "Stale"}}
+	assert.NoError(t, Unmarshal(empty, &decoded))
+	assert.Equal(t, omitEmptyStruct{Count: 32}, decoded)
+
+	// Non-nil empty slices aren't the zero value, so they aren't omitted.
+	decoded = omitEmptyStruct{}
+	assert.NoError(t, Unmarshal(full, &decoded))
+	assert.Equal(t, omitEmptyStruct{Name: "Test", Tags: []string{}, Count: 32}, decoded)
+
+	// With HashedFields, empty fields are left out entirely.
+	options := MarshalOptions{HashedFields: true}
+	hashed, err := options.Marshal(omitEmptyStruct{Count: 32})
+	assert.NoError(t, err)
+	_, _, size, err = Decoder(hashed).MapHeader()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), size)
+	decoded = omitEmptyStruct{}
+	assert.NoError(t, options.Unmarshal(hashed, &decoded))
+	assert.Equal(t, omitEmptyStruct{Count: 32}, decoded)
+
+	masked, err := MarshalOptions{HashedFields: true, FieldMask: []string{"id", "Count"}}.Marshal(omitEmptyStruct{Count: 32})
+	assert.NoError(t, err)
+	_, _, size, err = Decoder(masked).MapHeader()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), size)
+}
@@ -0,0 +1,66 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+// Option holds a value of type T, or nothing. The zero value holds nothing.
+type Option[T any] struct {
+	value T
+	ok    bool
+}
+
+// Some returns an Option holding value.
+func Some[T any](value T) Option[T] {
+	return Option[T]{value: value, ok: true}
+}
+
+// None returns an Option holding nothing.
+func None[T any]() Option[T] {
+	return Option[T]{}
+}
+
+// Get returns the value held by o, and whether it holds one.
+func (o Option[T]) Get() (T, bool) {
+	return o.value, o.ok
+}
+
+// IsSome reports whether o holds a value.
+func (o Option[T]) IsSome() bool {
+	return o.ok
+}
+
+// EncodeOption encodes Nil if opt holds nothing, and otherwise the value it holds, written by
+// encode. Since Nil marks an empty Option, encode must not write Nil for any value.
+func EncodeOption[T any](e *BufferEncoder, opt Option[T], encode func(*BufferEncoder, T)) *BufferEncoder {
+	if !opt.ok {
+		return e.Nil()
+	}
+	encode(e, opt.value)
+	return e
+}
+
+// DecodeOption decodes an Option encoded with EncodeOption, with decode reading the value if
+// there is one.
+func DecodeOption[T any](d *BufferDecoder, decode func(*BufferDecoder) (T, error)) (Option[T], error) {
+	if d.Nil() {
+		return None[T](), nil
+	}
+	value, err := decode(d)
+	if err != nil {
+		return None[T](), err
+	}
+	return Some(value), nil
+}
@@ -0,0 +1,71 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"testing"
+)
+
+func TestOption(t *testing.T) {
+	t.Parallel()
+
+	encode := func(e *BufferEncoder, v string) { e.String(v) }
+	decode := func(d *BufferDecoder) (string, error) { return d.String() }
+
+	options := []Option[string]{Some("value"), None[string](), Some(""), {}}
+
+	p := NewBuffer()
+	for _, opt := range options {
+		EncodeOption(Encoder(p), opt, encode)
+	}
+
+	d := Decoder(p.Bytes())
+	for _, opt := range options {
+		decoded, err := DecodeOption(d, decode)
+		assert.NoError(t, err)
+		assert.Equal(t, opt, decoded)
+	}
+	assert.Equal(t, 0, d.Remaining())
+
+	value, ok := Some(42).Get()
+	assert.True(t, ok)
+	assert.Equal(t, 42, value)
+	assert.True(t, Some(0).IsSome())
+
+	value, ok = None[int]().Get()
+	assert.False(t, ok)
+	assert.Equal(t, 0, value)
+	assert.False(t, Option[int]{}.IsSome())
+
+	// None is a single Nil byte, and Some is the value alone
+	p.Reset()
+	EncodeOption(Encoder(p), None[string](), encode)
+	assert.Equal(t, []byte{NilRawKind}, p.Bytes())
+	p.Reset()
+	EncodeOption(Encoder(p), Some("value"), encode)
+	q := NewBuffer()
+	Encoder(q).String("value")
+	assert.Equal(t, q.Bytes(), p.Bytes())
+
+	p.Reset()
+	Encoder(p).Uint32(1)
+	decoded, err := DecodeOption(Decoder(p.Bytes()), decode)
+	assert.ErrorIs(t, err, ErrInvalidString)
+	assert.False(t, decoded.IsSome())
+}
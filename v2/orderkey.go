@@ -0,0 +1,134 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// The tags written before each component of an order key, which order values of different
+// types and must all be below orderKeyEscaped so that a terminated string sorts before any
+// longer one.
+const (
+	orderKeyNil = iota + 1
+	orderKeyFalse
+	orderKeyTrue
+	orderKeyInt
+	orderKeyUint
+	orderKeyFloat
+	orderKeyBytes
+	orderKeyString
+)
+
+const (
+	orderKeyTerminator = 0x00
+	orderKeyEscaped    = 0xFF
+)
+
+// EncodeOrderKey encodes values as a key whose bytes sort, with bytes.Compare, in the same order
+// as the values do when compared one by one, so that it can be used as the key of a sorted
+// key-value store. Unlike the rest of the package the key isn't made of self-describing kinds:
+// each value is a one byte tag for its type followed by
+//
+//   - for signed integers, the value as eight big-endian bytes with the sign bit flipped;
+//   - for unsigned integers, the value as eight big-endian bytes;
+//   - for floats, the bits of the float64 as eight big-endian bytes, with the sign bit flipped
+//     for positive values and every bit flipped for negative ones;
+//   - for strings and byte slices, the bytes with every zero byte followed by 0xFF, ending in
+//     a zero byte;
+//   - for booleans and nil, nothing, as the tag alone orders them.
+//
+// Values of the same Go kind compare naturally, including named types, so a key whose values are
+// a prefix of another's sorts first. Values of different kinds are ordered by kind rather than by
+// value: nil, false, true, signed integers, unsigned integers, floats, byte slices and strings,
+// so an int and a uint in the same position don't compare by value. Floats order -0 before +0,
+// and NaNs outside the infinities according to their sign bit. Any other type fails with
+// ErrUnsupportedType, leaving what was written for the values before it in the buffer.
+func EncodeOrderKey(e *BufferEncoder, values ...any) error {
+	b := (*Buffer)(e)
+	for _, v := range values {
+		if v == nil {
+			writeOrderKey(b, orderKeyNil)
+			continue
+		}
+		switch rv := reflect.ValueOf(v); rv.Kind() {
+		case reflect.Bool:
+			if rv.Bool() {
+				writeOrderKey(b, orderKeyTrue)
+			} else {
+				writeOrderKey(b, orderKeyFalse)
+			}
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			writeOrderKey(b, orderKeyInt)
+			writeOrderKeyUint64(b, uint64(rv.Int())^(1<<63))
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			writeOrderKey(b, orderKeyUint)
+			writeOrderKeyUint64(b, rv.Uint())
+		case reflect.Float32, reflect.Float64:
+			bits := math.Float64bits(rv.Float())
+			if bits&(1<<63) != 0 {
+				bits = ^bits
+			} else {
+				bits ^= 1 << 63
+			}
+			writeOrderKey(b, orderKeyFloat)
+			writeOrderKeyUint64(b, bits)
+		case reflect.String:
+			writeOrderKey(b, orderKeyString)
+			writeOrderKeyBytes(b, rv.String())
+		case reflect.Slice:
+			if rv.Type().Elem().Kind() != reflect.Uint8 {
+				return fmt.Errorf("%w: %T", ErrUnsupportedType, v)
+			}
+			writeOrderKey(b, orderKeyBytes)
+			writeOrderKeyBytes(b, string(rv.Bytes()))
+		default:
+			return fmt.Errorf("%w: %T", ErrUnsupportedType, v)
+		}
+	}
+	return nil
+}
+
+func writeOrderKey(b *Buffer, tag byte) {
+	b.Grow(1)
+	b.b[b.offset] = tag
+	b.offset++
+}
+
+func writeOrderKeyUint64(b *Buffer, value uint64) {
+	b.Grow(8)
+	binary.BigEndian.PutUint64(b.b[b.offset:], value)
+	b.offset += 8
+}
+
+func writeOrderKeyBytes(b *Buffer, value string) {
+	b.Grow(len(value) + 1)
+	for i := 0; i < len(value); i++ {
+		b.b[b.offset] = value[i]
+		b.offset++
+		if value[i] == orderKeyTerminator {
+			b.Grow(len(value) - i + 1)
+			b.b[b.offset] = orderKeyEscaped
+			b.offset++
+		}
+	}
+	b.b[b.offset] = orderKeyTerminator
+	b.offset++
+}
@@ -0,0 +1,84 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"bytes"
+	"cmp"
+	"math"
+	"slices"
+	"testing"
+)
+
+func orderKey(t *testing.T, values ...any) []byte {
+	p := NewBuffer()
+	assert.NoError(t, EncodeOrderKey(Encoder(p), values...))
+	return p.Bytes()
+}
+
+// assertOrdered checks that the keys of values, which must already be sorted, sort in the same order.
+func assertOrdered[T any](t *testing.T, values []T) {
+	for i := 1; i < len(values); i++ {
+		assert.Equal(t, -1, bytes.Compare(orderKey(t, values[i-1]), orderKey(t, values[i])), "%v < %v", values[i-1], values[i])
+	}
+}
+
+func TestOrderKey(t *testing.T) {
+	t.Parallel()
+
+	assertOrdered(t, []int64{math.MinInt64, math.MinInt64 + 1, -1 << 32, -256, -1, 0, 1, 255, 256, 1 << 40, math.MaxInt64})
+	assertOrdered(t, []int8{math.MinInt8, -1, 0, 1, math.MaxInt8})
+	assertOrdered(t, []uint64{0, 1, 255, 256, 1 << 63, math.MaxUint64})
+	assertOrdered(t, []float64{math.Inf(-1), -math.MaxFloat64, -1.5, -math.SmallestNonzeroFloat64, math.Copysign(0, -1), 0, math.SmallestNonzeroFloat64, 1, 1.5, math.MaxFloat64, math.Inf(1), math.NaN()})
+	assertOrdered(t, []float32{-2.5, -1, 0, 0.25, 3})
+	assertOrdered(t, []string{"", "\x00", "\x00\x00", "\x00\x01", "\x01", "a", "a\x00", "a\x00b", "a\x01", "aa", "ab", "b", "\xff", "\xff\xff"})
+	assertOrdered(t, [][]byte{nil, {0}, {0, 0xFF}, {1}, {0xFF}})
+	assertOrdered(t, []any{nil, false, true, int64(math.MaxInt64), uint8(0), math.Inf(-1), []byte("z"), ""})
+
+	// Named types order like their underlying type
+	type userID int32
+	assertOrdered(t, []userID{-7, 3, 9})
+
+	// Composite keys order by their first value, then their second, and a prefix sorts first
+	type entry struct {
+		name string
+		id   int
+	}
+	entries := []entry{{"b", 2}, {"a", 10}, {"a\x00", -1}, {"", 5}, {"a", -3}, {"b", 1}, {"ab", 0}}
+	slices.SortFunc(entries, func(x, y entry) int {
+		if c := cmp.Compare(x.name, y.name); c != 0 {
+			return c
+		}
+		return cmp.Compare(x.id, y.id)
+	})
+	keys := make([][]byte, len(entries))
+	for i, e := range entries {
+		keys[i] = orderKey(t, e.name, e.id)
+	}
+	assert.True(t, slices.IsSortedFunc(keys, bytes.Compare))
+	assert.Equal(t, -1, bytes.Compare(orderKey(t, "a"), orderKey(t, "a", math.MinInt64)))
+	assert.Equal(t, -1, bytes.Compare(orderKey(t, "a", math.MaxInt64), orderKey(t, "a\x00")))
+
+	assert.Equal(t, []byte{orderKeyInt, 0x80, 0, 0, 0, 0, 0, 0, 1, orderKeyString, 'a', 0, 0xFF, 'b', 0}, orderKey(t, 1, "a\x00b"))
+	assert.Equal(t, []byte{orderKeyTrue, orderKeyNil}, orderKey(t, true, nil))
+
+	p := NewBuffer()
+	assert.ErrorIs(t, EncodeOrderKey(Encoder(p), "a", []int{1}), ErrUnsupportedType)
+	assert.ErrorIs(t, EncodeOrderKey(Encoder(p), struct{}{}), ErrUnsupportedType)
+}
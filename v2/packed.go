@@ -0,0 +1,251 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"encoding/binary"
+	"math"
+	"reflect"
+)
+
+// packedKind returns the kind a struct field of type t is encoded as in a PackedSlice, and
+// whether it can be packed at all, which is only the case for booleans, integers and floats.
+func packedKind(t reflect.Type) (Kind, bool) {
+	switch t.Kind() {
+	case reflect.Bool:
+		return BoolKind, true
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return Int32Kind, true
+	case reflect.Int, reflect.Int64:
+		return Int64Kind, true
+	case reflect.Uint8:
+		return Uint8Kind, true
+	case reflect.Uint16:
+		return Uint16Kind, true
+	case reflect.Uint32:
+		return Uint32Kind, true
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return Uint64Kind, true
+	case reflect.Float32:
+		return Float32Kind, true
+	case reflect.Float64:
+		return Float64Kind, true
+	}
+	return NilKind, false
+}
+
+// packedSize returns the number of bytes a field of the given kind takes up in a packed
+// record, or zero if the kind can't be packed.
+func packedSize(kind byte) int {
+	switch kind {
+	case BoolRawKind, Uint8RawKind:
+		return 1
+	case Uint16RawKind:
+		return 2
+	case Uint32RawKind, Int32RawKind, Float32RawKind:
+		return 4
+	case Uint64RawKind, Int64RawKind, Float64RawKind:
+		return 8
+	}
+	return 0
+}
+
+func encodePackedSliceHeader(b *Buffer, size int, kinds []Kind) {
+	b.Grow(1 + 2*VarIntLen64 + len(kinds))
+	b.b[b.offset] = PackedSliceRawKind
+	b.offset++
+	writeUvarint(b, uint64(size))
+	writeUvarint(b, uint64(len(kinds)))
+	for _, kind := range kinds {
+		b.b[b.offset] = byte(kind)
+		b.offset++
+	}
+}
+
+// decodePackedSlice decodes the header of a PackedSlice, returning the kinds of the fields in
+// each record and the records themselves, which are validated to fit in b.
+func decodePackedSlice(b []byte) ([]byte, []byte, []byte, int, error) {
+	if len(b) > 2 && b[0] == PackedSliceRawKind {
+		remaining, size, ok := readUvarint(b[1:])
+		if !ok {
+			return b, nil, nil, 0, ErrInvalidPackedSlice
+		}
+		var fields uint64
+		if remaining, fields, ok = readUvarint(remaining); !ok || fields == 0 || fields > uint64(len(remaining)) {
+			return b, nil, nil, 0, ErrInvalidPackedSlice
+		}
+		kinds := remaining[:fields]
+		remaining = remaining[fields:]
+		record := 0
+		for _, kind := range kinds {
+			n := packedSize(kind)
+			if n == 0 {
+				return b, nil, nil, 0, ErrInvalidPackedSlice
+			}
+			record += n
+		}
+		if size <= uint64(len(remaining)/record) {
+			n := int(size) * record
+			return remaining[n:], kinds, remaining[:n], int(size), nil
+		}
+	}
+	return b, nil, nil, 0, ErrInvalidPackedSlice
+}
+
+func (d *BufferDecoder) packedSlice() (kinds []byte, records []byte, size int, err error) {
+	d.b, kinds, records, size, err = decodePackedSlice(d.b)
+	err = d.step(err)
+	return
+}
+
+// packedStruct returns the kinds of the fields of the struct type t
+// if they can all be packed, or nil otherwise.
+func (o MarshalOptions) packedStruct(t reflect.Type) ([]Kind, error) {
+	if t.Kind() != reflect.Struct || o.keyed() || o.usesBinary(t) {
+		return nil, nil
+	}
+	info, err := getStructInfo(t, o.IncludeUnexported)
+	if err != nil || len(info.fields) == 0 {
+		return nil, err
+	}
+	kinds := make([]Kind, len(info.fields))
+	for i, field := range info.fields {
+		var ok bool
+		ft := t.Field(field.index).Type
+		if kinds[i], ok = packedKind(ft); !ok || o.usesBinary(ft) {
+			return nil, nil
+		}
+	}
+	return kinds, nil
+}
+
+// encodePacked encodes v, a slice or array of structs with the given field kinds, as a PackedSlice.
+func (o MarshalOptions) encodePacked(b *Buffer, v reflect.Value, kinds []Kind) {
+	info, _ := getStructInfo(v.Type().Elem(), o.IncludeUnexported)
+	record := 0
+	for _, kind := range kinds {
+		record += packedSize(byte(kind))
+	}
+	encodePackedSliceHeader(b, v.Len(), kinds)
+	b.Grow(v.Len() * record)
+	for i := 0; i < v.Len(); i++ {
+		elem := o.addressable(v.Index(i))
+		for j, field := range info.fields {
+			f := structFieldValue(elem, field)
+			out := b.b[b.offset:]
+			switch kinds[j] {
+			case BoolKind:
+				out[0] = 0
+				if f.Bool() {
+					out[0] = 1
+				}
+			case Uint8Kind:
+				out[0] = byte(f.Uint())
+			case Uint16Kind:
+				binary.LittleEndian.PutUint16(out, uint16(f.Uint()))
+			case Uint32Kind:
+				binary.LittleEndian.PutUint32(out, uint32(f.Uint()))
+			case Uint64Kind:
+				binary.LittleEndian.PutUint64(out, f.Uint())
+			case Int32Kind:
+				binary.LittleEndian.PutUint32(out, uint32(f.Int()))
+			case Int64Kind:
+				binary.LittleEndian.PutUint64(out, uint64(f.Int()))
+			case Float32Kind:
+				binary.LittleEndian.PutUint32(out, math.Float32bits(float32(f.Float())))
+			case Float64Kind:
+				binary.LittleEndian.PutUint64(out, math.Float64bits(f.Float()))
+			}
+			b.offset += packedSize(byte(kinds[j]))
+		}
+	}
+}
+
+// decodePacked decodes a PackedSlice into v, a slice or array of structs. Like positionally
+// encoded structs, fields beyond those of the target struct are ignored and missing ones are
+// left as they are, but the kinds of the fields present in both must match.
+func (o MarshalOptions) decodePacked(d *BufferDecoder, v reflect.Value) error {
+	info, err := getStructInfo(v.Type().Elem(), o.IncludeUnexported)
+	if err != nil {
+		return err
+	}
+	kinds, records, size, err := d.packedSlice()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(kinds) && i < len(info.fields); i++ {
+		want, ok := packedKind(v.Type().Elem().Field(info.fields[i].index).Type)
+		if !ok || byte(want) != kinds[i] {
+			return &KindMismatchError{Got: Kind(kinds[i]), Want: want}
+		}
+	}
+	if v.Kind() == reflect.Array {
+		if size != v.Len() {
+			return ErrInvalidSlice
+		}
+	} else {
+		v.Set(reflect.MakeSlice(v.Type(), size, size))
+	}
+	for i := 0; i < size; i++ {
+		elem := v.Index(i)
+		for j, kind := range kinds {
+			n := packedSize(kind)
+			in := records[:n]
+			records = records[n:]
+			if j >= len(info.fields) {
+				continue
+			}
+			f := structFieldValue(elem, info.fields[j])
+			switch kind {
+			case BoolRawKind:
+				if in[0] > 1 {
+					return ErrInvalidBool
+				}
+				f.SetBool(in[0] == 1)
+			case Uint8RawKind:
+				f.SetUint(uint64(in[0]))
+			case Uint16RawKind:
+				f.SetUint(uint64(binary.LittleEndian.Uint16(in)))
+			case Uint32RawKind:
+				f.SetUint(uint64(binary.LittleEndian.Uint32(in)))
+			case Uint64RawKind:
+				value := binary.LittleEndian.Uint64(in)
+				if f.OverflowUint(value) {
+					return ErrInvalidUint64
+				}
+				f.SetUint(value)
+			case Int32RawKind:
+				value := int64(int32(binary.LittleEndian.Uint32(in)))
+				if f.OverflowInt(value) {
+					return ErrInvalidInt32
+				}
+				f.SetInt(value)
+			case Int64RawKind:
+				value := int64(binary.LittleEndian.Uint64(in))
+				if f.OverflowInt(value) {
+					return ErrInvalidInt64
+				}
+				f.SetInt(value)
+			case Float32RawKind:
+				f.SetFloat(float64(math.Float32frombits(binary.LittleEndian.Uint32(in))))
+			case Float64RawKind:
+				f.SetFloat(math.Float64frombits(binary.LittleEndian.Uint64(in)))
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"io"
+	"testing"
+)
+
+type packedPoint struct {
+	X, Y  int32
+	Scale float64
+	Valid bool
+	ID    uint16 `polyglot:"id"`
+}
+
+type packedPointV1 struct {
+	X, Y int32
+}
+
+func TestPackStructs(t *testing.T) {
+	t.Parallel()
+
+	points := []packedPoint{
+		{X: 1, Y: -2, Scale: 0.5, Valid: true, ID: 7},
+		{X: -1 << 31, Y: 1<<31 - 1, Scale: -1e300, ID: 65535},
+		{},
+	}
+	options := MarshalOptions{PackStructs: true}
+
+	packed, err := options.Marshal(points)
+	assert.NoError(t, err)
+	assert.Equal(t, PackedSliceRawKind, packed[0])
+	positional, err := Marshal(points)
+	assert.NoError(t, err)
+	assert.Less(t, len(packed), len(positional))
+
+	var decoded []packedPoint
+	assert.NoError(t, Unmarshal(packed, &decoded))
+	assert.Equal(t, points, decoded)
+
+	var array [3]packedPoint
+	assert.NoError(t, Unmarshal(packed, &array))
+	assert.Equal(t, points, array[:])
+
+	var short [2]packedPoint
+	assert.ErrorIs(t, Unmarshal(packed, &short), ErrInvalidSlice)
+
+	fromArray, err := options.Marshal(array)
+	assert.NoError(t, err)
+	assert.Equal(t, packed, fromArray)
+
+	// Readers with fewer fields ignore the rest of each record.
+	var v1 []packedPointV1
+	assert.NoError(t, Unmarshal(packed, &v1))
+	assert.Equal(t, []packedPointV1{{X: 1, Y: -2}, {X: -1 << 31, Y: 1<<31 - 1}, {}}, v1)
+
+	var mismatched []struct {
+		X string
+	}
+	assert.ErrorIs(t, Unmarshal(packed, &mismatched), ErrKindMismatch)
+
+	var narrow []struct {
+		X int8
+	}
+	assert.ErrorIs(t, Unmarshal(packed, &narrow), ErrInvalidInt32)
+
+	d := Decoder(packed)
+	assert.NoError(t, d.Skip())
+	assert.NoError(t, d.Finish())
+
+	_, _, err = Decoder(packed).ReadTyped()
+	assert.ErrorIs(t, err, ErrContainerKind)
+
+	assert.ErrorIs(t, Unmarshal(packed[:len(packed)-1], &decoded), ErrInvalidPackedSlice)
+	assert.ErrorIs(t, Decoder(packed[:len(packed)-1]).Skip(), io.ErrUnexpectedEOF)
+	assert.ErrorIs(t, Decoder([]byte{PackedSliceRawKind, 1, 1, StringRawKind, 0}).Skip(), ErrInvalidPackedSlice)
+	assert.ErrorIs(t, Unmarshal([]byte{PackedSliceRawKind, 1, 1, StringRawKind, 0}, &decoded), ErrInvalidPackedSlice)
+
+	// Structs with fields that can't be packed, and HashedFields, fall back to a Slice.
+	encoded, err := options.Marshal([]marshalNested{{Name: "a"}})
+	assert.NoError(t, err)
+	assert.Equal(t, SliceRawKind, encoded[0])
+	encoded, err = MarshalOptions{PackStructs: true, HashedFields: true}.Marshal(points)
+	assert.NoError(t, err)
+	assert.Equal(t, SliceRawKind, encoded[0])
+}
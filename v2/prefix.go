@@ -0,0 +1,139 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// prefixVarintSize is the kind followed by the longest PrefixVarint, a zero byte and eight bytes.
+const prefixVarintSize = 10
+
+// writePrefixUvarint writes kind followed by value as a PrefixVarint. Unlike LEB128, the
+// number of bytes n is given up front by the number of trailing zero bits of the first byte,
+// which is followed by a set bit and the value in the remaining 7n bits, little-endian. Values
+// that need more than 56 bits are written as a zero byte followed by all eight bytes of the
+// value, which is read with a single load rather than a loop over ten LEB128 bytes.
+func writePrefixUvarint(b *Buffer, kind byte, value uint64) {
+	b.Grow(prefixVarintSize)
+	b.b[b.offset] = kind
+	out := b.b[b.offset+1:]
+	n := (bits.Len64(value) + 6) / 7
+	if n == 0 {
+		n = 1
+	}
+	if n > 8 {
+		out[0] = 0
+		binary.LittleEndian.PutUint64(out[1:], value)
+		b.offset += 10
+		return
+	}
+	x := (value<<1 | 1) << (n - 1)
+	for i := 0; i < n; i++ {
+		out[i] = byte(x >> (8 * i))
+	}
+	b.offset += 1 + n
+}
+
+// prefixUvarintLen returns the length of the PrefixVarint starting with first,
+// which is 9 when first is zero.
+func prefixUvarintLen(first byte) int {
+	return bits.TrailingZeros8(first) + 1
+}
+
+// readPrefixUvarint reads a PrefixVarint from the start of b, returning false if b is too short.
+// The common case of a value of at most 56 bits followed by enough bytes to load eight at once
+// is kept small enough to be inlined, loading them all and then discarding those after the value
+// with the left shift and the length bits with the right.
+func readPrefixUvarint(b []byte) ([]byte, uint64, bool) {
+	if len(b) >= 8 && b[0] != 0 {
+		n := prefixUvarintLen(b[0])
+		shift := uint(64 - 8*n)
+		return b[n:], binary.LittleEndian.Uint64(b) << shift >> (shift + uint(n)), true
+	}
+	return readPrefixUvarintSlow(b)
+}
+
+func readPrefixUvarintSlow(b []byte) ([]byte, uint64, bool) {
+	if len(b) == 0 {
+		return b, 0, false
+	}
+	n := prefixUvarintLen(b[0])
+	if len(b) < n {
+		return b, 0, false
+	}
+	if n == 9 {
+		return b[9:], binary.LittleEndian.Uint64(b[1:]), true
+	}
+	var x uint64
+	for i := n - 1; i >= 0; i-- {
+		x = x<<8 | uint64(b[i])
+	}
+	return b[n:], x >> n, true
+}
+
+func encodePrefixUint64(b *Buffer, value uint64) {
+	writePrefixUvarint(b, PrefixUint64RawKind, value)
+}
+
+func encodePrefixInt64(b *Buffer, value int64) {
+	castValue := uint64(value) << 1
+	if value < 0 {
+		castValue = ^castValue
+	}
+	writePrefixUvarint(b, PrefixInt64RawKind, castValue)
+}
+
+func decodePrefixUint64(b []byte) ([]byte, uint64, error) {
+	if len(b) > 1 && b[0] == PrefixUint64RawKind {
+		if remaining, value, ok := readPrefixUvarint(b[1:]); ok {
+			return remaining, value, nil
+		}
+	}
+	return b, 0, ErrInvalidUint64
+}
+
+func decodePrefixInt64(b []byte) ([]byte, int64, error) {
+	if len(b) > 1 && b[0] == PrefixInt64RawKind {
+		if remaining, ux, ok := readPrefixUvarint(b[1:]); ok {
+			x := int64(ux >> 1)
+			if ux&1 != 0 {
+				x = -(x + 1)
+			}
+			return remaining, x, nil
+		}
+	}
+	return b, 0, ErrInvalidInt64
+}
+
+// PrefixUint64 encodes value as a PrefixVarint rather than the LEB128 varint used by Uint64.
+// It's faster to decode for values of more than 56 bits, like hashes or random IDs, but slower
+// for small values, as Decoder.Uint64 only tries it after LEB128. Decoder.Uint64 accepts
+// either, so Go readers don't need to know which one the writer chose, but the Rust and
+// TypeScript decoders don't read PrefixVarints, so they must only be written for Go readers.
+func (e *BufferEncoder) PrefixUint64(value uint64) *BufferEncoder {
+	encodePrefixUint64((*Buffer)(e), value)
+	return e
+}
+
+// PrefixInt64 encodes value as a zig-zag PrefixVarint, and is decoded by Decoder.Int64. Like
+// PrefixUint64, it only pays off for large values and can't be read by the other languages.
+func (e *BufferEncoder) PrefixInt64(value int64) *BufferEncoder {
+	encodePrefixInt64((*Buffer)(e), value)
+	return e
+}
@@ -0,0 +1,106 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"io"
+	"math"
+	"testing"
+)
+
+func TestPrefixVarint(t *testing.T) {
+	t.Parallel()
+
+	unsigned := []uint64{0, 1, 127, 128, 1<<14 - 1, 1 << 14, 1<<56 - 1, 1 << 56, math.MaxUint64}
+	signed := []int64{0, -1, 63, -64, 64, math.MaxInt64, math.MinInt64}
+
+	p := NewBuffer()
+	e := Encoder(p)
+	for _, v := range unsigned {
+		e.PrefixUint64(v)
+	}
+	for _, v := range signed {
+		e.PrefixInt64(v)
+	}
+
+	d := Decoder(p.Bytes())
+	for _, v := range unsigned {
+		value, err := d.Uint64()
+		assert.NoError(t, err)
+		assert.Equal(t, v, value)
+	}
+	for _, v := range signed {
+		value, err := d.Int64()
+		assert.NoError(t, err)
+		assert.Equal(t, v, value)
+	}
+	assert.NoError(t, d.Finish())
+
+	d = Decoder(p.Bytes())
+	for range unsigned {
+		assert.NoError(t, d.Skip())
+	}
+	kind, value, err := d.ReadTyped()
+	assert.NoError(t, err)
+	assert.Equal(t, PrefixInt64Kind, kind)
+	assert.Equal(t, int64(0), value)
+
+	// The length of each value is given by its first byte.
+	p.Reset()
+	Encoder(p).PrefixUint64(127).PrefixUint64(128).PrefixUint64(math.MaxUint64)
+	assert.Equal(t, []byte{
+		PrefixUint64RawKind, 0xFF,
+		PrefixUint64RawKind, 0x02, 0x02,
+		PrefixUint64RawKind, 0x00, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF,
+	}, p.Bytes())
+
+	last := p.Bytes()[5:]
+	for i := 0; i < len(last); i++ {
+		_, err = skipValue(last[:i], 0)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+		_, _, err = decodePrefixUint64(last[:i])
+		assert.ErrorIs(t, err, ErrInvalidUint64)
+	}
+	_, _, err = decodePrefixInt64([]byte{PrefixInt64RawKind, 0x04, 0x00})
+	assert.ErrorIs(t, err, ErrInvalidInt64)
+	_, err = Decoder(p.Bytes()).Int64()
+	assert.ErrorIs(t, err, ErrInvalidInt64)
+}
+
+func TestMarshalPrefixVarints(t *testing.T) {
+	t.Parallel()
+
+	v := struct {
+		U  uint64
+		I  int
+		S  []int64
+		U8 uint8
+	}{U: math.MaxUint64, I: -1 << 40, S: []int64{1, -2}, U8: 8}
+
+	b, err := MarshalOptions{PrefixVarints: true}.Marshal(v)
+	assert.NoError(t, err)
+	plain, err := Marshal(v)
+	assert.NoError(t, err)
+	assert.NotEqual(t, plain, b)
+
+	decoded := v
+	decoded.U, decoded.I, decoded.S, decoded.U8 = 0, 0, nil, 0
+	assert.NoError(t, Unmarshal(b, &decoded))
+	assert.Equal(t, v, decoded)
+}
@@ -0,0 +1,65 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// encodeRegexp writes the source of value, tagged with RegexpRawKind rather than StringRawKind
+// so that tooling can tell patterns apart from plain strings. Whether value was switched to
+// leftmost-longest matching with Longest isn't encoded. A nil value is encoded as an empty
+// pattern, which matches everything.
+func encodeRegexp(b *Buffer, value *regexp.Regexp) {
+	var s string
+	if value != nil {
+		s = value.String()
+	}
+	b.Grow(1 + VarIntLen64 + len(s))
+	b.b[b.offset] = RegexpRawKind
+	b.offset++
+	writeUvarint(b, uint64(len(s)))
+	b.offset += copy(b.b[b.offset:], s)
+}
+
+func decodeRegexp(b []byte) ([]byte, *regexp.Regexp, error) {
+	if len(b) > 1 && b[0] == RegexpRawKind {
+		remaining, size, ok := readUvarint(b[1:])
+		if ok && size <= uint64(len(remaining)) {
+			value, err := regexp.Compile(string(remaining[:size]))
+			if err != nil {
+				return b, nil, fmt.Errorf("%w: %w", ErrInvalidRegexp, err)
+			}
+			return remaining[size:], value, nil
+		}
+	}
+	return b, nil, ErrInvalidRegexp
+}
+
+func (e *BufferEncoder) Regexp(value *regexp.Regexp) *BufferEncoder {
+	encodeRegexp((*Buffer)(e), value)
+	return e
+}
+
+// Regexp decodes and compiles a pattern encoded with Regexp. If the pattern doesn't
+// compile, the returned error wraps both ErrInvalidRegexp and the *syntax.Error.
+func (d *BufferDecoder) Regexp() (value *regexp.Regexp, err error) {
+	d.b, value, err = decodeRegexp(d.b)
+	err = d.step(err)
+	return
+}
@@ -0,0 +1,68 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"errors"
+	"regexp"
+	"regexp/syntax"
+	"testing"
+)
+
+func TestRegexp(t *testing.T) {
+	t.Parallel()
+
+	patterns := []*regexp.Regexp{
+		regexp.MustCompile(`^[a-z]+\[[0-9]+\]$`),
+		regexp.MustCompile(`(?i)héllo\s+(?P<name>\w+)`),
+		regexp.MustCompile(``),
+	}
+
+	p := NewBuffer()
+	e := Encoder(p)
+	for _, re := range patterns {
+		e.Regexp(re)
+	}
+
+	d := Decoder(p.Bytes())
+	for _, re := range patterns {
+		value, err := d.Regexp()
+		assert.NoError(t, err)
+		assert.Equal(t, re.String(), value.String())
+		assert.Equal(t, re.SubexpNames(), value.SubexpNames())
+	}
+	assert.Equal(t, 0, d.Remaining())
+
+	d = Decoder(p.Bytes())
+	kind, value, err := d.ReadTyped()
+	assert.NoError(t, err)
+	assert.Equal(t, RegexpKind, kind)
+	assert.True(t, value.(*regexp.Regexp).MatchString("abc[12]"))
+	assert.NoError(t, d.Skip())
+	assert.NoError(t, d.Skip())
+	assert.Equal(t, 0, d.Remaining())
+
+	_, err = Decoder([]byte{RegexpRawKind, 2, '(', 'a'}).Regexp()
+	assert.ErrorIs(t, err, ErrInvalidRegexp)
+	var syntaxErr *syntax.Error
+	assert.True(t, errors.As(err, &syntaxErr))
+
+	_, err = Decoder([]byte{RegexpRawKind, 2, 'a'}).Regexp()
+	assert.ErrorIs(t, err, ErrInvalidRegexp)
+}
@@ -0,0 +1,66 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+const (
+	resultOk  = byte(0)
+	resultErr = byte(1)
+)
+
+func encodeResult(b *Buffer, ok bool) {
+	b.Grow(2)
+	b.b[b.offset] = ResultRawKind
+	b.b[b.offset+1] = resultOk
+	if !ok {
+		b.b[b.offset+1] = resultErr
+	}
+	b.offset += 2
+}
+
+// decodeResult also checks that the error branch holds an Error, so that a Result
+// can't claim to be an error while carrying something else.
+func decodeResult(b []byte) ([]byte, bool, error) {
+	if len(b) > 2 && b[0] == ResultRawKind {
+		switch b[1] {
+		case resultOk:
+			return b[2:], false, nil
+		case resultErr:
+			if b[2] == ErrorRawKind {
+				return b[2:], true, nil
+			}
+		}
+	}
+	return b, false, ErrInvalidResult
+}
+
+// Result encodes the success or error union of an RPC response. If ok is true, fn encodes the
+// value, and otherwise it must encode the error with Error.
+func (e *BufferEncoder) Result(ok bool, fn func(*BufferEncoder)) *BufferEncoder {
+	encodeResult((*Buffer)(e), ok)
+	fn(e)
+	return e
+}
+
+// Result decodes the discriminator written by BufferEncoder.Result, returning d positioned at
+// the value if isErr is false, and at the error to be decoded with Error if it's true.
+func (d *BufferDecoder) Result() (isErr bool, value *BufferDecoder, err error) {
+	d.b, isErr, err = decodeResult(d.b)
+	if err = d.step(err); err != nil {
+		return false, nil, err
+	}
+	return isErr, d, nil
+}
@@ -0,0 +1,65 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"errors"
+	"testing"
+)
+
+func TestResult(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).
+		Result(true, func(e *BufferEncoder) { e.String("value") }).
+		Result(false, func(e *BufferEncoder) { e.Error(errors.New("not found")) }).
+		Bool(true)
+
+	d := Decoder(p.Bytes())
+	isErr, vd, err := d.Result()
+	assert.NoError(t, err)
+	assert.False(t, isErr)
+	value, err := vd.String()
+	assert.NoError(t, err)
+	assert.Equal(t, "value", value)
+
+	isErr, vd, err = d.Result()
+	assert.NoError(t, err)
+	assert.True(t, isErr)
+	resultErr, err := vd.Error()
+	assert.NoError(t, err)
+	assert.EqualError(t, resultErr, "not found")
+
+	d = Decoder(p.Bytes())
+	assert.NoError(t, d.Skip())
+	assert.NoError(t, d.Skip())
+	_, err = d.Bool()
+	assert.NoError(t, err)
+
+	p.Reset()
+	Encoder(p).Result(false, func(e *BufferEncoder) { e.String("not an error") })
+	_, _, err = Decoder(p.Bytes()).Result()
+	assert.ErrorIs(t, err, ErrInvalidResult)
+
+	_, _, err = Decoder([]byte{ResultRawKind, 2, BoolRawKind, 1}).Result()
+	assert.ErrorIs(t, err, ErrInvalidResult)
+	_, _, err = Decoder([]byte{ResultRawKind, resultOk}).Result()
+	assert.ErrorIs(t, err, ErrInvalidResult)
+}
@@ -0,0 +1,49 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"fmt"
+)
+
+// DecodePanicError is returned by SafeDecode when the decode function panics,
+// and matches ErrDecodePanic with errors.Is.
+type DecodePanicError struct {
+	Value any
+}
+
+func (e *DecodePanicError) Error() string {
+	return fmt.Sprintf("%s: %v", ErrDecodePanic, e.Value)
+}
+
+func (e *DecodePanicError) Unwrap() error {
+	return ErrDecodePanic
+}
+
+// SafeDecode calls fn, recovering from any panic and returning it as a *DecodePanicError.
+//
+// This is a safety net for services decoding untrusted input, not a substitute for bounds
+// checking: a decoder that panics part way through may have left its target partially
+// populated, so on error the decoded values must be discarded.
+func SafeDecode(fn func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &DecodePanicError{Value: r}
+		}
+	}()
+	return fn()
+}
@@ -0,0 +1,70 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"errors"
+	"testing"
+)
+
+func TestSafeDecode(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).Slice(4, Uint32Kind).Uint32(1).Uint32(2)
+
+	var values []uint32
+	err := SafeDecode(func() error {
+		d := Decoder(p.Bytes())
+		size, err := d.Slice(Uint32Kind)
+		if err != nil {
+			return err
+		}
+		values = make([]uint32, 2)
+		for i := uint32(0); i < size; i++ {
+			// Trusts the encoded size, and so indexes past the end of values
+			values[i], err = d.Uint32()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, ErrDecodePanic)
+
+	var panicErr *DecodePanicError
+	assert.True(t, errors.As(err, &panicErr))
+	assert.ErrorContains(t, panicErr.Value.(error), "index out of range")
+
+	err = SafeDecode(func() error {
+		_, err := Decoder(p.Bytes()).String()
+		return err
+	})
+	assert.ErrorIs(t, err, ErrInvalidString)
+	assert.NotErrorIs(t, err, ErrDecodePanic)
+
+	assert.NoError(t, SafeDecode(func() error {
+		return nil
+	}))
+
+	err = SafeDecode(func() error {
+		panic("Test Panic")
+	})
+	assert.EqualError(t, err, "panic during decode: Test Panic")
+}
@@ -0,0 +1,150 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// mapFromFlushSize is how much MapFrom encodes before flushing, bounding the
+// memory used while streaming a map rather than holding the whole map.
+const mapFromFlushSize = 32 << 10
+
+// Sink is the destination that a SinkEncoder flushes encoded bytes to, such as a
+// ring buffer, a memory-mapped region or a custom allocator. Implementations must
+// copy b if they need it after Append returns, as the SinkEncoder reuses it.
+type Sink interface {
+	Append(b []byte) error
+}
+
+// Append implements Sink, so a Buffer can be used as the destination of a SinkEncoder.
+func (buf *Buffer) Append(b []byte) error {
+	buf.Write(b)
+	return nil
+}
+
+// SinkEncoder encodes values into a reusable scratch Buffer, and hands the
+// encoded bytes to its Sink whenever Flush is called.
+type SinkEncoder struct {
+	*BufferEncoder
+	buf  *Buffer
+	sink Sink
+}
+
+func NewSinkEncoder(sink Sink) *SinkEncoder {
+	buf := NewBuffer()
+	return &SinkEncoder{
+		BufferEncoder: Encoder(buf),
+		buf:           buf,
+		sink:          sink,
+	}
+}
+
+// Flush appends everything encoded since the previous Flush to the Sink. The scratch
+// Buffer is reset even if the Sink returns an error, so the failed bytes are dropped.
+func (s *SinkEncoder) Flush() error {
+	if s.buf.Len() == 0 {
+		return nil
+	}
+	err := s.sink.Append(s.buf.Bytes())
+	s.buf.Reset()
+	return err
+}
+
+// WriterEncoder encodes values and writes them to an io.Writer whenever Flush is called.
+//
+// If the writer is a *bytes.Buffer, values are encoded directly into its spare capacity,
+// so Flush only has to extend the buffer rather than copy the bytes. Other data can be
+// written to the bytes.Buffer between a Flush and the next call to Encoder, but not while
+// encoded values are pending, as it would overwrite them.
+type WriterEncoder struct {
+	buf *Buffer
+	w   io.Writer
+}
+
+func NewEncoderWriter(w io.Writer) *WriterEncoder {
+	buf := NewBufferFromBytes(nil)
+	if _, ok := w.(*bytes.Buffer); !ok {
+		buf.b = make([]byte, defaultSize)
+	}
+	return &WriterEncoder{
+		buf: buf,
+		w:   w,
+	}
+}
+
+// Encoder returns the BufferEncoder to encode the next values with. It must be called
+// again after every Flush, rather than being kept, to pick up where the writer now ends.
+func (e *WriterEncoder) Encoder() *BufferEncoder {
+	if bb, ok := e.w.(*bytes.Buffer); ok && e.buf.Len() == 0 {
+		bb.Grow(defaultSize)
+		e.buf.b = bb.AvailableBuffer()
+		e.buf.b = e.buf.b[:cap(e.buf.b)]
+	}
+	return Encoder(e.buf)
+}
+
+// Flush writes everything encoded since the previous Flush to the writer. The scratch
+// Buffer is reset even if the writer returns an error, so the failed bytes are dropped.
+func (e *WriterEncoder) Flush() error {
+	if e.buf.Len() == 0 {
+		return nil
+	}
+	_, err := e.w.Write(e.buf.Bytes())
+	e.buf.Reset()
+	return err
+}
+
+// encodeMapFrom writes a Map header for n entries, then encodes n entries pulled from next with
+// EncodeAny, calling flush whenever the encoder returned by enc has buffered mapFromFlushSize bytes.
+func encodeMapFrom(enc func() *BufferEncoder, flush func() error, n uint32, keyKind, valueKind Kind, next func() (k, v any, ok bool)) error {
+	enc().Map(n, keyKind, valueKind)
+	for i := uint32(0); i < n; i++ {
+		k, v, ok := next()
+		if !ok {
+			return fmt.Errorf("%w: iterator ended after %d of %d entries", ErrInvalidMap, i, n)
+		}
+		e := enc()
+		if err := EncodeAny(e, k); err != nil {
+			return err
+		}
+		if err := EncodeAny(e, v); err != nil {
+			return err
+		}
+		if e.offset >= mapFromFlushSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// MapFrom encodes a Map of n entries pulled from next, flushing to the Sink as it goes so that
+// the whole map is never held in memory. Keys and values are encoded with EncodeAny, and next
+// must return exactly n entries, as the header has already been written by the time it runs out.
+// The entries still buffered when MapFrom returns are left for the next Flush.
+func (s *SinkEncoder) MapFrom(n uint32, keyKind, valueKind Kind, next func() (k, v any, ok bool)) error {
+	return encodeMapFrom(func() *BufferEncoder { return s.BufferEncoder }, s.Flush, n, keyKind, valueKind, next)
+}
+
+// MapFrom is like SinkEncoder.MapFrom, flushing to the writer as it goes.
+func (e *WriterEncoder) MapFrom(n uint32, keyKind, valueKind Kind, next func() (k, v any, ok bool)) error {
+	return encodeMapFrom(e.Encoder, e.Flush, n, keyKind, valueKind, next)
+}
@@ -0,0 +1,189 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// ringSink is a fixed size Sink that wraps around and rejects
+// writes that would overwrite bytes that haven't been read yet.
+type ringSink struct {
+	b     []byte
+	read  int
+	write int
+}
+
+var errRingFull = errors.New("ring full")
+
+func (r *ringSink) Append(b []byte) error {
+	if len(b) > len(r.b)-(r.write-r.read) {
+		return errRingFull
+	}
+	for _, c := range b {
+		r.b[r.write%len(r.b)] = c
+		r.write++
+	}
+	return nil
+}
+
+func (r *ringSink) Read(n int) []byte {
+	out := make([]byte, n)
+	for i := range out {
+		out[i] = r.b[r.read%len(r.b)]
+		r.read++
+	}
+	return out
+}
+
+func TestSinkEncoder(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	s := NewSinkEncoder(p)
+	s.String("Test String").Uint32(32)
+	assert.Equal(t, 0, p.Len())
+	assert.NoError(t, s.Flush())
+	s.Bool(true)
+	assert.NoError(t, s.Flush())
+	assert.NoError(t, s.Flush())
+
+	expected := NewBuffer()
+	Encoder(expected).String("Test String").Uint32(32).Bool(true)
+	assert.Equal(t, expected.Bytes(), p.Bytes())
+}
+
+func TestSinkEncoderRing(t *testing.T) {
+	t.Parallel()
+
+	r := &ringSink{b: make([]byte, 16)}
+	s := NewSinkEncoder(r)
+
+	expected := NewBuffer()
+	for i := uint32(0); i < 8; i++ {
+		s.Uint32(i << 20).String("ok")
+		assert.NoError(t, s.Flush())
+
+		expected.Reset()
+		Encoder(expected).Uint32(i << 20).String("ok")
+		assert.Equal(t, expected.Bytes(), r.Read(expected.Len()))
+	}
+
+	s.Bytes(make([]byte, 16))
+	assert.ErrorIs(t, s.Flush(), errRingFull)
+	assert.NoError(t, s.Flush())
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(b []byte) (int, error) {
+	return f(b)
+}
+
+func TestWriterEncoder(t *testing.T) {
+	t.Parallel()
+
+	expected := NewBuffer()
+	Encoder(expected).String("hello").Uint32(32)
+
+	var bb bytes.Buffer
+	bb.WriteString("header")
+	e := NewEncoderWriter(&bb)
+	e.Encoder().String("hello").Uint32(32)
+	assert.Same(t, &bb.Bytes()[:bb.Len()+1][bb.Len()], &e.buf.b[0])
+	assert.NoError(t, e.Flush())
+	bb.WriteString("middle")
+	e.Encoder().String("hello").Uint32(32)
+	assert.NoError(t, e.Flush())
+	assert.NoError(t, e.Flush())
+	assert.Equal(t, "header"+string(expected.Bytes())+"middle"+string(expected.Bytes()), bb.String())
+
+	// Values bigger than the spare capacity still end up in the bytes.Buffer.
+	bb.Reset()
+	e = NewEncoderWriter(&bb)
+	large := bytes.Repeat([]byte{1}, 4*defaultSize)
+	e.Encoder().Bytes(large)
+	assert.NoError(t, e.Flush())
+	v, err := Decoder(bb.Bytes()).Bytes(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, large, v)
+
+	var written []byte
+	errWrite := errors.New("write failed")
+	w := NewEncoderWriter(writerFunc(func(b []byte) (int, error) {
+		if written != nil {
+			return 0, errWrite
+		}
+		written = append(written, b...)
+		return len(b), nil
+	}))
+	w.Encoder().String("hello").Uint32(32)
+	assert.NoError(t, w.Flush())
+	assert.Equal(t, expected.Bytes(), written)
+	w.Encoder().String("dropped")
+	assert.ErrorIs(t, w.Flush(), errWrite)
+	assert.Equal(t, 0, w.buf.Len())
+}
+
+func TestMapFrom(t *testing.T) {
+	t.Parallel()
+
+	iterator := func(n int) func() (any, any, bool) {
+		i := 0
+		return func() (any, any, bool) {
+			if i == n {
+				return nil, nil, false
+			}
+			i++
+			return uint32(i), strings.Repeat("v", 100), true
+		}
+	}
+
+	var bb bytes.Buffer
+	w := NewEncoderWriter(&bb)
+	assert.NoError(t, w.MapFrom(1000, Uint32Kind, StringKind, iterator(1000)))
+	// Most of the map was flushed while it was being encoded
+	assert.Greater(t, bb.Len(), 1000*100-mapFromFlushSize)
+	assert.NoError(t, w.Flush())
+
+	d := Decoder(bb.Bytes())
+	size, err := d.Map(Uint32Kind, StringKind)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1000), size)
+	for i := uint32(1); i <= size; i++ {
+		k, err := d.Uint32()
+		assert.NoError(t, err)
+		assert.Equal(t, i, k)
+		v, err := d.String()
+		assert.NoError(t, err)
+		assert.Len(t, v, 100)
+	}
+	assert.Equal(t, 0, d.Remaining())
+
+	p := NewBuffer()
+	s := NewSinkEncoder(p)
+	assert.NoError(t, s.MapFrom(1000, Uint32Kind, StringKind, iterator(1000)))
+	assert.NoError(t, s.Flush())
+	assert.Equal(t, bb.Bytes(), p.Bytes())
+
+	assert.ErrorIs(t, s.MapFrom(10, Uint32Kind, StringKind, iterator(5)), ErrInvalidMap)
+}
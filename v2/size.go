@@ -0,0 +1,97 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"math/bits"
+)
+
+// The Size functions return the exact number of bytes the Encoder method of the same name writes
+// for a value, so that the size of a message can be added up ahead of encoding it to create a
+// buffer of the right size with NewEncoderSize.
+
+// uvarintSize returns the number of bytes value takes as a varint.
+func uvarintSize(value uint64) int {
+	return (bits.Len64(value|1) + 6) / 7
+}
+
+// zigzag maps value to the unsigned value written for it as a zig-zag varint.
+func zigzag(value int64) uint64 {
+	return uint64(value<<1) ^ uint64(value>>63)
+}
+
+func SizeNil() int {
+	return nilSize
+}
+
+func SizeBool() int {
+	return boolSize
+}
+
+func SizeUint8() int {
+	return uint8Size
+}
+
+func SizeUint16(value uint16) int {
+	return 1 + uvarintSize(uint64(value))
+}
+
+func SizeUint32(value uint32) int {
+	return 1 + uvarintSize(uint64(value))
+}
+
+func SizeUint64(value uint64) int {
+	return 1 + uvarintSize(value)
+}
+
+func SizeInt32(value int32) int {
+	return 1 + uvarintSize(zigzag(int64(value)))
+}
+
+func SizeInt64(value int64) int {
+	return 1 + uvarintSize(zigzag(value))
+}
+
+func SizeFloat32() int {
+	return float32Size
+}
+
+func SizeFloat64() int {
+	return float64Size
+}
+
+func SizeString(value string) int {
+	return 1 + SizeUint32(uint32(len(value))) + len(value)
+}
+
+func SizeBytes(value []byte) int {
+	return 1 + SizeUint32(uint32(len(value))) + len(value)
+}
+
+func SizeError(value error) int {
+	return 1 + SizeString(value.Error())
+}
+
+// SizeSlice returns the size of the header of a slice of size elements, without the elements.
+func SizeSlice(size uint32) int {
+	return 2 + SizeUint32(size)
+}
+
+// SizeMap returns the size of the header of a map of size entries, without the entries.
+func SizeMap(size uint32) int {
+	return 3 + SizeUint32(size)
+}
@@ -0,0 +1,90 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"errors"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestSize(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	size := func(encode func(e *BufferEncoder)) int {
+		p.Reset()
+		encode(Encoder(p))
+		return p.Len()
+	}
+
+	assert.Equal(t, size(func(e *BufferEncoder) { e.Nil() }), SizeNil())
+	assert.Equal(t, size(func(e *BufferEncoder) { e.Bool(true) }), SizeBool())
+	assert.Equal(t, size(func(e *BufferEncoder) { e.Uint8(255) }), SizeUint8())
+	assert.Equal(t, size(func(e *BufferEncoder) { e.Float32(1) }), SizeFloat32())
+	assert.Equal(t, size(func(e *BufferEncoder) { e.Float64(1) }), SizeFloat64())
+
+	for _, v := range []uint64{0, 1, 127, 128, 16383, 16384, math.MaxUint16, math.MaxUint32, 1 << 56, math.MaxUint64} {
+		if v <= math.MaxUint16 {
+			assert.Equal(t, size(func(e *BufferEncoder) { e.Uint16(uint16(v)) }), SizeUint16(uint16(v)), "%d", v)
+		}
+		if v <= math.MaxUint32 {
+			assert.Equal(t, size(func(e *BufferEncoder) { e.Uint32(uint32(v)) }), SizeUint32(uint32(v)), "%d", v)
+			assert.Equal(t, size(func(e *BufferEncoder) { e.Slice(uint32(v), AnyKind) }), SizeSlice(uint32(v)), "%d", v)
+			assert.Equal(t, size(func(e *BufferEncoder) { e.Map(uint32(v), AnyKind, AnyKind) }), SizeMap(uint32(v)), "%d", v)
+		}
+		assert.Equal(t, size(func(e *BufferEncoder) { e.Uint64(v) }), SizeUint64(v), "%d", v)
+	}
+
+	for _, v := range []int64{0, 1, -1, 63, -64, 64, -65, math.MaxInt32, math.MinInt32, math.MaxInt64, math.MinInt64} {
+		if v >= math.MinInt32 && v <= math.MaxInt32 {
+			assert.Equal(t, size(func(e *BufferEncoder) { e.Int32(int32(v)) }), SizeInt32(int32(v)), "%d", v)
+		}
+		assert.Equal(t, size(func(e *BufferEncoder) { e.Int64(v) }), SizeInt64(v), "%d", v)
+	}
+
+	for _, v := range []string{"", "a", strings.Repeat("a", 127), strings.Repeat("a", 128), strings.Repeat("a", 20000)} {
+		assert.Equal(t, size(func(e *BufferEncoder) { e.String(v) }), SizeString(v))
+		assert.Equal(t, size(func(e *BufferEncoder) { e.Bytes([]byte(v)) }), SizeBytes([]byte(v)))
+		assert.Equal(t, size(func(e *BufferEncoder) { e.Error(errors.New(v)) }), SizeError(errors.New(v)))
+	}
+}
+
+func TestNewEncoderSize(t *testing.T) {
+	t.Parallel()
+
+	tags := []string{"alpha", "beta", "gamma"}
+	size := SizeString("name") + SizeUint64(math.MaxUint64) + SizeSlice(uint32(len(tags)))
+	for _, tag := range tags {
+		size += SizeString(tag)
+	}
+	// The last value is a small Uint64, which is checked for room for a whole varint
+	size += SizeUint64(0)
+
+	e := NewEncoderSize(size)
+	capacity := e.Buffer().Cap()
+	e.String("name").Uint64(math.MaxUint64).Slice(uint32(len(tags)), StringKind)
+	for _, tag := range tags {
+		e.String(tag)
+	}
+	e.Uint64(0)
+	assert.Equal(t, size, e.Buffer().Len())
+	assert.Equal(t, capacity, e.Buffer().Cap())
+}
@@ -0,0 +1,570 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"io"
+	"math/bits"
+)
+
+const (
+	// maxSkipDepth bounds how deeply nested slices and maps can be
+	// skipped, to protect against maliciously deep input.
+	maxSkipDepth = 512
+)
+
+// skipValue advances b past the next value, whatever its kind. It returns io.ErrUnexpectedEOF
+// if b ends before the value does, which lets callers distinguish incomplete input from invalid input.
+func skipValue(b []byte, depth int) ([]byte, error) {
+	if len(b) == 0 {
+		return b, io.ErrUnexpectedEOF
+	}
+	switch b[0] {
+	case NilRawKind, EmptyRawKind:
+		return b[1:], nil
+	case SliceRawKind:
+		if depth >= maxSkipDepth {
+			return b, ErrInvalidSlice
+		}
+		if len(b) < 2 {
+			return b, io.ErrUnexpectedEOF
+		}
+		remaining, size, err := skipLength(b[2:], ErrInvalidSlice)
+		if err != nil {
+			return b, err
+		}
+		for i := uint64(0); i < size; i++ {
+			if remaining, err = skipValue(remaining, depth+1); err != nil {
+				return b, err
+			}
+		}
+		return remaining, nil
+	case MapRawKind:
+		if depth >= maxSkipDepth {
+			return b, ErrInvalidMap
+		}
+		if len(b) < 3 {
+			return b, io.ErrUnexpectedEOF
+		}
+		remaining, size, err := skipLength(b[3:], ErrInvalidMap)
+		if err != nil {
+			return b, err
+		}
+		for i := uint64(0); i < size*2; i++ {
+			if remaining, err = skipValue(remaining, depth+1); err != nil {
+				return b, err
+			}
+		}
+		return remaining, nil
+	case SetRawKind:
+		if depth >= maxSkipDepth {
+			return b, ErrInvalidSet
+		}
+		if len(b) < 2 {
+			return b, io.ErrUnexpectedEOF
+		}
+		remaining, size, err := skipUvarint(b[2:], ErrInvalidSet)
+		if err != nil {
+			return b, err
+		}
+		for i := uint64(0); i < size; i++ {
+			if remaining, err = skipValue(remaining, depth+1); err != nil {
+				return b, err
+			}
+		}
+		return remaining, nil
+	case SparseSliceRawKind:
+		if depth >= maxSkipDepth {
+			return b, ErrInvalidSparseSlice
+		}
+		if len(b) < 2 {
+			return b, io.ErrUnexpectedEOF
+		}
+		remaining, size, err := skipUvarint(b[2:], ErrInvalidSparseSlice)
+		if err != nil {
+			return b, err
+		}
+		if size > uint64(len(remaining))*8 || uint64(len(remaining)) < (size+7)/8 {
+			return b, io.ErrUnexpectedEOF
+		}
+		present := 0
+		for _, c := range remaining[:(size+7)/8] {
+			present += bits.OnesCount8(c)
+		}
+		remaining = remaining[(size+7)/8:]
+		for i := 0; i < present; i++ {
+			if remaining, err = skipValue(remaining, depth+1); err != nil {
+				return b, err
+			}
+		}
+		return remaining, nil
+	case ChecksumSliceRawKind:
+		// Like a Slice, followed by the CRC-32C of the elements
+		if depth >= maxSkipDepth {
+			return b, ErrInvalidChecksumSlice
+		}
+		if len(b) < 2 {
+			return b, io.ErrUnexpectedEOF
+		}
+		remaining, size, err := skipUvarint(b[2:], ErrInvalidChecksumSlice)
+		if err != nil {
+			return b, err
+		}
+		for i := uint64(0); i < size; i++ {
+			if remaining, err = skipValue(remaining, depth+1); err != nil {
+				return b, err
+			}
+		}
+		if remaining, err = skipFixed(remaining, 4); err != nil {
+			return b, err
+		}
+		return remaining, nil
+	case ResultRawKind:
+		// The discriminator and the value or error
+		if depth >= maxSkipDepth {
+			return b, ErrInvalidResult
+		}
+		if len(b) < 2 {
+			return b, io.ErrUnexpectedEOF
+		}
+		remaining, err := skipValue(b[2:], depth+1)
+		if err != nil {
+			return b, err
+		}
+		return remaining, nil
+	case PackedSliceRawKind:
+		// The field kinds, then a fixed size record for each element
+		remaining, n, err := skipUvarint(b[1:], ErrInvalidPackedSlice)
+		if err != nil {
+			return b, err
+		}
+		var fields uint64
+		if remaining, fields, err = skipUvarint(remaining, ErrInvalidPackedSlice); err != nil {
+			return b, err
+		}
+		if fields == 0 {
+			return b, ErrInvalidPackedSlice
+		}
+		if uint64(len(remaining)) < fields {
+			return b, io.ErrUnexpectedEOF
+		}
+		record := 0
+		for _, kind := range remaining[:fields] {
+			size := packedSize(kind)
+			if size == 0 {
+				return b, ErrInvalidPackedSlice
+			}
+			record += size
+		}
+		remaining = remaining[fields:]
+		if uint64(len(remaining))/uint64(record) < n {
+			return b, io.ErrUnexpectedEOF
+		}
+		return remaining[n*uint64(record):], nil
+	case RLESliceRawKind:
+		// An element and a Uint32 length for each run
+		if depth >= maxSkipDepth {
+			return b, ErrInvalidRLESlice
+		}
+		if len(b) < 2 {
+			return b, io.ErrUnexpectedEOF
+		}
+		remaining, _, err := skipUvarint(b[2:], ErrInvalidRLESlice)
+		if err != nil {
+			return b, err
+		}
+		var runs uint64
+		if remaining, runs, err = skipUvarint(remaining, ErrInvalidRLESlice); err != nil {
+			return b, err
+		}
+		// Checked before doubling, which would wrap for a huge runs
+		if runs > uint64(len(remaining)/2) {
+			return b, io.ErrUnexpectedEOF
+		}
+		for i := uint64(0); i < 2*runs; i++ {
+			if remaining, err = skipValue(remaining, depth+1); err != nil {
+				return b, err
+			}
+		}
+		return remaining, nil
+	case DeltaMapRawKind:
+		// The keys as untagged varints followed by the values
+		if depth >= maxSkipDepth {
+			return b, ErrInvalidDeltaMap
+		}
+		if len(b) < 2 {
+			return b, io.ErrUnexpectedEOF
+		}
+		remaining, size, err := skipUvarint(b[2:], ErrInvalidDeltaMap)
+		if err != nil {
+			return b, err
+		}
+		for i := uint64(0); i < size; i++ {
+			if remaining, _, err = skipUvarint(remaining, ErrInvalidDeltaMap); err != nil {
+				return b, err
+			}
+		}
+		for i := uint64(0); i < size; i++ {
+			if remaining, err = skipValue(remaining, depth+1); err != nil {
+				return b, err
+			}
+		}
+		return remaining, nil
+	case BytesRawKind:
+		return skipSized(b, b[1:], ErrInvalidBytes)
+	case StringRawKind:
+		return skipSized(b, b[1:], ErrInvalidString)
+	case ErrorRawKind:
+		if len(b) > 1 && b[1] != StringRawKind {
+			return b, ErrInvalidError
+		}
+		return skipSized(b, b[min(len(b), 2):], ErrInvalidError)
+	case Base64RawKind:
+		if len(b) > 1 && b[1] != StringRawKind {
+			return b, ErrInvalidBytes
+		}
+		return skipSized(b, b[min(len(b), 2):], ErrInvalidBytes)
+	case BoolRawKind, Uint8RawKind:
+		return skipFixed(b, boolSize)
+	case Uint16RawKind:
+		return skipVarint(b, VarIntLen16, ErrInvalidUint16)
+	case Uint32RawKind:
+		return skipVarint(b, VarIntLen32, ErrInvalidUint32)
+	case Uint64RawKind:
+		return skipVarint(b, VarIntLen64, ErrInvalidUint64)
+	case Int32RawKind:
+		return skipVarint(b, VarIntLen32, ErrInvalidInt32)
+	case Int64RawKind:
+		return skipVarint(b, VarIntLen64, ErrInvalidInt64)
+	case PrefixUint64RawKind, PrefixInt64RawKind:
+		if len(b) < 2 {
+			return b, io.ErrUnexpectedEOF
+		}
+		return skipFixed(b, 1+prefixUvarintLen(b[1]))
+	case Float32RawKind:
+		return skipFixed(b, float32Size)
+	case Float64RawKind:
+		return skipFixed(b, float64Size)
+	case NetipAddrRawKind:
+		if len(b) < 2 {
+			return b, io.ErrUnexpectedEOF
+		}
+		if b[1] == netipZoned {
+			remaining, err := skipFixed(b, netipAddrSize)
+			if err != nil {
+				return b, err
+			}
+			if remaining, err = skipUvarintSized(remaining, ErrInvalidNetipAddr); err != nil {
+				return b, err
+			}
+			return remaining, nil
+		}
+		return skipFixed(b, 2+int(b[1]))
+	case AddrRawKind:
+		if len(b) < 3 {
+			return b, io.ErrUnexpectedEOF
+		}
+		switch b[1] {
+		case addrTCP, addrUDP:
+			// Laid out like a NetipAddr, with the network in place of the kind, then the port
+			remaining := b
+			var err error
+			if b[2] == netipZoned {
+				if remaining, err = skipFixed(b[1:], netipAddrSize); err != nil {
+					return b, err
+				}
+				if remaining, err = skipUvarintSized(remaining, ErrInvalidAddr); err != nil {
+					return b, err
+				}
+			} else if remaining, err = skipFixed(b, 3+int(b[2])); err != nil {
+				return b, err
+			}
+			if remaining, err = skipFixed(remaining, 2); err != nil {
+				return b, err
+			}
+			return remaining, nil
+		case addrUnix, addrUnixgram, addrUnixpacket:
+			remaining, err := skipUvarintSized(b[2:], ErrInvalidAddr)
+			if err != nil {
+				return b, err
+			}
+			return remaining, nil
+		}
+		return b, ErrInvalidAddr
+	case NetipPrefixRawKind:
+		if len(b) < 2 {
+			return b, io.ErrUnexpectedEOF
+		}
+		return skipFixed(b, 3+int(b[1]))
+	case HardwareAddrRawKind:
+		if len(b) < 2 {
+			return b, io.ErrUnexpectedEOF
+		}
+		return skipFixed(b, 2+int(b[1]))
+	case UnixMillisRawKind:
+		return skipVarint(b, VarIntLen64, ErrInvalidTime)
+	case TimeZoneRawKind:
+		// Seconds, nanoseconds and offset varints, followed by the zone name.
+		remaining := b[1:]
+		var err error
+		for i := 0; i < 3; i++ {
+			if remaining, _, err = skipUvarint(remaining, ErrInvalidTime); err != nil {
+				return b, err
+			}
+		}
+		if remaining, err = skipUvarintSized(remaining, ErrInvalidTime); err != nil {
+			return b, err
+		}
+		return remaining, nil
+	case BigFloatRawKind:
+		return skipVarSized(b, ErrInvalidBigFloat)
+	case RatRawKind:
+		// The sign, followed by the numerator and denominator unless it's nil
+		if len(b) < 2 {
+			return b, io.ErrUnexpectedEOF
+		}
+		if b[1] == ratNil {
+			return b[2:], nil
+		}
+		remaining, err := skipUvarintSized(b[2:], ErrInvalidRat)
+		if err != nil {
+			return b, err
+		}
+		if remaining, err = skipUvarintSized(remaining, ErrInvalidRat); err != nil {
+			return b, err
+		}
+		return remaining, nil
+	case FixedSliceRawKind:
+		if len(b) < 2 {
+			return b, io.ErrUnexpectedEOF
+		}
+		size := fixedKindSize(b[1])
+		if size == 0 {
+			return b, ErrInvalidFixedSlice
+		}
+		remaining, n, err := skipUvarint(b[2:], ErrInvalidFixedSlice)
+		if err != nil {
+			return b, err
+		}
+		if uint64(len(remaining))/uint64(size) < n {
+			return b, io.ErrUnexpectedEOF
+		}
+		return remaining[n*uint64(size):], nil
+	case LatLngRawKind:
+		return skipFixed(b, latLngSize)
+	case HistogramRawKind:
+		// A bound and a count as untagged varints for each bucket
+		remaining, size, err := skipUvarint(b[1:], ErrInvalidHistogram)
+		if err != nil {
+			return b, err
+		}
+		if size > uint64(len(remaining)/2) {
+			return b, io.ErrUnexpectedEOF
+		}
+		for i := uint64(0); i < 2*size; i++ {
+			if remaining, _, err = skipUvarint(remaining, ErrInvalidHistogram); err != nil {
+				return b, err
+			}
+		}
+		return remaining, nil
+	case StaticUint32RawKind:
+		return skipFixed(b, staticUint32Size)
+	case FlagByteRawKind:
+		return skipFixed(b, 2)
+	case FlagsRawKind:
+		if len(b) < 2 {
+			return b, io.ErrUnexpectedEOF
+		}
+		return skipFixed(b, 2+int(b[1]))
+	case ColorRawKind:
+		return skipFixed(b, colorSize)
+	case StringUTF16RawKind:
+		remaining, n, err := skipUvarint(b[1:], ErrInvalidString)
+		if err != nil {
+			return b, err
+		}
+		if uint64(len(remaining))/2 < n {
+			return b, io.ErrUnexpectedEOF
+		}
+		return remaining[2*n:], nil
+	case CheckedUint64RawKind:
+		remaining, _, err := skipUvarint(b[1:], ErrInvalidUint64)
+		if err != nil {
+			return b, err
+		}
+		return skipFixed(remaining, 1)
+	case FloatTextRawKind:
+		return skipVarSized(b, ErrInvalidFloatText)
+	case RegexpRawKind:
+		return skipVarSized(b, ErrInvalidRegexp)
+	case TimeRangeRawKind:
+		// Start seconds and nanoseconds, then the seconds delta and end nanoseconds.
+		remaining := b[1:]
+		var err error
+		for i := 0; i < 4; i++ {
+			if remaining, _, err = skipUvarint(remaining, ErrInvalidTimeRange); err != nil {
+				return b, err
+			}
+		}
+		return remaining, nil
+	case ScheduleRawKind:
+		// The interval, anchor seconds and nanoseconds, and count.
+		remaining := b[1:]
+		var err error
+		for i := 0; i < 4; i++ {
+			if remaining, _, err = skipUvarint(remaining, ErrInvalidSchedule); err != nil {
+				return b, err
+			}
+		}
+		return remaining, nil
+	case URLRawKind:
+		return skipVarSized(b, ErrInvalidURL)
+	case BytesVarRawKind:
+		return skipVarSized(b, ErrInvalidBytes)
+	case StringVarRawKind:
+		return skipVarSized(b, ErrInvalidString)
+	}
+	return b, ErrUnsupportedKind
+}
+
+func skipFixed(b []byte, size int) ([]byte, error) {
+	if len(b) < size {
+		return b, io.ErrUnexpectedEOF
+	}
+	return b[size:], nil
+}
+
+// skipVarint skips a tagged varint of at most max bytes.
+func skipVarint(b []byte, max int, invalid error) ([]byte, error) {
+	for i := 1; i <= max; i++ {
+		if i == len(b) {
+			return b, io.ErrUnexpectedEOF
+		}
+		if b[i] < continuation {
+			return b[i+1:], nil
+		}
+	}
+	return b, invalid
+}
+
+// skipLength reads the tagged Uint32 length used by slices, maps, bytes and strings.
+func skipLength(b []byte, invalid error) ([]byte, uint64, error) {
+	if len(b) == 0 {
+		return b, 0, io.ErrUnexpectedEOF
+	}
+	if b[0] != Uint32RawKind {
+		return b, 0, invalid
+	}
+	if _, err := skipVarint(b, VarIntLen32, invalid); err != nil {
+		return b, 0, err
+	}
+	remaining, size, err := decodeUint32(b)
+	if err != nil {
+		return b, 0, invalid
+	}
+	return remaining, uint64(size), nil
+}
+
+func skipSized(b []byte, header []byte, invalid error) ([]byte, error) {
+	remaining, size, err := skipLength(header, invalid)
+	if err != nil {
+		return b, err
+	}
+	if uint64(len(remaining)) < size {
+		return b, io.ErrUnexpectedEOF
+	}
+	return remaining[size:], nil
+}
+
+func skipVarSized(b []byte, invalid error) ([]byte, error) {
+	remaining, err := skipUvarintSized(b[1:], invalid)
+	if err != nil {
+		return b, err
+	}
+	return remaining, nil
+}
+
+// skipUvarint reads an untagged varint, returning io.ErrUnexpectedEOF if b ends before it does.
+func skipUvarint(b []byte, invalid error) ([]byte, uint64, error) {
+	remaining, value, ok := readUvarint(b)
+	if !ok {
+		// readUvarint only fails early when it runs out of bytes
+		if len(b) < VarIntLen64 {
+			return b, 0, io.ErrUnexpectedEOF
+		}
+		return b, 0, invalid
+	}
+	return remaining, value, nil
+}
+
+// skipUvarintSized skips an untagged varint length followed by that many bytes.
+func skipUvarintSized(b []byte, invalid error) ([]byte, error) {
+	remaining, size, err := skipUvarint(b, invalid)
+	if err != nil {
+		return b, err
+	}
+	if uint64(len(remaining)) < size {
+		return b, io.ErrUnexpectedEOF
+	}
+	return remaining[size:], nil
+}
+
+// MessageComplete reports whether b starts with a complete value, and if so its length in bytes,
+// without decoding it. It returns complete as false if b ends before the value does, so an event
+// loop can keep accumulating bytes until a message is whole, and an error if the value is invalid.
+func MessageComplete(b []byte) (n int, complete bool, err error) {
+	remaining, err := skipValue(b, 0)
+	switch {
+	case err == io.ErrUnexpectedEOF:
+		return 0, false, nil
+	case err != nil:
+		return 0, false, err
+	}
+	return len(b) - len(remaining), true, nil
+}
+
+// Skip advances the Decoder past the next value without decoding it.
+func (d *BufferDecoder) Skip() (err error) {
+	d.b, err = skipValue(d.b, 0)
+	err = d.step(err)
+	return
+}
+
+// Field returns a Decoder positioned at the value n values after the current one, with n
+// starting at 0, by skipping the values before it. d itself isn't advanced.
+func (d *BufferDecoder) Field(n int) (*BufferDecoder, error) {
+	c := d.Clone()
+	for i := 0; i < n; i++ {
+		if err := c.Skip(); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// RawMessage returns the encoded bytes of the next value, including its kind, and advances past
+// it without decoding it. The returned bytes alias the Decoder's buffer rather than being copied,
+// so they must be copied if they're needed after the buffer is reused.
+func (d *BufferDecoder) RawMessage() (value []byte, err error) {
+	start := d.b
+	d.b, err = skipValue(d.b, 0)
+	err = d.step(err)
+	if err != nil {
+		return nil, err
+	}
+	return start[: len(start)-len(d.b) : len(start)-len(d.b)], nil
+}
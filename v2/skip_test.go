@@ -0,0 +1,185 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"errors"
+	"io"
+	"math/big"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestSkip(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	e := Encoder(p)
+	e.Nil().Empty()
+	e.Slice(2, StringKind).String("1").String("2")
+	e.Map(1, StringKind, SliceKind).String("Test").Slice(1, Uint64Kind).Uint64(64)
+	e.Bytes([]byte("Test Bytes")).String("Test String").Error(errors.New("Test Error"))
+	e.Bool(true).Uint8(8).Uint16(16).Uint32(32).Uint64(64).Int32(-32).Int64(-64).Float32(32.32).Float64(64.64)
+	e.NetipAddr(netip.MustParseAddr("2001:db8::1")).NetipPrefix(netip.MustParsePrefix("10.0.0.0/8"))
+	e.BytesVar([]byte("Test Bytes")).StringVar("Test String")
+	e.Uint32(1)
+
+	d := Decoder(p.Bytes())
+	for i := 0; i < 20; i++ {
+		assert.NoError(t, d.Skip())
+	}
+	value, err := d.Uint32()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(1), value)
+
+	err = d.Skip()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	d = Decoder([]byte{0xFF})
+	err = d.Skip()
+	assert.ErrorIs(t, err, ErrUnsupportedKind)
+	assert.Equal(t, 1, d.Remaining())
+}
+
+func TestField(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).String("Test String").Slice(2, Uint32Kind).Uint32(1).Uint32(2).Bool(true).Int64(-64)
+
+	d := Decoder(p.Bytes())
+	f, err := d.Field(2)
+	assert.NoError(t, err)
+	b, err := f.Bool()
+	assert.NoError(t, err)
+	assert.True(t, b)
+	assert.Equal(t, len(p.Bytes()), d.Remaining())
+
+	f, err = d.Field(0)
+	assert.NoError(t, err)
+	s, err := f.String()
+	assert.NoError(t, err)
+	assert.Equal(t, "Test String", s)
+
+	f, err = f.Field(2)
+	assert.NoError(t, err)
+	i, err := f.Int64()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-64), i)
+
+	_, err = d.Field(5)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestRawMessage(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).String("route").Slice(2, AnyKind).String("Test String").Map(1, StringKind, Uint32Kind).String("a").Uint32(1).Bool(true)
+
+	d := Decoder(p.Bytes())
+	route, err := d.String()
+	assert.NoError(t, err)
+	assert.Equal(t, "route", route)
+
+	raw, err := d.RawMessage()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, d.Remaining())
+
+	forwarded := NewBuffer()
+	Encoder(forwarded).RawMessage(raw)
+
+	expected := NewBuffer()
+	Encoder(expected).Slice(2, AnyKind).String("Test String").Map(1, StringKind, Uint32Kind).String("a").Uint32(1)
+	assert.Equal(t, expected.Bytes(), forwarded.Bytes())
+
+	// Appending to the returned bytes doesn't overwrite the rest of the buffer
+	_ = append(raw, 0xFF)
+	b, err := d.Bool()
+	assert.NoError(t, err)
+	assert.True(t, b)
+
+	_, err = d.RawMessage()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func TestSkipTruncated(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).Map(1, StringKind, SliceKind).String("Test").Slice(2, Uint64Kind).Uint64(64).Uint64(1 << 40)
+
+	for i := 0; i < len(p.Bytes()); i++ {
+		_, err := skipValue((p.Bytes())[:i], 0)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	}
+	remaining, err := skipValue(p.Bytes(), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(remaining))
+}
+
+func TestMessageComplete(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	e := Encoder(p)
+	e.Slice(12, AnyKind).String("Test").Bytes([]byte("Test")).Error(errors.New("Test Error")).Uint64(1 << 40).Float64(64.64)
+	e.Map(1, StringKind, SliceKind).String("Test").Slice(1, Int32Kind).Int32(-32)
+	e.NetipAddr(netip.MustParseAddr("fe80::1%eth0")).StringVar("Test").Nil()
+	e.Rat(big.NewRat(-22, 7)).TimeRange(time.Unix(1, 2), time.Unix(3, 4))
+	packed, err := MarshalOptions{PackStructs: true}.Marshal([]struct{ X, Y int32 }{{1, 2}, {3, 4}})
+	assert.NoError(t, err)
+	p.Write(packed)
+	message := p.Bytes()
+
+	for i := 0; i < len(message); i++ {
+		n, complete, err := MessageComplete(message[:i])
+		assert.NoError(t, err)
+		assert.False(t, complete)
+		assert.Zero(t, n)
+	}
+
+	n, complete, err := MessageComplete(append(message, StringRawKind))
+	assert.NoError(t, err)
+	assert.True(t, complete)
+	assert.Equal(t, len(message), n)
+
+	_, complete, err = MessageComplete([]byte{0xFF})
+	assert.ErrorIs(t, err, ErrUnsupportedKind)
+	assert.False(t, complete)
+}
+
+func TestSkipInvalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := skipValue([]byte{Uint32RawKind, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x01}, 0)
+	assert.ErrorIs(t, err, ErrInvalidUint32)
+
+	_, err = skipValue([]byte{SliceRawKind, byte(StringKind), Uint64RawKind, 1}, 0)
+	assert.ErrorIs(t, err, ErrInvalidSlice)
+
+	p := NewBuffer()
+	for i := 0; i < maxSkipDepth+1; i++ {
+		encodeSlice(p, 1, SliceKind)
+	}
+	encodeSlice(p, 0, SliceKind)
+	_, err = skipValue(p.Bytes(), 0)
+	assert.ErrorIs(t, err, ErrInvalidSlice)
+}
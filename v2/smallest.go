@@ -0,0 +1,80 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"math"
+)
+
+// encodeSmallestInt writes value as a Uint8 if it fits in one, as an Int32 if it fits in one,
+// and as an Int64 otherwise. A Uint8 takes two bytes, and an Int32 takes the same number of bytes
+// as an Int64 with the same value, so this is as small as any of them can be.
+func encodeSmallestInt(b *Buffer, value int64) {
+	switch {
+	case value >= 0 && value <= math.MaxUint8:
+		encodeUint8(b, uint8(value))
+	case value >= math.MinInt32 && value <= math.MaxInt32:
+		encodeInt32(b, int32(value))
+	default:
+		encodeInt64(b, value)
+	}
+}
+
+// decodeSmallestInt decodes any of the integer kinds whose value fits in an int64, so that it
+// also reads values that were written with a fixed width.
+func decodeSmallestInt(b []byte) ([]byte, int64, error) {
+	if len(b) > 0 {
+		switch b[0] {
+		case Uint8RawKind:
+			remaining, value, err := decodeUint8(b)
+			return remaining, int64(value), err
+		case Uint16RawKind:
+			remaining, value, err := decodeUint16(b)
+			return remaining, int64(value), err
+		case Uint32RawKind:
+			remaining, value, err := decodeUint32(b)
+			return remaining, int64(value), err
+		case Uint64RawKind:
+			remaining, value, err := decodeUint64(b)
+			if err == nil && value > math.MaxInt64 {
+				return b, 0, ErrInvalidInt64
+			}
+			return remaining, int64(value), err
+		case Int32RawKind:
+			remaining, value, err := decodeInt32(b)
+			return remaining, int64(value), err
+		case Int64RawKind:
+			return decodeInt64(b)
+		}
+	}
+	return b, 0, ErrInvalidInt64
+}
+
+// SmallestInt encodes value as whichever of a Uint8, Int32 or Int64 is smallest for it,
+// so that the caller doesn't need to pick a width for a value that could be any size.
+func (e *BufferEncoder) SmallestInt(value int64) *BufferEncoder {
+	encodeSmallestInt((*Buffer)(e), value)
+	return e
+}
+
+// SmallestInt decodes a value encoded with SmallestInt, or any other integer kind
+// whose value fits in an int64, failing with ErrInvalidInt64 for any other kind.
+func (d *BufferDecoder) SmallestInt() (value int64, err error) {
+	d.b, value, err = decodeSmallestInt(d.b)
+	err = d.step(err)
+	return
+}
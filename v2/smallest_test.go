@@ -0,0 +1,89 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"math"
+	"testing"
+)
+
+func TestSmallestInt(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		value int64
+		kind  Kind
+		size  int
+	}{
+		{0, Uint8Kind, 2},
+		{1, Uint8Kind, 2},
+		{math.MaxUint8, Uint8Kind, 2},
+		{math.MaxUint8 + 1, Int32Kind, 3},
+		{-1, Int32Kind, 2},
+		{-64, Int32Kind, 2},
+		{math.MaxInt32, Int32Kind, 6},
+		{math.MinInt32, Int32Kind, 6},
+		{math.MaxInt32 + 1, Int64Kind, 6},
+		{math.MinInt32 - 1, Int64Kind, 6},
+		{math.MaxInt64, Int64Kind, 11},
+		{math.MinInt64, Int64Kind, 11},
+	}
+
+	p := NewBuffer()
+	for _, test := range tests {
+		p.Reset()
+		Encoder(p).SmallestInt(test.value)
+		assert.Equal(t, test.kind, Kind(p.Bytes()[0]), "%d", test.value)
+		assert.Equal(t, test.size, p.Len(), "%d", test.value)
+
+		d := Decoder(p.Bytes())
+		value, err := d.SmallestInt()
+		assert.NoError(t, err)
+		assert.Equal(t, test.value, value)
+		assert.Equal(t, 0, d.Remaining())
+
+		_, err = Decoder(p.Bytes()[:1]).SmallestInt()
+		assert.Error(t, err)
+	}
+
+	// Values written with a fixed width decode too
+	p.Reset()
+	Encoder(p).Uint16(65535).Uint32(math.MaxUint32).Uint64(math.MaxInt64).Int32(-5).Int64(7)
+	d := Decoder(p.Bytes())
+	for _, expected := range []int64{65535, math.MaxUint32, math.MaxInt64, -5, 7} {
+		value, err := d.SmallestInt()
+		assert.NoError(t, err)
+		assert.Equal(t, expected, value)
+	}
+
+	p.Reset()
+	Encoder(p).Uint64(math.MaxInt64 + 1)
+	d = Decoder(p.Bytes())
+	_, err := d.SmallestInt()
+	assert.ErrorIs(t, err, ErrInvalidInt64)
+	assert.Equal(t, p.Len(), d.Remaining())
+
+	_, err = Decoder(nil).SmallestInt()
+	assert.ErrorIs(t, err, ErrInvalidInt64)
+
+	p.Reset()
+	Encoder(p).String("1")
+	_, err = Decoder(p.Bytes()).SmallestInt()
+	assert.ErrorIs(t, err, ErrInvalidInt64)
+}
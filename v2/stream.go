@@ -0,0 +1,263 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"io"
+)
+
+const (
+	// streamReadSize is the minimum amount of space made available for each read from an io.Reader.
+	streamReadSize = 4096
+
+	// maxEmptyReads bounds the number of consecutive reads that return
+	// no data and no error before giving up with io.ErrNoProgress.
+	maxEmptyReads = 100
+)
+
+// DefaultMaxBuffered is the most input a StreamDecoder created with NewStreamDecoderReader buffers
+// by default while waiting for the rest of a value.
+const DefaultMaxBuffered = 16 << 20
+
+// StreamDecoder decodes values from input that arrives in chunks, such as reads from a network connection.
+//
+// If a value is split across chunks, its read method returns ErrNeedMoreData without consuming
+// anything, and the same call can be retried once the rest of the value has been passed to Feed.
+// A StreamDecoder created with NewStreamDecoderReader reads the rest of the value itself instead.
+type StreamDecoder struct {
+	// MaxBuffered is the most input read from the io.Reader is allowed to hold while waiting for the
+	// rest of a value, after which its read methods fail with ErrMessageTooLarge. It defaults to
+	// DefaultMaxBuffered, and zero means no limit. Input passed to Feed isn't limited.
+	MaxBuffered int
+
+	b      []byte
+	offset int
+	r      io.Reader
+}
+
+func NewStreamDecoder() *StreamDecoder {
+	return new(StreamDecoder)
+}
+
+// NewStreamDecoderReader returns a StreamDecoder that reads from r whenever the buffered input
+// doesn't hold a complete value, accumulating short reads until it does. Its read methods return
+// io.EOF if r ends between values, and io.ErrUnexpectedEOF if it ends partway through one.
+func NewStreamDecoderReader(r io.Reader) *StreamDecoder {
+	return &StreamDecoder{
+		MaxBuffered: DefaultMaxBuffered,
+		r:           r,
+	}
+}
+
+// Feed makes b available for decoding. The bytes are copied, so b can be reused once Feed returns.
+func (s *StreamDecoder) Feed(b []byte) {
+	s.compact()
+	s.b = append(s.b, b...)
+}
+
+// compact reclaims the space used by values that have already been decoded.
+func (s *StreamDecoder) compact() {
+	if s.offset > 0 {
+		s.b = s.b[:copy(s.b, s.b[s.offset:])]
+		s.offset = 0
+	}
+}
+
+// fill reads at least one more byte from the reader into the buffer, growing it as needed up to
+// MaxBuffered.
+func (s *StreamDecoder) fill() error {
+	s.compact()
+	if s.MaxBuffered > 0 && len(s.b) >= s.MaxBuffered {
+		return ErrMessageTooLarge
+	}
+	if cap(s.b)-len(s.b) < streamReadSize {
+		size := 2*cap(s.b) + streamReadSize
+		if s.MaxBuffered > 0 {
+			size = min(size, s.MaxBuffered)
+		}
+		b := make([]byte, len(s.b), size)
+		copy(b, s.b)
+		s.b = b
+	}
+	limit := cap(s.b)
+	if s.MaxBuffered > 0 {
+		limit = min(limit, s.MaxBuffered)
+	}
+	for i := 0; i < maxEmptyReads; i++ {
+		n, err := s.r.Read(s.b[len(s.b):limit])
+		s.b = s.b[:len(s.b)+n]
+		switch {
+		case n > 0:
+			return nil
+		case err == io.EOF:
+			if len(s.b) > 0 {
+				return io.ErrUnexpectedEOF
+			}
+			return io.EOF
+		case err != nil:
+			return err
+		}
+	}
+	return io.ErrNoProgress
+}
+
+// Buffered returns the number of bytes that have been fed but not yet decoded.
+func (s *StreamDecoder) Buffered() int {
+	return len(s.b) - s.offset
+}
+
+// next returns the buffered bytes if they start with a complete value. Malformed values are
+// left for the decode function to report, so that callers get the usual error for the kind.
+func (s *StreamDecoder) next() ([]byte, error) {
+	for {
+		b := s.b[s.offset:]
+		if _, err := skipValue(b, 0); err != io.ErrUnexpectedEOF {
+			return b, nil
+		}
+		if err := s.more(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// more returns ErrNeedMoreData, or if there's a reader, reads more input from it.
+func (s *StreamDecoder) more() error {
+	if s.r == nil {
+		return ErrNeedMoreData
+	}
+	return s.fill()
+}
+
+// nextHeader is like next, but only requires the header of a
+// slice or map, whose elements are read by subsequent calls.
+func (s *StreamDecoder) nextHeader(size int) ([]byte, error) {
+	for {
+		b := s.b[s.offset:]
+		if len(b) >= size {
+			if _, _, err := skipLength(b[size:], nil); err != io.ErrUnexpectedEOF {
+				return b, nil
+			}
+		}
+		if err := s.more(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (s *StreamDecoder) advance(remaining []byte) {
+	s.offset = len(s.b) - len(remaining)
+}
+
+func streamDecode[T any](s *StreamDecoder, decode func([]byte) ([]byte, T, error)) (value T, err error) {
+	var b []byte
+	if b, err = s.next(); err != nil {
+		return
+	}
+	b, value, err = decode(b)
+	s.advance(b)
+	return
+}
+
+func (s *StreamDecoder) Nil() (value bool, err error) {
+	var b []byte
+	if b, err = s.next(); err != nil {
+		return
+	}
+	b, value = decodeNil(b)
+	s.advance(b)
+	return
+}
+
+func (s *StreamDecoder) Map(keyKind, valueKind Kind) (size uint32, err error) {
+	var b []byte
+	if b, err = s.nextHeader(3); err != nil {
+		return
+	}
+	b, size, err = decodeMap(b, keyKind, valueKind)
+	s.advance(b)
+	return
+}
+
+func (s *StreamDecoder) Slice(kind Kind) (size uint32, err error) {
+	var b []byte
+	if b, err = s.nextHeader(2); err != nil {
+		return
+	}
+	b, size, err = decodeSlice(b, kind)
+	s.advance(b)
+	return
+}
+
+func (s *StreamDecoder) Bytes(b []byte) ([]byte, error) {
+	return streamDecode(s, func(buf []byte) ([]byte, []byte, error) {
+		return decodeBytes(buf, b)
+	})
+}
+
+func (s *StreamDecoder) String() (string, error) {
+	return streamDecode(s, decodeString)
+}
+
+func (s *StreamDecoder) Error() (error, error) {
+	return streamDecode(s, decodeError)
+}
+
+func (s *StreamDecoder) Bool() (bool, error) {
+	return streamDecode(s, decodeBool)
+}
+
+func (s *StreamDecoder) Uint8() (uint8, error) {
+	return streamDecode(s, decodeUint8)
+}
+
+func (s *StreamDecoder) Uint16() (uint16, error) {
+	return streamDecode(s, decodeUint16)
+}
+
+func (s *StreamDecoder) Uint32() (uint32, error) {
+	return streamDecode(s, decodeUint32)
+}
+
+func (s *StreamDecoder) Uint64() (uint64, error) {
+	return streamDecode(s, decodeUint64)
+}
+
+func (s *StreamDecoder) Int32() (int32, error) {
+	return streamDecode(s, decodeInt32)
+}
+
+func (s *StreamDecoder) Int64() (int64, error) {
+	return streamDecode(s, decodeInt64)
+}
+
+func (s *StreamDecoder) Float32() (float32, error) {
+	return streamDecode(s, decodeFloat32)
+}
+
+func (s *StreamDecoder) Float64() (float64, error) {
+	return streamDecode(s, decodeFloat64)
+}
+
+func (s *StreamDecoder) Skip() (err error) {
+	var b []byte
+	if b, err = s.next(); err != nil {
+		return
+	}
+	b, err = skipValue(b, 0)
+	s.advance(b)
+	return
+}
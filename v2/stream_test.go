@@ -0,0 +1,173 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestStreamDecoder(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).String("Test String").Slice(2, Uint64Kind).Uint64(1<<40).Uint64(64).Map(1, StringKind, BoolKind).String("Test").Bool(true).Bytes([]byte("Test Bytes")).Error(errors.New("Test Error")).Float64(64.64).Nil()
+
+	s := NewStreamDecoder()
+	var err error
+	var next int
+
+	// feed delivers the encoded bytes one at a time until fn stops asking for more.
+	feed := func(fn func() error) {
+		for err = fn(); errors.Is(err, ErrNeedMoreData); err = fn() {
+			if !assert.Less(t, next, len(p.Bytes())) {
+				return
+			}
+			s.Feed((p.Bytes())[next : next+1])
+			next++
+		}
+		assert.NoError(t, err)
+	}
+
+	var str string
+	feed(func() (err error) { str, err = s.String(); return })
+	assert.Equal(t, "Test String", str)
+
+	var size uint32
+	feed(func() (err error) { size, err = s.Slice(Uint64Kind); return })
+	assert.Equal(t, uint32(2), size)
+	assert.Equal(t, 0, s.Buffered())
+
+	var u64 uint64
+	feed(func() (err error) { u64, err = s.Uint64(); return })
+	assert.Equal(t, uint64(1<<40), u64)
+	feed(func() (err error) { u64, err = s.Uint64(); return })
+	assert.Equal(t, uint64(64), u64)
+
+	feed(func() (err error) { size, err = s.Map(StringKind, BoolKind); return })
+	assert.Equal(t, uint32(1), size)
+	feed(func() (err error) { str, err = s.String(); return })
+	assert.Equal(t, "Test", str)
+
+	var b bool
+	feed(func() (err error) { b, err = s.Bool(); return })
+	assert.True(t, b)
+
+	var bytes []byte
+	feed(func() (err error) { bytes, err = s.Bytes(nil); return })
+	assert.Equal(t, []byte("Test Bytes"), bytes)
+
+	var e error
+	feed(func() (err error) { e, err = s.Error(); return })
+	assert.ErrorIs(t, e, Error("Test Error"))
+
+	feed(s.Skip)
+
+	feed(func() (err error) { b, err = s.Nil(); return })
+	assert.True(t, b)
+	assert.Equal(t, len(p.Bytes()), next)
+	assert.Equal(t, 0, s.Buffered())
+
+	_, err = s.Uint32()
+	assert.ErrorIs(t, err, ErrNeedMoreData)
+}
+
+func TestStreamDecoderReader(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).String("Test String").Slice(2, Uint64Kind).Uint64(1 << 40).Uint64(64).Bytes(make([]byte, 3*streamReadSize)).Bool(true)
+
+	s := NewStreamDecoderReader(iotest.OneByteReader(bytes.NewReader(p.Bytes())))
+
+	str, err := s.String()
+	assert.NoError(t, err)
+	assert.Equal(t, "Test String", str)
+	size, err := s.Slice(Uint64Kind)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(2), size)
+	u64, err := s.Uint64()
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(1<<40), u64)
+	assert.NoError(t, s.Skip())
+	b, err := s.Bytes(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, make([]byte, 3*streamReadSize), b)
+	v, err := s.Bool()
+	assert.NoError(t, err)
+	assert.True(t, v)
+
+	_, err = s.Uint32()
+	assert.ErrorIs(t, err, io.EOF)
+
+	s = NewStreamDecoderReader(iotest.OneByteReader(bytes.NewReader(p.Bytes()[:5])))
+	_, err = s.String()
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	s = NewStreamDecoderReader(iotest.TimeoutReader(iotest.OneByteReader(bytes.NewReader(p.Bytes()))))
+	_, err = s.String()
+	assert.ErrorIs(t, err, iotest.ErrTimeout)
+
+	// A value larger than MaxBuffered isn't read into memory beyond it
+	p.Reset()
+	Encoder(p).Bytes(make([]byte, 3*streamReadSize)).Bool(true)
+	s = NewStreamDecoderReader(bytes.NewReader(p.Bytes()))
+	s.MaxBuffered = 2 * streamReadSize
+	_, err = s.Bytes(nil)
+	assert.ErrorIs(t, err, ErrMessageTooLarge)
+	assert.Equal(t, 2*streamReadSize, s.Buffered())
+
+	s = NewStreamDecoderReader(bytes.NewReader(p.Bytes()))
+	s.MaxBuffered = p.Len()
+	_, err = s.Bytes(nil)
+	assert.NoError(t, err)
+	v, err = s.Bool()
+	assert.NoError(t, err)
+	assert.True(t, v)
+}
+
+func TestStreamDecoderInvalid(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).String("Test String").Uint32(32)
+
+	s := NewStreamDecoder()
+	s.Feed(p.Bytes())
+
+	_, err := s.Uint32()
+	assert.ErrorIs(t, err, ErrInvalidUint32)
+	assert.Equal(t, len(p.Bytes()), s.Buffered())
+
+	str, err := s.String()
+	assert.NoError(t, err)
+	assert.Equal(t, "Test String", str)
+
+	s.Feed([]byte{Uint32RawKind})
+	v, err := s.Uint32()
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(32), v)
+	assert.Equal(t, 1, s.Buffered())
+
+	_, err = s.Uint32()
+	assert.ErrorIs(t, err, ErrNeedMoreData)
+}
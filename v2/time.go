@@ -0,0 +1,295 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"time"
+)
+
+// encodeTimeWithZone writes the Unix seconds, nanoseconds and zone offset in seconds as
+// untagged varints, followed by the zone name. The offset is authoritative, while the name
+// is best-effort: abbreviations like "EST" are ambiguous and aren't resolved back to a
+// location, so the decoded time uses a fixed zone rather than the original *time.Location.
+func encodeTimeWithZone(b *Buffer, value time.Time) {
+	name, offset := value.Zone()
+	b.Grow(1 + 3*VarIntLen64 + len(name))
+	b.b[b.offset] = TimeZoneRawKind
+	b.offset++
+	writeVarint(b, value.Unix())
+	writeUvarint(b, uint64(value.Nanosecond()))
+	writeVarint(b, int64(offset))
+	writeUvarint(b, uint64(len(name)))
+	b.offset += copy(b.b[b.offset:], name)
+}
+
+func decodeTimeWithZone(b []byte) ([]byte, time.Time, error) {
+	if len(b) > 4 && b[0] == TimeZoneRawKind {
+		remaining, sec, ok := readVarint(b[1:])
+		if !ok {
+			return b, time.Time{}, ErrInvalidTime
+		}
+		var nsec uint64
+		if remaining, nsec, ok = readUvarint(remaining); !ok || nsec >= uint64(time.Second) {
+			return b, time.Time{}, ErrInvalidTime
+		}
+		var offset int64
+		if remaining, offset, ok = readVarint(remaining); !ok || offset != int64(int32(offset)) {
+			return b, time.Time{}, ErrInvalidTime
+		}
+		var size uint64
+		if remaining, size, ok = readUvarint(remaining); !ok || size > uint64(len(remaining)) {
+			return b, time.Time{}, ErrInvalidTime
+		}
+		zone := time.FixedZone(string(remaining[:size]), int(offset))
+		return remaining[size:], time.Unix(sec, int64(nsec)).In(zone), nil
+	}
+	return b, time.Time{}, ErrInvalidTime
+}
+
+// TimeWithZone encodes value along with its zone, so that it's decoded
+// in a zone with the same offset and name rather than in UTC.
+func (e *BufferEncoder) TimeWithZone(value time.Time) *BufferEncoder {
+	encodeTimeWithZone((*Buffer)(e), value)
+	return e
+}
+
+func (d *BufferDecoder) TimeWithZone() (value time.Time, err error) {
+	d.b, value, err = decodeTimeWithZone(d.b)
+	err = d.step(err)
+	return
+}
+
+// encodeUnixMillis writes the number of milliseconds since the Unix epoch as an untagged varint,
+// which takes six bytes for current dates.
+func encodeUnixMillis(b *Buffer, value time.Time) {
+	b.Grow(1 + VarIntLen64)
+	b.b[b.offset] = UnixMillisRawKind
+	b.offset++
+	writeVarint(b, value.UnixMilli())
+}
+
+func decodeUnixMillis(b []byte) ([]byte, time.Time, error) {
+	if len(b) > 1 && b[0] == UnixMillisRawKind {
+		remaining, msec, ok := readVarint(b[1:])
+		if !ok {
+			return b, time.Time{}, ErrInvalidTime
+		}
+		return remaining, time.UnixMilli(msec).UTC(), nil
+	}
+	return b, time.Time{}, ErrInvalidTime
+}
+
+// UnixMillis encodes value as the number of milliseconds since the Unix epoch, which is how
+// JavaScript and many databases represent times. Anything below a millisecond is dropped,
+// rounding towards the past like time.Time.UnixMilli, and the zone isn't kept, so it's decoded
+// in UTC. Times too far from the epoch for their milliseconds to fit in an int64, about 292
+// million years either way, aren't supported.
+func (e *BufferEncoder) UnixMillis(value time.Time) *BufferEncoder {
+	encodeUnixMillis((*Buffer)(e), value)
+	return e
+}
+
+func (d *BufferDecoder) UnixMillis() (value time.Time, err error) {
+	d.b, value, err = decodeUnixMillis(d.b)
+	err = d.step(err)
+	return
+}
+
+// TimeRange is a start and end time, as encoded by BufferEncoder.TimeRange.
+type TimeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// encodeTimeRange writes start as Unix seconds and nanoseconds, then end as the difference in
+// seconds from start and its own nanoseconds, all as untagged varints. Short intervals between
+// whole seconds take a byte or two for the end, and nothing is lost for intervals too long to
+// fit in a time.Duration.
+func encodeTimeRange(b *Buffer, start, end time.Time) {
+	b.Grow(1 + 4*VarIntLen64)
+	b.b[b.offset] = TimeRangeRawKind
+	b.offset++
+	writeVarint(b, start.Unix())
+	writeUvarint(b, uint64(start.Nanosecond()))
+	writeVarint(b, end.Unix()-start.Unix())
+	writeUvarint(b, uint64(end.Nanosecond()))
+}
+
+func decodeTimeRange(b []byte) ([]byte, time.Time, time.Time, error) {
+	if len(b) > 4 && b[0] == TimeRangeRawKind {
+		remaining, sec, ok := readVarint(b[1:])
+		if !ok {
+			return b, time.Time{}, time.Time{}, ErrInvalidTimeRange
+		}
+		var nsec uint64
+		if remaining, nsec, ok = readUvarint(remaining); !ok || nsec >= uint64(time.Second) {
+			return b, time.Time{}, time.Time{}, ErrInvalidTimeRange
+		}
+		var delta int64
+		if remaining, delta, ok = readVarint(remaining); !ok {
+			return b, time.Time{}, time.Time{}, ErrInvalidTimeRange
+		}
+		var endNsec uint64
+		if remaining, endNsec, ok = readUvarint(remaining); !ok || endNsec >= uint64(time.Second) {
+			return b, time.Time{}, time.Time{}, ErrInvalidTimeRange
+		}
+		return remaining, time.Unix(sec, int64(nsec)).UTC(), time.Unix(sec+delta, int64(endNsec)).UTC(), nil
+	}
+	return b, time.Time{}, time.Time{}, ErrInvalidTimeRange
+}
+
+// TimeRange encodes the interval from start to end, with end stored relative to start.
+// The zones aren't kept, so both are decoded in UTC.
+func (e *BufferEncoder) TimeRange(start, end time.Time) *BufferEncoder {
+	encodeTimeRange((*Buffer)(e), start, end)
+	return e
+}
+
+// TimeRange decodes a range encoded by BufferEncoder.TimeRange. With
+// DecoderOptions.RequireOrderedTimeRanges set, it fails if end is before start.
+func (d *BufferDecoder) TimeRange() (start, end time.Time, err error) {
+	remaining, start, end, err := decodeTimeRange(d.b)
+	if err == nil && d.options.RequireOrderedTimeRanges && end.Before(start) {
+		return time.Time{}, time.Time{}, ErrInvalidTimeRange
+	}
+	d.b = remaining
+	err = d.step(err)
+	return
+}
+
+// Schedule is a periodic schedule, as encoded by BufferEncoder.Schedule, of Count occurrences
+// Interval apart starting at Anchor.
+type Schedule struct {
+	Interval time.Duration
+	Anchor   time.Time
+	Count    int
+}
+
+// encodeSchedule writes the interval in nanoseconds, the anchor as Unix seconds and nanoseconds,
+// and the count, all as untagged varints.
+func encodeSchedule(b *Buffer, value Schedule) {
+	b.Grow(1 + 4*VarIntLen64)
+	b.b[b.offset] = ScheduleRawKind
+	b.offset++
+	writeVarint(b, int64(value.Interval))
+	writeVarint(b, value.Anchor.Unix())
+	writeUvarint(b, uint64(value.Anchor.Nanosecond()))
+	writeVarint(b, int64(value.Count))
+}
+
+func decodeSchedule(b []byte) ([]byte, Schedule, error) {
+	if len(b) > 4 && b[0] == ScheduleRawKind {
+		remaining, interval, ok := readVarint(b[1:])
+		if !ok {
+			return b, Schedule{}, ErrInvalidSchedule
+		}
+		var sec int64
+		if remaining, sec, ok = readVarint(remaining); !ok {
+			return b, Schedule{}, ErrInvalidSchedule
+		}
+		var nsec uint64
+		if remaining, nsec, ok = readUvarint(remaining); !ok || nsec >= uint64(time.Second) {
+			return b, Schedule{}, ErrInvalidSchedule
+		}
+		var count int64
+		if remaining, count, ok = readVarint(remaining); !ok || count != int64(int(count)) {
+			return b, Schedule{}, ErrInvalidSchedule
+		}
+		return remaining, Schedule{
+			Interval: time.Duration(interval),
+			Anchor:   time.Unix(sec, int64(nsec)).UTC(),
+			Count:    int(count),
+		}, nil
+	}
+	return b, Schedule{}, ErrInvalidSchedule
+}
+
+// Schedule encodes value as a single value rather than as a separate duration, time and count,
+// so that it's recognised as a schedule by Any and in a trace. The anchor's zone isn't kept, so
+// it's decoded in UTC.
+func (e *BufferEncoder) Schedule(value Schedule) *BufferEncoder {
+	encodeSchedule((*Buffer)(e), value)
+	return e
+}
+
+func (d *BufferDecoder) Schedule() (value Schedule, err error) {
+	d.b, value, err = decodeSchedule(d.b)
+	err = d.step(err)
+	return
+}
+
+// invalidCalendarValue is encoded for out of range months and weekdays, rather than
+// truncating them to a byte that could decode as a different, valid value.
+const invalidCalendarValue = 0xFF
+
+func encodeMonth(b *Buffer, value time.Month) {
+	if value < time.January || value > time.December {
+		encodeUint8(b, invalidCalendarValue)
+		return
+	}
+	encodeUint8(b, uint8(value))
+}
+
+func decodeMonth(b []byte) ([]byte, time.Month, error) {
+	remaining, value, err := decodeUint8(b)
+	if err != nil || value < uint8(time.January) || value > uint8(time.December) {
+		return b, 0, ErrInvalidMonth
+	}
+	return remaining, time.Month(value), nil
+}
+
+func encodeWeekday(b *Buffer, value time.Weekday) {
+	if value < time.Sunday || value > time.Saturday {
+		encodeUint8(b, invalidCalendarValue)
+		return
+	}
+	encodeUint8(b, uint8(value))
+}
+
+func decodeWeekday(b []byte) ([]byte, time.Weekday, error) {
+	remaining, value, err := decodeUint8(b)
+	if err != nil || value > uint8(time.Saturday) {
+		return b, 0, ErrInvalidWeekday
+	}
+	return remaining, time.Weekday(value), nil
+}
+
+// Month encodes value as a Uint8. Months outside January to December
+// are encoded so that they fail to decode.
+func (e *BufferEncoder) Month(value time.Month) *BufferEncoder {
+	encodeMonth((*Buffer)(e), value)
+	return e
+}
+
+func (d *BufferDecoder) Month() (value time.Month, err error) {
+	d.b, value, err = decodeMonth(d.b)
+	err = d.step(err)
+	return
+}
+
+// Weekday encodes value as a Uint8. Weekdays outside Sunday to Saturday
+// are encoded so that they fail to decode.
+func (e *BufferEncoder) Weekday(value time.Weekday) *BufferEncoder {
+	encodeWeekday((*Buffer)(e), value)
+	return e
+}
+
+func (d *BufferDecoder) Weekday() (value time.Weekday, err error) {
+	d.b, value, err = decodeWeekday(d.b)
+	err = d.step(err)
+	return
+}
@@ -0,0 +1,316 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"math"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestTimeWithZone(t *testing.T) {
+	t.Parallel()
+
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	assert.NoError(t, err)
+
+	times := []time.Time{
+		time.Unix(0, 0).UTC(),
+		time.Date(1969, 7, 20, 20, 17, 40, 123456789, time.UTC),
+		time.Date(2024, 6, 1, 12, 0, 0, 0, kolkata),
+		// Either side of the spring forward and fall back transitions.
+		time.Date(2024, 3, 10, 1, 59, 59, 999999999, newYork),
+		time.Date(2024, 3, 10, 3, 0, 0, 0, newYork),
+		time.Date(2024, 11, 3, 1, 30, 0, 0, newYork),
+		time.Date(2024, 11, 3, 1, 30, 0, 0, newYork).Add(time.Hour),
+		time.Date(2024, 11, 3, 2, 30, 0, 0, newYork),
+	}
+
+	p := NewBuffer()
+	e := Encoder(p)
+	for _, tm := range times {
+		e.TimeWithZone(tm)
+	}
+
+	d := Decoder(p.Bytes())
+	for _, tm := range times {
+		value, err := d.TimeWithZone()
+		assert.NoError(t, err)
+		assert.True(t, tm.Equal(value))
+		assert.Equal(t, tm.Format(time.RFC3339Nano+" MST"), value.Format(time.RFC3339Nano+" MST"))
+
+		name, offset := tm.Zone()
+		valueName, valueOffset := value.Zone()
+		assert.Equal(t, name, valueName)
+		assert.Equal(t, offset, valueOffset)
+	}
+	assert.Equal(t, 0, d.Remaining())
+
+	d = Decoder(p.Bytes())
+	assert.NoError(t, d.Skip())
+	assert.NoError(t, d.Skip())
+}
+
+func TestTimeWithZoneInvalid(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	encodeTimeWithZone(p, time.Date(2024, 3, 10, 3, 0, 0, 0, time.FixedZone("EDT", -4*60*60)))
+
+	for i := 0; i < len(p.Bytes()); i++ {
+		_, _, err := decodeTimeWithZone((p.Bytes())[:i])
+		assert.ErrorIs(t, err, ErrInvalidTime)
+	}
+
+	_, _, err := decodeTimeWithZone(append([]byte{TimeZoneRawKind, 0}, 0x80, 0x94, 0xeb, 0xdc, 0x03, 0, 0))
+	assert.ErrorIs(t, err, ErrInvalidTime)
+}
+
+func TestUnixMillis(t *testing.T) {
+	t.Parallel()
+
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+
+	times := []time.Time{
+		time.Unix(0, 0).UTC(),
+		time.UnixMilli(-1).UTC(),
+		time.UnixMilli(1).UTC(),
+		time.Date(2024, 3, 10, 3, 0, 0, 0, newYork),
+		time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(9999, 12, 31, 23, 59, 59, 999000000, time.UTC),
+		time.UnixMilli(math.MaxInt64).UTC(),
+		time.UnixMilli(math.MinInt64).UTC(),
+	}
+
+	p := NewBuffer()
+	e := Encoder(p)
+	for _, tm := range times {
+		e.UnixMillis(tm)
+	}
+
+	d := Decoder(p.Bytes())
+	for _, tm := range times {
+		value, err := d.UnixMillis()
+		assert.NoError(t, err)
+		assert.True(t, tm.Equal(value))
+		assert.Equal(t, time.UTC, value.Location())
+	}
+	assert.Equal(t, 0, d.Remaining())
+
+	// Anything below a millisecond is truncated, towards the past before the epoch too
+	p.Reset()
+	Encoder(p).UnixMillis(time.Unix(0, 1999999)).UnixMillis(time.Unix(0, -1))
+	d = Decoder(p.Bytes())
+	value, err := d.UnixMillis()
+	assert.NoError(t, err)
+	assert.Equal(t, time.UnixMilli(1).UTC(), value)
+	value, err = d.UnixMillis()
+	assert.NoError(t, err)
+	assert.Equal(t, time.UnixMilli(-1).UTC(), value)
+
+	p.Reset()
+	Encoder(p).UnixMillis(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC))
+	assert.Equal(t, 7, p.Len())
+	anyValue, err := Decoder(p.Bytes()).Any()
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC), anyValue)
+
+	d = Decoder(p.Bytes())
+	assert.NoError(t, d.Skip())
+	assert.Equal(t, 0, d.Remaining())
+
+	for i := 0; i < p.Len(); i++ {
+		_, err = Decoder(p.Bytes()[:i]).UnixMillis()
+		assert.ErrorIs(t, err, ErrInvalidTime)
+	}
+	_, err = Decoder([]byte{UnixMillisRawKind, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x01}).UnixMillis()
+	assert.ErrorIs(t, err, ErrInvalidTime)
+}
+
+func TestTimeRange(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 6, 1, 9, 0, 0, 0, time.UTC)
+	ranges := []TimeRange{
+		{start, start.Add(30 * time.Minute)},
+		{start, start},
+		{start.Add(time.Hour), start},
+		{time.Date(1969, 7, 20, 20, 17, 40, 123456789, time.UTC), time.Date(2400, 1, 1, 0, 0, 0, 1, time.UTC)},
+	}
+
+	p := NewBuffer()
+	e := Encoder(p)
+	for _, r := range ranges {
+		e.TimeRange(r.Start, r.End)
+	}
+
+	// Kind, start seconds and nanoseconds, then just three bytes for a 30 minute end.
+	single := NewBuffer()
+	Encoder(single).TimeRange(ranges[0].Start, ranges[0].End)
+	assert.Equal(t, 1+5+1+2+1, single.Len())
+
+	d := Decoder(p.Bytes())
+	for _, r := range ranges {
+		s, end, err := d.TimeRange()
+		assert.NoError(t, err)
+		assert.Equal(t, r.Start, s)
+		assert.Equal(t, r.End, end)
+	}
+	assert.Equal(t, 0, d.Remaining())
+
+	d = Decoder(p.Bytes())
+	value, err := d.Any()
+	assert.NoError(t, err)
+	assert.Equal(t, ranges[0], value)
+	for range ranges[1:] {
+		assert.NoError(t, d.Skip())
+	}
+	assert.Equal(t, 0, d.Remaining())
+
+	d = DecoderWithOptions(p.Bytes(), DecoderOptions{RequireOrderedTimeRanges: true})
+	_, _, err = d.TimeRange()
+	assert.NoError(t, err)
+	_, _, err = d.TimeRange()
+	assert.NoError(t, err)
+	_, _, err = d.TimeRange()
+	assert.ErrorIs(t, err, ErrInvalidTimeRange)
+
+	p.Reset()
+	encodeTimeRange(p, start, start.Add(time.Second))
+	for i := 0; i < len(p.Bytes()); i++ {
+		_, _, _, err = decodeTimeRange((p.Bytes())[:i])
+		assert.ErrorIs(t, err, ErrInvalidTimeRange)
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	t.Parallel()
+
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err)
+
+	schedules := []Schedule{
+		// Every 15 minutes over a trading day
+		{Interval: 15 * time.Minute, Anchor: time.Date(2024, 6, 3, 9, 30, 0, 0, newYork), Count: 27},
+		{Interval: 24 * time.Hour, Anchor: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Count: -1},
+		{Interval: 1500 * time.Microsecond, Anchor: time.Unix(-1, 999999999), Count: 1 << 40},
+		{Interval: -time.Second, Anchor: time.Unix(0, 0), Count: 0},
+		{},
+	}
+
+	p := NewBuffer()
+	for _, schedule := range schedules {
+		Encoder(p).Schedule(schedule)
+	}
+
+	d := Decoder(p.Bytes())
+	for _, schedule := range schedules {
+		value, err := d.Schedule()
+		assert.NoError(t, err)
+		assert.Equal(t, schedule.Interval, value.Interval)
+		assert.Equal(t, schedule.Count, value.Count)
+		assert.True(t, schedule.Anchor.Equal(value.Anchor))
+		assert.Equal(t, time.UTC, value.Anchor.Location())
+	}
+	assert.Equal(t, 0, d.Remaining())
+
+	p.Reset()
+	Encoder(p).Schedule(schedules[0])
+	value, err := Decoder(p.Bytes()).Any()
+	assert.NoError(t, err)
+	assert.Equal(t, Schedule{Interval: 15 * time.Minute, Anchor: time.Date(2024, 6, 3, 13, 30, 0, 0, time.UTC), Count: 27}, value)
+	q := NewBuffer()
+	assert.NoError(t, EncodeAny(Encoder(q), value))
+	assert.Equal(t, p.Bytes(), q.Bytes())
+
+	n, complete, err := MessageComplete(p.Bytes())
+	assert.NoError(t, err)
+	assert.True(t, complete)
+	assert.Equal(t, p.Len(), n)
+	for i := 0; i < p.Len(); i++ {
+		_, err = Decoder(p.Bytes()[:i]).Schedule()
+		assert.ErrorIs(t, err, ErrInvalidSchedule)
+		if i > 0 {
+			_, complete, err = MessageComplete(p.Bytes()[:i])
+			assert.NoError(t, err)
+			assert.False(t, complete)
+		}
+	}
+
+	_, err = Decoder([]byte{ScheduleRawKind, 0, 0, 0x80, 0x94, 0xeb, 0xdc, 0x03, 0}).Schedule()
+	assert.ErrorIs(t, err, ErrInvalidSchedule)
+}
+
+func TestMonthWeekday(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	e := Encoder(p)
+	for m := time.January; m <= time.December; m++ {
+		e.Month(m)
+	}
+	for w := time.Sunday; w <= time.Saturday; w++ {
+		e.Weekday(w)
+	}
+	assert.Equal(t, 19*2, p.Len())
+
+	d := Decoder(p.Bytes())
+	for m := time.January; m <= time.December; m++ {
+		value, err := d.Month()
+		assert.NoError(t, err)
+		assert.Equal(t, m, value)
+	}
+	for w := time.Sunday; w <= time.Saturday; w++ {
+		value, err := d.Weekday()
+		assert.NoError(t, err)
+		assert.Equal(t, w, value)
+	}
+	assert.Equal(t, 0, d.Remaining())
+
+	for _, m := range []time.Month{0, 13, 257} {
+		p.Reset()
+		Encoder(p).Month(m)
+		_, err := Decoder(p.Bytes()).Month()
+		assert.ErrorIs(t, err, ErrInvalidMonth)
+	}
+	for _, w := range []time.Weekday{-1, 7, 256} {
+		p.Reset()
+		Encoder(p).Weekday(w)
+		_, err := Decoder(p.Bytes()).Weekday()
+		assert.ErrorIs(t, err, ErrInvalidWeekday)
+	}
+
+	p.Reset()
+	Encoder(p).Uint8(7)
+	_, err := Decoder(p.Bytes()).Weekday()
+	assert.ErrorIs(t, err, ErrInvalidWeekday)
+	value, err := Decoder(p.Bytes()).Month()
+	assert.NoError(t, err)
+	assert.Equal(t, time.July, value)
+
+	p.Reset()
+	Encoder(p).Uint16(1)
+	_, err = Decoder(p.Bytes()).Month()
+	assert.ErrorIs(t, err, ErrInvalidMonth)
+}
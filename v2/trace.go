@@ -0,0 +1,74 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"fmt"
+)
+
+// TraceEvent describes a single read made by a Decoder created with a Trace option.
+// Diffing the traces of two implementations decoding the same buffer shows exactly
+// where they first disagree.
+type TraceEvent struct {
+	// Offset is the position of the read in the buffer the Decoder was created with.
+	Offset int
+
+	// Kind is the kind byte found at Offset, or NilKind if the buffer was exhausted.
+	Kind Kind
+
+	// Raw holds the bytes consumed by the read, and aliases the Decoder's buffer.
+	Raw []byte
+
+	// Err is the error returned by the read, in which case Raw is empty.
+	Err error
+}
+
+// Value decodes Raw into a value with ReadTyped. Slice and Map headers can't be
+// decoded on their own, so for these Value returns ErrContainerKind.
+func (e TraceEvent) Value() (any, error) {
+	_, _, value, err := decodeTyped(e.Raw)
+	return value, err
+}
+
+func (e TraceEvent) String() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%d: kind %d: %v", e.Offset, e.Kind, e.Err)
+	}
+	value, err := e.Value()
+	if err != nil {
+		return fmt.Sprintf("%d: kind %d, %d bytes", e.Offset, e.Kind, len(e.Raw))
+	}
+	return fmt.Sprintf("%d: kind %d, %d bytes: %v", e.Offset, e.Kind, len(e.Raw), value)
+}
+
+func (d *BufferDecoder) trace(err error) {
+	consumed := d.total - len(d.b)
+	if consumed == d.traced && err == nil {
+		// A Nil or Empty check that didn't match
+		return
+	}
+	event := TraceEvent{
+		Offset: d.traced,
+		Raw:    d.origin[d.traced:consumed],
+		Err:    err,
+	}
+	if d.traced < len(d.origin) {
+		event.Kind = Kind(d.origin[d.traced])
+	}
+	d.traced = consumed
+	d.options.Trace(event)
+}
@@ -0,0 +1,77 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"testing"
+)
+
+func TestTrace(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	Encoder(p).Slice(2, StringKind).String("a").String("bc").Uint32(32).Nil()
+
+	var events []TraceEvent
+	d := DecoderWithOptions(p.Bytes(), DecoderOptions{
+		Trace: func(event TraceEvent) {
+			events = append(events, event)
+		},
+	})
+
+	assert.False(t, d.Nil())
+	size, err := d.Slice(StringKind)
+	assert.NoError(t, err)
+	for i := uint32(0); i < size; i++ {
+		_, err = d.String()
+		assert.NoError(t, err)
+	}
+	_, err = d.String()
+	assert.ErrorIs(t, err, ErrInvalidString)
+	_, err = d.Uint32()
+	assert.NoError(t, err)
+	assert.True(t, d.Nil())
+	_, err = d.Uint32()
+	assert.ErrorIs(t, err, ErrInvalidUint32)
+
+	assert.Len(t, events, 7)
+
+	assert.Equal(t, 0, events[0].Offset)
+	assert.Equal(t, SliceKind, events[0].Kind)
+	_, err = events[0].Value()
+	assert.ErrorIs(t, err, ErrContainerKind)
+
+	assert.Equal(t, StringKind, events[1].Kind)
+	assert.Equal(t, events[0].Offset+len(events[0].Raw), events[1].Offset)
+	value, err := events[2].Value()
+	assert.NoError(t, err)
+	assert.Equal(t, "bc", value)
+
+	assert.Equal(t, Uint32Kind, events[3].Kind)
+	assert.ErrorIs(t, events[3].Err, ErrInvalidString)
+	assert.Empty(t, events[3].Raw)
+	assert.Equal(t, events[3].Offset, events[4].Offset)
+	assert.Equal(t, "13: kind 10, 2 bytes: 32", events[4].String())
+
+	assert.Equal(t, NilKind, events[5].Kind)
+	assert.Len(t, events[5].Raw, 1)
+
+	assert.Equal(t, len(p.Bytes()), events[6].Offset)
+	assert.Equal(t, "16: kind 0: invalid uint32 encoding", events[6].String())
+}
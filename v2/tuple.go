@@ -0,0 +1,121 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+// Scalar is the set of types that can be encoded as elements of a tuple.
+type Scalar interface {
+	bool | uint8 | uint16 | uint32 | uint64 | int32 | int64 | float32 | float64 | string | []byte
+}
+
+func encodeScalar[T Scalar](b *Buffer, value T) {
+	switch v := any(value).(type) {
+	case bool:
+		encodeBool(b, v)
+	case uint8:
+		encodeUint8(b, v)
+	case uint16:
+		encodeUint16(b, v)
+	case uint32:
+		encodeUint32(b, v)
+	case uint64:
+		encodeUint64(b, v)
+	case int32:
+		encodeInt32(b, v)
+	case int64:
+		encodeInt64(b, v)
+	case float32:
+		encodeFloat32(b, v)
+	case float64:
+		encodeFloat64(b, v)
+	case string:
+		encodeString(b, v)
+	case []byte:
+		encodeBytes(b, v)
+	}
+}
+
+func decodeScalar[T Scalar](d *BufferDecoder) (value T, err error) {
+	switch v := any(&value).(type) {
+	case *bool:
+		*v, err = d.Bool()
+	case *uint8:
+		*v, err = d.Uint8()
+	case *uint16:
+		*v, err = d.Uint16()
+	case *uint32:
+		*v, err = d.Uint32()
+	case *uint64:
+		*v, err = d.Uint64()
+	case *int32:
+		*v, err = d.Int32()
+	case *int64:
+		*v, err = d.Int64()
+	case *float32:
+		*v, err = d.Float32()
+	case *float64:
+		*v, err = d.Float64()
+	case *string:
+		*v, err = d.String()
+	case *[]byte:
+		*v, err = d.Bytes(nil)
+	}
+	return
+}
+
+// EncodeTuple2 encodes a pair of values one after the other, without the header a Slice
+// would need, since the number of elements and their kinds are fixed by the type parameters.
+func EncodeTuple2[A, B Scalar](e *BufferEncoder, a A, b B) *BufferEncoder {
+	encodeScalar((*Buffer)(e), a)
+	encodeScalar((*Buffer)(e), b)
+	return e
+}
+
+func DecodeTuple2[A, B Scalar](d *BufferDecoder) (a A, b B, err error) {
+	if a, err = decodeScalar[A](d); err != nil {
+		return
+	}
+	b, err = decodeScalar[B](d)
+	return
+}
+
+func EncodeTuple3[A, B, C Scalar](e *BufferEncoder, a A, b B, c C) *BufferEncoder {
+	EncodeTuple2(e, a, b)
+	encodeScalar((*Buffer)(e), c)
+	return e
+}
+
+func DecodeTuple3[A, B, C Scalar](d *BufferDecoder) (a A, b B, c C, err error) {
+	if a, b, err = DecodeTuple2[A, B](d); err != nil {
+		return
+	}
+	c, err = decodeScalar[C](d)
+	return
+}
+
+func EncodeTuple4[A, B, C, D Scalar](e *BufferEncoder, a A, b B, c C, d D) *BufferEncoder {
+	EncodeTuple3(e, a, b, c)
+	encodeScalar((*Buffer)(e), d)
+	return e
+}
+
+func DecodeTuple4[A, B, C, D Scalar](d *BufferDecoder) (a A, b B, c C, e D, err error) {
+	if a, b, c, err = DecodeTuple3[A, B, C](d); err != nil {
+		return
+	}
+	e, err = decodeScalar[D](d)
+	return
+}
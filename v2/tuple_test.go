@@ -0,0 +1,70 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"testing"
+)
+
+func TestTuple(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	e := Encoder(p)
+	EncodeTuple2(e, "Test String", uint32(32))
+	EncodeTuple3(e, "Test String", uint32(32), true)
+	EncodeTuple4(e, []byte("Test Bytes"), int64(-64), float32(32.32), uint8(8))
+	EncodeTuple4(e, uint16(16), int32(-32), float64(64.64), uint64(64))
+
+	expected := NewBuffer()
+	Encoder(expected).String("Test String").Uint32(32).String("Test String").Uint32(32).Bool(true).
+		Bytes([]byte("Test Bytes")).Int64(-64).Float32(32.32).Uint8(8).Uint16(16).Int32(-32).Float64(64.64).Uint64(64)
+	assert.Equal(t, expected.Bytes(), p.Bytes())
+
+	d := Decoder(p.Bytes())
+	s, u, err := DecodeTuple2[string, uint32](d)
+	assert.NoError(t, err)
+	assert.Equal(t, "Test String", s)
+	assert.Equal(t, uint32(32), u)
+
+	s, u, b, err := DecodeTuple3[string, uint32, bool](d)
+	assert.NoError(t, err)
+	assert.Equal(t, "Test String", s)
+	assert.Equal(t, uint32(32), u)
+	assert.True(t, b)
+
+	bs, i64, f32, u8, err := DecodeTuple4[[]byte, int64, float32, uint8](d)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("Test Bytes"), bs)
+	assert.Equal(t, int64(-64), i64)
+	assert.Equal(t, float32(32.32), f32)
+	assert.Equal(t, uint8(8), u8)
+
+	u16, i32, f64, u64, err := DecodeTuple4[uint16, int32, float64, uint64](d)
+	assert.NoError(t, err)
+	assert.Equal(t, uint16(16), u16)
+	assert.Equal(t, int32(-32), i32)
+	assert.Equal(t, 64.64, f64)
+	assert.Equal(t, uint64(64), u64)
+	assert.Equal(t, 0, d.Remaining())
+
+	d = Decoder(p.Bytes())
+	_, _, err = DecodeTuple2[string, bool](d)
+	assert.ErrorIs(t, err, ErrInvalidBool)
+}
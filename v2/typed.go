@@ -0,0 +1,75 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+var (
+	typesMu sync.RWMutex
+	types   = make(map[uint32]func() any)
+)
+
+// RegisterType associates id with factory, which must return a new pointer to decode a value
+// written by BufferEncoder.Typed with that id into. Registering an id again replaces its factory.
+func RegisterType(id uint32, factory func() any) {
+	typesMu.Lock()
+	types[id] = factory
+	typesMu.Unlock()
+}
+
+// Typed encodes id as a Uint32 followed by v, so that it can be decoded into the type
+// registered for id with BufferDecoder.Typed. Values implementing PolyglotMarshaler
+// encode themselves, and anything else is encoded as it would be by Marshal.
+func (e *BufferEncoder) Typed(id uint32, v any) error {
+	b := (*Buffer)(e)
+	encodeUint32(b, id)
+	if m, ok := v.(PolyglotMarshaler); ok {
+		m.Encode(b)
+		return nil
+	}
+	return MarshalOptions{}.encode(b, reflect.ValueOf(v), 0)
+}
+
+// Typed decodes a value written by BufferEncoder.Typed into a new value from the factory
+// registered for its id, and returns it. An unregistered id fails with ErrUnknownTypeID,
+// leaving the Decoder at the value so that it can be skipped with Skip.
+func (d *BufferDecoder) Typed() (any, error) {
+	id, err := d.Uint32()
+	if err != nil {
+		return nil, err
+	}
+	typesMu.RLock()
+	factory, ok := types[id]
+	typesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnknownTypeID, id)
+	}
+	v := factory()
+	if u, ok := v.(PolyglotUnmarshaler); ok {
+		err = u.DecodeFrom(d)
+	} else {
+		err = MarshalOptions{}.Decode(d, v)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
@@ -0,0 +1,77 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"testing"
+)
+
+type typedPoint struct {
+	X int64
+	Y int64
+}
+
+func TestTyped(t *testing.T) {
+	t.Parallel()
+
+	RegisterType(1001, func() any { return new(testMessage) })
+	RegisterType(1002, func() any { return new(typedPoint) })
+
+	values := []any{
+		&testMessage{Name: "first", Value: 1, Tags: []string{"a"}},
+		&typedPoint{X: -1, Y: 2},
+		&testMessage{Name: "second", Value: 2, Tags: []string{}},
+	}
+	ids := []uint32{1001, 1002, 1001}
+
+	p := NewBuffer()
+	e := Encoder(p).Slice(uint32(len(values)), AnyKind)
+	for i, v := range values {
+		assert.NoError(t, e.Typed(ids[i], v))
+	}
+
+	d := Decoder(p.Bytes())
+	size, err := d.Slice(AnyKind)
+	assert.NoError(t, err)
+	decoded := make([]any, size)
+	for i := range decoded {
+		decoded[i], err = d.Typed()
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, values, decoded)
+	assert.Equal(t, 0, d.Remaining())
+
+	p.Reset()
+	assert.NoError(t, Encoder(p).Typed(1999, &typedPoint{X: 1}))
+	Encoder(p).String("next")
+	d = Decoder(p.Bytes())
+	_, err = d.Typed()
+	assert.ErrorIs(t, err, ErrUnknownTypeID)
+	assert.NoError(t, d.Skip())
+	next, err := d.String()
+	assert.NoError(t, err)
+	assert.Equal(t, "next", next)
+
+	p.Reset()
+	Encoder(p).Uint32(1002).String("not a point")
+	_, err = Decoder(p.Bytes()).Typed()
+	assert.Error(t, err)
+
+	assert.ErrorIs(t, Encoder(p).Typed(1002, make(chan int)), ErrUnsupportedType)
+}
@@ -0,0 +1,40 @@
+//go:build go1.23
+
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"unique"
+)
+
+// EncodeHandle encodes the value interned by h, with encode writing it.
+func EncodeHandle[T comparable](e *BufferEncoder, h unique.Handle[T], encode func(*BufferEncoder, T)) *BufferEncoder {
+	encode(e, h.Value())
+	return e
+}
+
+// DecodeHandle decodes a value with decode and interns it with unique.Make, so equal values
+// decoded from different buffers (or interned locally) share the same Handle.
+func DecodeHandle[T comparable](d *BufferDecoder, decode func(*BufferDecoder) (T, error)) (unique.Handle[T], error) {
+	v, err := decode(d)
+	if err != nil {
+		var h unique.Handle[T]
+		return h, err
+	}
+	return unique.Make(v), nil
+}
@@ -0,0 +1,63 @@
+//go:build go1.23
+
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"testing"
+	"unique"
+)
+
+func TestHandle(t *testing.T) {
+	t.Parallel()
+
+	type key struct {
+		Name string
+		ID   uint32
+	}
+	encode := func(e *BufferEncoder, k key) { e.String(k.Name).Uint32(k.ID) }
+	decode := func(d *BufferDecoder) (k key, err error) {
+		if k.Name, err = d.String(); err != nil {
+			return
+		}
+		k.ID, err = d.Uint32()
+		return
+	}
+
+	h := unique.Make(key{Name: "polyglot", ID: 32})
+	p := NewBuffer()
+	EncodeHandle(Encoder(p), h, encode)
+
+	expected := NewBuffer()
+	Encoder(expected).String("polyglot").Uint32(32)
+	assert.Equal(t, expected.Bytes(), p.Bytes())
+
+	decoded, err := DecodeHandle(Decoder(p.Bytes()), decode)
+	assert.NoError(t, err)
+	assert.True(t, h == decoded)
+	assert.Equal(t, h.Value(), decoded.Value())
+
+	other, err := DecodeHandle(Decoder(p.Bytes()), decode)
+	assert.NoError(t, err)
+	assert.True(t, decoded == other)
+
+	_, err = DecodeHandle(Decoder(p.Bytes()[:p.Len()-1]), decode)
+	assert.ErrorIs(t, err, ErrInvalidUint32)
+}
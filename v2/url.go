@@ -0,0 +1,60 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"net/url"
+)
+
+// encodeURL writes the canonical form of value from url.URL.String, tagged with URLRawKind
+// rather than StringRawKind so that tooling can tell URLs apart from plain strings. A nil
+// value is encoded the same way as an empty URL.
+func encodeURL(b *Buffer, value *url.URL) {
+	var s string
+	if value != nil {
+		s = value.String()
+	}
+	b.Grow(1 + VarIntLen64 + len(s))
+	b.b[b.offset] = URLRawKind
+	b.offset++
+	writeUvarint(b, uint64(len(s)))
+	b.offset += copy(b.b[b.offset:], s)
+}
+
+func decodeURL(b []byte) ([]byte, *url.URL, error) {
+	if len(b) > 1 && b[0] == URLRawKind {
+		remaining, size, ok := readUvarint(b[1:])
+		if ok && size <= uint64(len(remaining)) {
+			value, err := url.Parse(string(remaining[:size]))
+			if err == nil {
+				return remaining[size:], value, nil
+			}
+		}
+	}
+	return b, nil, ErrInvalidURL
+}
+
+func (e *BufferEncoder) URL(value *url.URL) *BufferEncoder {
+	encodeURL((*Buffer)(e), value)
+	return e
+}
+
+func (d *BufferDecoder) URL() (value *url.URL, err error) {
+	d.b, value, err = decodeURL(d.b)
+	err = d.step(err)
+	return
+}
@@ -0,0 +1,85 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"encoding/binary"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// encodeStringUTF16 writes the number of UTF-16 code units in value followed by the code units
+// in little-endian order, without a byte order mark. Invalid UTF-8 is encoded as U+FFFD.
+func encodeStringUTF16(b *Buffer, value string) {
+	var units []uint16
+	for _, r := range value {
+		units = utf16.AppendRune(units, r)
+	}
+	b.Grow(1 + VarIntLen64 + 2*len(units))
+	b.b[b.offset] = StringUTF16RawKind
+	b.offset++
+	writeUvarint(b, uint64(len(units)))
+	for _, u := range units {
+		binary.LittleEndian.PutUint16(b.b[b.offset:], u)
+		b.offset += 2
+	}
+}
+
+func decodeStringUTF16(b []byte) ([]byte, string, error) {
+	if len(b) > 1 && b[0] == StringUTF16RawKind {
+		remaining, n, ok := readUvarint(b[1:])
+		if ok && uint64(len(remaining))/2 >= n {
+			units := remaining[:2*n]
+			value := make([]byte, 0, len(units))
+			for i := 0; i < len(units); i += 2 {
+				r := rune(binary.LittleEndian.Uint16(units[i:]))
+				if utf16.IsSurrogate(r) {
+					// A surrogate must be a high surrogate immediately followed by a low one
+					if i+2 >= len(units) {
+						return b, emptyString, ErrInvalidString
+					}
+					r = utf16.DecodeRune(r, rune(binary.LittleEndian.Uint16(units[i+2:])))
+					if r == utf8.RuneError {
+						return b, emptyString, ErrInvalidString
+					}
+					i += 2
+				}
+				value = utf8.AppendRune(value, r)
+			}
+			return remaining[2*n:], string(value), nil
+		}
+	}
+	return b, emptyString, ErrInvalidString
+}
+
+// StringUTF16 encodes value as little-endian UTF-16 code units for interoperability with
+// systems that only handle UTF-16. It must be decoded with StringUTF16 rather than String.
+func (e *BufferEncoder) StringUTF16(value string) *BufferEncoder {
+	encodeStringUTF16((*Buffer)(e), value)
+	return e
+}
+
+// StringUTF16 decodes a string encoded with StringUTF16, returning ErrInvalidString if it
+// contains unpaired surrogates.
+func (d *BufferDecoder) StringUTF16() (value string, err error) {
+	d.b, value, err = decodeStringUTF16(d.b)
+	err = d.step(err)
+	if err == nil {
+		value = d.intern(value)
+	}
+	return
+}
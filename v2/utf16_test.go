@@ -0,0 +1,73 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"testing"
+)
+
+func TestStringUTF16(t *testing.T) {
+	t.Parallel()
+
+	values := []string{"", "Test String", "héllo wörld", "日本語", "emoji 😀 and 𝄞", "�"}
+
+	p := NewBuffer()
+	e := Encoder(p)
+	for _, v := range values {
+		e.StringUTF16(v)
+	}
+
+	d := Decoder(p.Bytes())
+	for _, v := range values {
+		value, err := d.StringUTF16()
+		assert.NoError(t, err)
+		assert.Equal(t, v, value)
+	}
+	assert.Equal(t, 0, d.Remaining())
+
+	d = Decoder(p.Bytes())
+	for range values {
+		assert.NoError(t, d.Skip())
+	}
+	assert.Equal(t, 0, d.Remaining())
+
+	p.Reset()
+	Encoder(p).StringUTF16("😀")
+	assert.Equal(t, []byte{StringUTF16RawKind, 2, 0x3D, 0xD8, 0x00, 0xDE}, p.Bytes())
+
+	kind, value, err := Decoder(p.Bytes()).ReadTyped()
+	assert.NoError(t, err)
+	assert.Equal(t, StringUTF16Kind, kind)
+	assert.Equal(t, "😀", value)
+
+	_, err = Decoder(p.Bytes()[:5]).StringUTF16()
+	assert.ErrorIs(t, err, ErrInvalidString)
+
+	// An unpaired high surrogate, a low surrogate on its own, and a high surrogate at the end
+	for _, units := range [][]byte{{0x3D, 0xD8, 0x41, 0x00}, {0x00, 0xDE, 0x41, 0x00}, {0x41, 0x00, 0x3D, 0xD8}} {
+		_, err = Decoder(append([]byte{StringUTF16RawKind, 2}, units...)).StringUTF16()
+		assert.ErrorIs(t, err, ErrInvalidString)
+	}
+
+	p.Reset()
+	Encoder(p).StringUTF16("a\xffb")
+	value, err = Decoder(p.Bytes()).StringUTF16()
+	assert.NoError(t, err)
+	assert.Equal(t, "a�b", value)
+}
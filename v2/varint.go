@@ -0,0 +1,104 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"io"
+)
+
+// writeUvarint appends value to b as an untagged varint, growing b as needed.
+func writeUvarint(b *Buffer, value uint64) {
+	b.Grow(VarIntLen64)
+	offset := b.offset
+	for value >= continuation {
+		// Append the lower 7 bits of the value, then shift the value to the right by 7 bits.
+		b.b[offset] = byte(value) | continuation
+		value >>= 7
+		offset++
+	}
+	b.b[offset] = byte(value)
+	b.offset = offset + 1
+}
+
+// writeVarint appends value to b as an untagged zig-zag varint, growing b as needed.
+func writeVarint(b *Buffer, value int64) {
+	// Shift the value to the left by 1 bit, then flip the bits if the value is negative.
+	castValue := uint64(value) << 1
+	if value < 0 {
+		castValue = ^castValue
+	}
+	writeUvarint(b, castValue)
+}
+
+// readUvarint reads an untagged varint from the start of b, returning false
+// if b is too short or the varint overflows 64 bits.
+func readUvarint(b []byte) ([]byte, uint64, bool) {
+	var x uint64
+	var s uint
+	for i := 0; i < VarIntLen64 && i < len(b); i++ {
+		cb := b[i]
+		// Check if msb is set signifying a continuation byte
+		if cb < continuation {
+			if i == VarIntLen64-1 && cb > 1 {
+				return b, 0, false
+			}
+			return b[i+1:], x | uint64(cb)<<s, true
+		}
+		x |= uint64(cb&(continuation-1)) << s
+		s += 7
+	}
+	return b, 0, false
+}
+
+// readVarint reads an untagged zig-zag varint from the start of b.
+func readVarint(b []byte) ([]byte, int64, bool) {
+	b, ux, ok := readUvarint(b)
+	// Separate value and sign
+	x := int64(ux >> 1)
+	// If sign bit is set, negate the number
+	if ux&1 != 0 {
+		x = -(x + 1)
+	}
+	return b, x, ok
+}
+
+// Uvarint reads an untagged varint, without a leading kind byte, from the start of b and returns
+// it along with the number of bytes read. Unlike binary.Uvarint it returns io.ErrUnexpectedEOF
+// if b ends before the varint does, and ErrVarintOverflow if it doesn't fit in 64 bits.
+func Uvarint(b []byte) (value uint64, n int, err error) {
+	remaining, value, ok := readUvarint(b)
+	if !ok {
+		if len(b) < VarIntLen64 && (len(b) == 0 || b[len(b)-1] >= continuation) {
+			return 0, 0, io.ErrUnexpectedEOF
+		}
+		return 0, 0, ErrVarintOverflow
+	}
+	return value, len(b) - len(remaining), nil
+}
+
+// Varint reads an untagged zig-zag varint in the same way as Uvarint.
+func Varint(b []byte) (value int64, n int, err error) {
+	ux, n, err := Uvarint(b)
+	if err != nil {
+		return 0, 0, err
+	}
+	value = int64(ux >> 1)
+	if ux&1 != 0 {
+		value = -(value + 1)
+	}
+	return value, n, nil
+}
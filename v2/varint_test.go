@@ -0,0 +1,116 @@
+/*
+	Copyright 2023 Loophole Labs
+
+	Licensed under the Apache License, Version 2.0 (the "License");
+	you may not use this file except in compliance with the License.
+	You may obtain a copy of the License at
+
+		   http://www.apache.org/licenses/LICENSE-2.0
+
+	Unless required by applicable law or agreed to in writing, software
+	distributed under the License is distributed on an "AS IS" BASIS,
+	WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+	See the License for the specific language governing permissions and
+	limitations under the License.
+*/
+
+package polyglot
+
+import (
+	"github.com/stretchr/testify/assert"
+
+	"io"
+	"math"
+	"testing"
+)
+
+func TestUvarint(t *testing.T) {
+	t.Parallel()
+
+	values := []uint64{0, 1, continuation - 1, continuation, math.MaxUint32, math.MaxUint64}
+
+	p := NewBuffer()
+	for _, v := range values {
+		writeUvarint(p, v)
+	}
+
+	b := p.Bytes()
+	for _, v := range values {
+		var value uint64
+		var ok bool
+		b, value, ok = readUvarint(b)
+		assert.True(t, ok)
+		assert.Equal(t, v, value)
+	}
+	assert.Equal(t, 0, len(b))
+
+	_, _, ok := readUvarint([]byte{continuation, continuation})
+	assert.False(t, ok)
+
+	_, _, ok = readUvarint([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x02})
+	assert.False(t, ok)
+
+	_, _, ok = readUvarint(nil)
+	assert.False(t, ok)
+}
+
+func TestVarint(t *testing.T) {
+	t.Parallel()
+
+	values := []int64{0, 1, -1, 63, -64, 64, math.MaxInt32, math.MinInt32, math.MaxInt64, math.MinInt64}
+
+	p := NewBuffer()
+	for _, v := range values {
+		writeVarint(p, v)
+	}
+	assert.Equal(t, byte(1), (p.Bytes())[2])
+
+	b := p.Bytes()
+	for _, v := range values {
+		var value int64
+		var ok bool
+		b, value, ok = readVarint(b)
+		assert.True(t, ok)
+		assert.Equal(t, v, value)
+	}
+	assert.Equal(t, 0, len(b))
+}
+
+func TestScanVarint(t *testing.T) {
+	t.Parallel()
+
+	p := NewBuffer()
+	writeUvarint(p, 300)
+	writeVarint(p, -300)
+	writeUvarint(p, math.MaxUint64)
+
+	value, n, err := Uvarint(p.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(300), value)
+	assert.Equal(t, 2, n)
+
+	signed, m, err := Varint(p.Bytes()[n:])
+	assert.NoError(t, err)
+	assert.Equal(t, int64(-300), signed)
+	assert.Equal(t, 2, m)
+
+	value, n, err = Uvarint(p.Bytes()[4:])
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(math.MaxUint64), value)
+	assert.Equal(t, VarIntLen64, n)
+
+	_, _, err = Uvarint(nil)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	_, _, err = Uvarint(p.Bytes()[4 : 4+VarIntLen64-1])
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	_, _, err = Varint([]byte{0x80})
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+
+	_, _, err = Uvarint([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x02})
+	assert.ErrorIs(t, err, ErrVarintOverflow)
+
+	_, _, err = Uvarint([]byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0x01})
+	assert.ErrorIs(t, err, ErrVarintOverflow)
+}
@@ -18,11 +18,13 @@ package version
 
 import (
 	_ "embed"
+	"strings"
 )
 
 //go:embed current_version
 var currentVersion string
 
+// Version returns the current version, without the trailing newline of the file it's embedded from.
 func Version() string {
-	return currentVersion
+	return strings.TrimSpace(currentVersion)
 }